@@ -59,7 +59,7 @@ func (_ Provide) NewBufferFromFile(
 			Linebreak:        linebreak,
 		}
 		link(buffer, moment)
-		buffer.SetLanguage(scope, LanguageFromPath(path))
+		buffer.SetLanguage(scope, DetectLanguage(path, moment))
 
 		trigger(EvBufferCreated{
 			Buffer: buffer,
@@ -158,7 +158,7 @@ func (_ Provide) NewBuffersFromPath(
 				Linebreak:        linebreak,
 			}
 			link(buffer, moment)
-			buffer.SetLanguage(scope, LanguageFromPath(paths[i]))
+			buffer.SetLanguage(scope, DetectLanguage(paths[i], moment))
 			buffers = append(buffers, buffer)
 		}
 
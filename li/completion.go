@@ -15,14 +15,72 @@ type EvCollectCompletionCandidate struct {
 
 type CompletionCandidate struct {
 	Text             string
+	Detail           string // shown after Text
 	Rank             float64
 	MatchRuneOffsets []int
 	Begin            Position
 	End              Position
+	// if not nil, called instead of replacing range with Text
+	Apply func(scope Scope)
+}
+
+func (c CompletionCandidate) displayText() string {
+	if c.Detail == "" {
+		return c.Text
+	}
+	return c.Text + "  " + c.Detail
 }
 
 type AddCompletionCandidate func(CompletionCandidate)
 
+// completionPattern returns the word before cursor and its range
+func completionPattern(
+	moment *Moment,
+	state ViewMomentState,
+) (
+	pattern string,
+	begin Position,
+	end Position,
+	ok bool,
+) {
+	line := moment.GetLine(state.CursorLine)
+	if line == nil {
+		return
+	}
+	var cell int
+	col := 0
+	for i := 0; i < len(line.Cells); i++ {
+		if col >= state.CursorCol {
+			break
+		}
+		col += line.Cells[i].DisplayWidth
+		cell = i
+	}
+	endCell := cell + 1
+	for cell > 0 {
+		category := runeCategory(line.Cells[cell].Rune)
+		idx := cell - 1
+		if idx < 0 {
+			break
+		}
+		prevCategory := runeCategory(line.Cells[idx].Rune)
+		if category != prevCategory {
+			break
+		}
+		cell--
+	}
+	if endCell == cell {
+		// no pattern
+		return
+	}
+	runes := line.Runes()
+	pattern = string(runes[cell:endCell])
+	begin = Position{Line: state.CursorLine, Cell: cell}
+	end = Position{Line: state.CursorLine, Cell: endCell}
+	ok = true
+	return
+}
+
 type ContinueCompletion *int64
 
 func (_ Provide) ContinueCompletion() ContinueCompletion {
@@ -59,7 +117,7 @@ func (_ Provide) Completion(
 			maxWidth Width,
 			maxHeight Height,
 			cont ContinueCompletion,
-			calLineHeight CalculateSumLineHeight,
+			cursorPosition ViewCursorScreenPosition,
 		) {
 
 			// continue, dont update
@@ -143,10 +201,23 @@ func (_ Provide) Completion(
 						return c1.Text < c2.Text
 					})
 
+					// dedup, keep the first of the same text
+					seen := make(map[string]bool)
+					n := 0
+					for _, candidate := range candidates {
+						if seen[candidate.Text] {
+							continue
+						}
+						seen[candidate.Text] = true
+						candidates[n] = candidate
+						n++
+					}
+					candidates = candidates[:n]
+
 					// position
 					width := 0
 					for _, candidate := range candidates {
-						if w := displayWidth(candidate.Text); w > width {
+						if w := displayWidth(candidate.displayText()); w > width {
 							width = w
 						}
 					}
@@ -156,9 +227,7 @@ func (_ Provide) Completion(
 					}
 					view.RLock()
 					defer view.RUnlock()
-					cursorY := view.ContentBox.Top
-					lineHeight := calLineHeight(moment, [2]int{view.ViewportLine, view.CursorLine})
-					cursorY += lineHeight
+					cursorX, cursorY := cursorPosition(view, moment)
 					height := len(candidates)
 					below := true
 					var maxH int
@@ -171,7 +240,6 @@ func (_ Provide) Completion(
 					if height > maxH {
 						height = maxH
 					}
-					cursorX := view.ContentBox.Left + (view.CursorCol - view.ViewportCol)
 					left := cursorX - 1
 					if left+width > int(maxWidth) {
 						left = int(maxWidth) - width
@@ -252,6 +320,7 @@ func (c *CompletionList) RenderFunc() any {
 
 		style := getStyle("Completion")
 		selectedStyle := getStyle("CompletionSelected")
+		detailStyle := getStyle("CompletionDetail")
 
 		box := c.Box
 		box.Left++
@@ -264,8 +333,11 @@ func (c *CompletionList) RenderFunc() any {
 			}
 			texts = append(texts, Text(
 				box,
-				candidate.Text,
+				candidate.displayText(),
 				OffsetStyleFunc(func(i int) StyleFunc {
+					if i >= len([]rune(candidate.Text)) {
+						return lineStyle.And(detailStyle)
+					}
 					for _, offset := range candidate.MatchRuneOffsets {
 						if offset == i {
 							return lineStyle.SetUnderline(true)
@@ -302,10 +374,15 @@ func (c *CompletionList) StrokeSpecs() any {
 					apply := func(index int) {
 						c.index = &index
 						candidate := c.Candidates[index]
-						scope.Fork(
+						applyScope := scope.Fork(
 							AsCurrentView(c.View),
 							AsCurrentMoment(c.Moment),
-						).Call(func(
+						)
+						if candidate.Apply != nil {
+							candidate.Apply(applyScope)
+							return
+						}
+						applyScope.Call(func(
 							replace ReplaceWithinRange,
 						) {
 							replace(
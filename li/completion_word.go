@@ -4,7 +4,6 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
-	"unicode"
 )
 
 func (_ Provide) CollectWords(
@@ -152,42 +151,11 @@ func (_ Provide) CollectWords(
 		) {
 
 			// get pattern
-			line := ev.Moment.GetLine(ev.State.CursorLine)
-			var cell int
-			col := 0
-			for i := 0; i < len(line.Cells); i++ {
-				if col >= ev.State.CursorCol {
-					break
-				}
-				col += line.Cells[i].DisplayWidth
-				cell = i
-			}
-			endCell := cell + 1
-			for cell > 0 {
-				category := runeCategory(line.Cells[cell].Rune)
-				idx := cell - 1
-				if idx < 0 {
-					break
-				}
-				prevCategory := runeCategory(line.Cells[idx].Rune)
-				if category != prevCategory {
-					break
-				}
-				cell--
-			}
-			if endCell == cell {
-				// no pattern
+			pattern, beginPos, endPos, ok := completionPattern(ev.Moment, ev.State)
+			if !ok {
 				return
 			}
-			runes := line.Runes()
-			patternRunes := make([]rune, endCell-cell)
-			copy(patternRunes, runes[cell:endCell])
-			pattern := string(patternRunes)
-			for i, r := range patternRunes {
-				patternRunes[i] = unicode.ToLower(r)
-			}
-			beginPos := Position{Line: ev.State.CursorLine, Cell: cell}
-			endPos := Position{Line: ev.State.CursorLine, Cell: endCell}
+			patternRunes := []rune(strings.ToLower(pattern))
 
 			allCandidates := make(map[string]CompletionCandidate)
 			var l sync.Mutex
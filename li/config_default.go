@@ -19,6 +19,7 @@ TabWidth = 4
 StatusWidth = 20
 JournalHeight = 2
 MaxOutlineDistance = 2000
+OutlineWidth = 30
   [UI.ViewList]
   HideTimeoutSeconds = 1
   MarginLeft = 120
@@ -66,10 +67,67 @@ Layouts = [
   [Style.CompletionSelected]
   BG = 0x336666
 
+  [Style.CompletionDetail]
+  FG = 0x999999
+
+  [Style.Popup]
+  BG = 0x444444
+
+  [Style.PopupCode]
+  FG = 0x99CCFF
+
   [Style.Hint]
   Bold = true
   FG = 0xDEAD01
 
+  [Style.Changed]
+  BG = 0x2F3F2F
+
+  [Style.DiffDelete]
+  BG = 0x3F2626
+
+  [Style.DiffInsert]
+  BG = 0x263F26
+
+  [Style.DiffDeleteWord]
+  BG = 0x7F3030
+
+  [Style.DiffInsertWord]
+  BG = 0x307F30
+
+  [Style.GitAdded]
+  FG = 0x66BB66
+
+  [Style.GitModified]
+  FG = 0xCCAA44
+
+  [Style.GitDeleted]
+  FG = 0xCC5555
+
+  [Style.SemanticNamespace]
+  FG = 0x88AACC
+
+  [Style.SemanticTypeParameter]
+  FG = 0x55CCCC
+
+  [Style.SemanticParameter]
+  FG = 0xCCBB88
+
+  [Style.SemanticDeprecated]
+  Underline = true
+
+  [Style.DiagnosticError]
+  FG = 0xFF5555
+
+  [Style.DiagnosticWarning]
+  FG = 0xDDAA33
+
+  [Style.DiagnosticInfo]
+  FG = 0x55AADD
+
+  [Style.DiagnosticHint]
+  FG = 0x888888
+
 [ReadMode]
 
   [ReadMode.SequenceCommand]
@@ -111,6 +169,12 @@ Layouts = [
   'Rune[z] Rune[t]' = 'ScrollCursorToUpper'
   'Rune[z] Rune[z]' = 'ScrollCursorToMiddle'
   'Rune[z] Rune[b]' = 'ScrollCursorToLower'
+  'Rune[z] Rune[c]' = 'Fold'
+  'Rune[z] Rune[o]' = 'Unfold'
+  'Rune[z] Rune[a]' = 'ToggleFold'
+  'Rune[z] Rune[M]' = 'FoldAll'
+  'Rune[z] Rune[R]' = 'UnfoldAll'
+  'Rune[z] Rune[i]' = 'ToggleAllFolds'
   'Rune[x]' = 'DeleteRune'
   'Rune[c]' = 'Change'
   'Rune[c] Rune[w]' = 'ChangeToWordEnd'
@@ -119,6 +183,7 @@ Layouts = [
   'Rune[b]' = 'ShowViewSwitcher'
   'Rune[M]' = 'PageDown'
   'Rune[/]' = 'ShowSearchDialog'
+  'Rune[!]' = 'FilterThroughCommand'
 
   'Rune[,] Rune[q]' = 'CloseView'
   'Rune[,] Rune[w]' = 'SyncViewToFile'
@@ -126,14 +191,50 @@ Layouts = [
   'Rune[,] Rune[f]' = 'NextLineWithRune'
   'Rune[,] Rune[g]' = 'NextViewGroupLayout'
   'Rune[,] Rune[v]' = 'NextViewLayout'
+  'Rune[,] Rune[l]' = 'ToggleSoftWrap'
+  'Rune[,] Rune[h]' = 'EnterTimeline'
+  'Rune[,] Rune[s]' = 'SortLines'
+  'Rune[,] Rune[u]' = 'UniqueLines'
+  'Rune[,] Rune[a]' = 'AlignColumns'
+  'Rune[,] Rune[d]' = 'DiffWithDisk'
+  'Rune[,] Rune[D]' = 'DiffWithBuffer'
+  'Rune[,] Rune[=]' = 'FormatWithLanguageServer'
+  'Rune[,] Rune[b]' = 'ToggleGitBlame'
+  'Rune[,] Rune[r]' = 'RevertGitHunk'
+  'Rune[,] Rune[n]' = 'NextGitHunk'
+  'Rune[.] Rune[n]' = 'PrevGitHunk'
+  'Rune[,] Rune[e]' = 'NextDiagnostic'
+  'Rune[.] Rune[e]' = 'PrevDiagnostic'
+  'Rune[,] Rune[E]' = 'ShowDiagnostics'
+  'Rune[J]' = 'JoinLines'
+
+  'Rune[,] Rune[o]' = 'ToggleOutline'
+  'Rune[,] Rune[L]' = 'SetBufferLanguage'
 
   'Rune[,] Rune[N]' = 'CurrentTime'
 
   'Rune[.] Rune[g]' = 'PrevViewGroupLayout'
   'Rune[.] Rune[f]' = 'PrevLineWithRune'
   'Rune[.] Rune[v]' = 'PrevViewLayout'
+  'Rune[.] Rune[u]' = 'ShowUndoTree'
+  'Rune[.] Rune[d]' = 'DiffWithPreviousMoment'
+  'Rune[.] Rune[w]' = 'ShowSaveHistory'
 
   'Alt+Rune[u]' = 'RedoLatest'
+  'Ctrl+R' = 'RedoDuration1'
+  'Rune[g] Rune[u]' = 'UndoByUnit'
+  'Rune[g] Rune[r]' = 'RedoByUnit'
+  'Rune[g] Rune[d]' = 'GotoDefinition'
+  'Rune[g] Rune[t]' = 'GotoTypeDefinition'
+  'Rune[g] Rune[i]' = 'GotoImplementation'
+  'Rune[g] Rune[R]' = 'FindReferences'
+  'Rune[g] Rune[h]' = 'ShowHover'
+  'Rune[g] Rune[b]' = 'JumpBack'
+  'Rune[g] Rune[f]' = 'JumpForward'
+  'Rune[g] Rune[n]' = 'RenameSymbol'
+  'Rune[g] Rune[a]' = 'CodeActions'
+  'Rune[g] Rune[o]' = 'ShowOutline'
+  'Rune[g] Rune[s]' = 'WorkspaceSymbols'
 
   'Ctrl+U' = 'Undo'
   'Ctrl+O' = 'ShowCommandPalette'
@@ -147,14 +248,30 @@ DisableSequence = "kd"
 
   'Ctrl+O' = 'ShowCommandPalette'
 
+[SoftWrap]
+Enable = false
+Indicator = '↪'
+Indent = 2
+MoveByDisplayRow = true
+
 [Undo]
 DurationMS1 = 1000
+Unit = "session"
 
 [Debug]
 Verbose = false
 
 [LanguageServerProtocol]
 Enable = false
+Format = false
+TimeoutSeconds = 10
+
+  [LanguageServerProtocol.Servers.Go]
+  Command = 'gopls'
+  Args = ['-logfile', '${ConfigDir}/gopls.log', '-rpc.trace', '-v']
+  RootMarkers = ['go.work', 'go.mod', '.git']
+    [LanguageServerProtocol.Servers.Go.Settings.gopls]
+    semanticTokens = true
 
 [Formatter]
 DelaySeconds = 5
@@ -162,4 +279,21 @@ DelaySeconds = 5
 [Completion]
 DelayMilliseconds = 100
 
+[Timeline]
+StepSeconds = 60
+
+[Filter]
+Shell = "sh"
+TimeoutSeconds = 10
+
+[SaveHistory]
+Enable = true
+MaxEntries = 100
+MaxAgeDays = 30
+
+[Git]
+Command = "git"
+Gutter = true
+TimeoutSeconds = 10
+
 `
@@ -29,6 +29,8 @@ func (_ Provide) MoveCursor(
 	withN WithContextNumber,
 	trigger Trigger,
 	scrollToCursor ScrollToCursor,
+	scope Scope,
+	wrapConfig SoftWrapConfig,
 ) MoveCursor {
 
 	return func(move Move) {
@@ -46,16 +48,44 @@ func (_ Provide) MoveCursor(
 			return
 		}
 
+		moment := view.GetMoment()
+
+		// moving by wrapped display rows
+		if view.SoftWrap && wrapConfig.MoveByDisplayRow &&
+			move.RelLine != 0 && move.AbsLine == nil && move.AbsCol == nil {
+			line, col := view.moveByDisplayRow(scope, moment, wrapConfig, move.RelLine)
+			move = Move{
+				AbsLine: &line,
+				AbsCol:  &col,
+			}
+		}
+
 		// get line
 		var line int
 		if move.AbsLine != nil {
 			line = *move.AbsLine
+		} else if move.RelLine != 0 && len(view.FoldedLines) > 0 {
+			// skip folded lines
+			line = view.CursorLine
+			for i := 0; i < move.RelLine; i++ {
+				next := view.nextVisibleLine(scope, moment, line)
+				if next >= moment.NumLines() {
+					break
+				}
+				line = next
+			}
+			for i := 0; i > move.RelLine; i-- {
+				prev := view.prevVisibleLine(scope, moment, line)
+				if prev < 0 {
+					break
+				}
+				line = prev
+			}
 		} else {
 			line = view.CursorLine
 			line += move.RelLine
 		}
 
-		moment := view.GetMoment()
 		maxLine := moment.NumLines() - 1
 		currentPosition := view.cursorPosition()
 
@@ -191,6 +221,9 @@ func (_ Provide) MoveCursor(
 			}
 		}
 
+		// open folds hiding the target line
+		view.unfoldLine(scope, moment, line)
+
 		// no change
 		if view.CursorLine == line && view.CursorCol == col {
 			return
@@ -235,7 +268,7 @@ func (_ Provide) PageDown(
 	cur CurrentView,
 	config ScrollConfig,
 	moveCursor MoveCursor,
-	calLineHeights CalculateLineHeights,
+	calLineHeights CalculateViewLineHeights,
 ) PageDown {
 	return func() {
 		view := cur()
@@ -246,14 +279,14 @@ func (_ Provide) PageDown(
 
 		scrollHeight := view.Box.Height() - config.PaddingBottom
 		line := view.ViewportLine
-		lineHeights := calLineHeights(moment, [2]int{line, line + scrollHeight})
+		lineHeights := calLineHeights(view, moment, [2]int{line, line + scrollHeight})
 		scrollLines := 0
 		for {
-			if h, ok := lineHeights[line]; ok {
-				scrollHeight -= h
-			} else {
-				scrollHeight--
+			h, ok := lineHeights[line]
+			if !ok {
+				h = 1
 			}
+			scrollHeight -= h
 			if scrollHeight < 0 {
 				break
 			}
@@ -261,7 +294,10 @@ func (_ Provide) PageDown(
 				break
 			}
 			line++
-			scrollLines++
+			if h > 0 {
+				// folded lines not counted
+				scrollLines++
+			}
 		}
 
 		if view.ViewportLine != line {
@@ -277,7 +313,7 @@ func (_ Provide) PageUp(
 	cur CurrentView,
 	config ScrollConfig,
 	moveCursor MoveCursor,
-	calLineHeights CalculateLineHeights,
+	calLineHeights CalculateViewLineHeights,
 ) PageUp {
 	return func() {
 		view := cur()
@@ -288,23 +324,27 @@ func (_ Provide) PageUp(
 
 		scrollHeight := view.Box.Height() - config.PaddingTop
 		line := view.ViewportLine
-		lineHeights := calLineHeights(moment, [2]int{line - scrollHeight - 1, line})
+		lineHeights := calLineHeights(view, moment, [2]int{line - scrollHeight - 1, line})
+		lines := 0
 		for {
 			l := line - 1
 			if l < 0 {
 				break
 			}
-			if h, ok := lineHeights[l]; ok {
-				scrollHeight -= h
-			} else {
-				scrollHeight--
+			h, ok := lineHeights[l]
+			if !ok {
+				h = 1
 			}
+			scrollHeight -= h
 			if scrollHeight < 0 {
 				break
 			}
 			line--
+			if h > 0 {
+				// folded lines not counted
+				lines++
+			}
 		}
-		lines := view.ViewportLine - line
 		if line == 0 && scrollHeight > 0 {
 			// viewport not moving, set cursor line to 0
 			lines = view.CursorLine
@@ -0,0 +1,241 @@
+package li
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+type LineDiffOp uint8
+
+const (
+	LineEqual LineDiffOp = iota
+	LineDelete
+	LineInsert
+)
+
+type LineDiff struct {
+	Op   LineDiffOp
+	Text string
+	A    int // line number in a, -1 for insert
+	B    int // line number in b, -1 for delete
+}
+
+// diffLines returns line-based diff from a to b
+func diffLines(a []string, b []string) (ret []LineDiff) {
+	// encode distinct lines as runes and diff them with the Myers algorithm
+	runeOfLine := make(map[string]rune)
+	var lines []string
+	encode := func(ls []string) []rune {
+		rs := make([]rune, 0, len(ls))
+		for _, line := range ls {
+			r, ok := runeOfLine[line]
+			if !ok {
+				r = lineRune(len(lines))
+				runeOfLine[line] = r
+				lines = append(lines, line)
+			}
+			rs = append(rs, r)
+		}
+		return rs
+	}
+	runesA := encode(a)
+	runesB := encode(b)
+
+	h := diffmatchpatch.New()
+	ia, ib := 0, 0
+	for _, diff := range h.DiffMainRunes(runesA, runesB, false) {
+		for _, r := range diff.Text {
+			line := lines[lineIndex(r)]
+			switch diff.Type {
+			case diffmatchpatch.DiffEqual:
+				ret = append(ret, LineDiff{Op: LineEqual, Text: line, A: ia, B: ib})
+				ia++
+				ib++
+			case diffmatchpatch.DiffDelete:
+				ret = append(ret, LineDiff{Op: LineDelete, Text: line, A: ia, B: -1})
+				ia++
+			case diffmatchpatch.DiffInsert:
+				ret = append(ret, LineDiff{Op: LineInsert, Text: line, A: -1, B: ib})
+				ib++
+			}
+		}
+	}
+	return
+}
+
+const surrogateMin, surrogateMax = 0xd800, 0xdfff
+
+// lineRune maps line index to a valid rune, skipping surrogates
+func lineRune(i int) rune {
+	r := rune(i + 1)
+	if r >= surrogateMin {
+		r += surrogateMax - surrogateMin + 1
+	}
+	return r
+}
+
+func lineIndex(r rune) int {
+	if r > surrogateMax {
+		r -= surrogateMax - surrogateMin + 1
+	}
+	return int(r) - 1
+}
+
+// contentLines splits content into lines without line endings
+func contentLines(content string) []string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+	return strings.Split(content, "\n")
+}
+
+// diffMomentLines returns line-based diff between two moments
+func diffMomentLines(from *Moment, to *Moment) []LineDiff {
+	return diffLines(
+		linesText(from, 0, from.NumLines()-1),
+		linesText(to, 0, to.NumLines()-1),
+	)
+}
+
+// intraLineDiff marks runes of a and b that differ
+func intraLineDiff(a string, b string) (aMask []bool, bMask []bool) {
+	aMask = make([]bool, len([]rune(a)))
+	bMask = make([]bool, len([]rune(b)))
+	h := diffmatchpatch.New()
+	diffs := h.DiffMain(a, b, false)
+	diffs = h.DiffCleanupSemantic(diffs)
+	ia, ib := 0, 0
+	for _, diff := range diffs {
+		n := len([]rune(diff.Text))
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			ia += n
+			ib += n
+		case diffmatchpatch.DiffDelete:
+			for i := 0; i < n; i++ {
+				aMask[ia+i] = true
+			}
+			ia += n
+		case diffmatchpatch.DiffInsert:
+			for i := 0; i < n; i++ {
+				bMask[ib+i] = true
+			}
+			ib += n
+		}
+	}
+	return
+}
+
+// replaceMomentLines replaces lines [begin, end) of moment with lines
+func replaceMomentLines(
+	apply ApplyChange,
+	moment *Moment,
+	begin int,
+	end int,
+	lines []string,
+) *Moment {
+	numLines := moment.NumLines()
+	lastCell := func(line int) int {
+		return len(moment.GetLine(line).Cells) - 1
+	}
+	text := strings.Join(lines, "\n")
+	var change Change
+
+	switch {
+
+	case len(lines) > 0 && begin == end && begin < numLines:
+		// insert before line
+		change = Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: begin},
+			String: text + "\n",
+		}
+
+	case len(lines) > 0 && begin == end:
+		// append after last line
+		change = Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: numLines - 1, Cell: lastCell(numLines - 1)},
+			String: "\n" + text,
+		}
+
+	case len(lines) > 0:
+		if end < numLines {
+			text += "\n"
+		}
+		change = Change{
+			Op:     OpReplace,
+			Begin:  Position{Line: begin},
+			End:    Position{Line: end},
+			String: text,
+		}
+
+	case end < numLines:
+		change = Change{
+			Op:    OpDelete,
+			Begin: Position{Line: begin},
+			End:   Position{Line: end},
+		}
+
+	case begin == 0:
+		// delete all
+		change = Change{
+			Op:    OpReplace,
+			Begin: Position{Line: 0},
+			End:   Position{Line: numLines},
+		}
+
+	default:
+		// delete trailing lines with the line break before them
+		change = Change{
+			Op:    OpDelete,
+			Begin: Position{Line: begin - 1, Cell: lastCell(begin - 1)},
+			End:   Position{Line: numLines - 1, Cell: lastCell(numLines - 1)},
+		}
+
+	}
+
+	newMoment, _ := apply(moment, change)
+	return newMoment
+}
+
+type LineHunk struct {
+	ABegin int // lines, end exclusive
+	AEnd   int
+	BBegin int
+	BEnd   int
+}
+
+// lineHunks groups consecutive changed lines
+func lineHunks(diffs []LineDiff) (hunks []LineHunk) {
+	nextA, nextB := 0, 0
+	var hunk *LineHunk
+	for _, diff := range diffs {
+		if diff.Op == LineEqual {
+			if hunk != nil {
+				hunks = append(hunks, *hunk)
+				hunk = nil
+			}
+			nextA = diff.A + 1
+			nextB = diff.B + 1
+			continue
+		}
+		if hunk == nil {
+			hunk = &LineHunk{
+				ABegin: nextA,
+				AEnd:   nextA,
+				BBegin: nextB,
+				BEnd:   nextB,
+			}
+		}
+		if diff.Op == LineDelete {
+			hunk.AEnd++
+		} else {
+			hunk.BEnd++
+		}
+	}
+	if hunk != nil {
+		hunks = append(hunks, *hunk)
+	}
+	return
+}
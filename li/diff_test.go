@@ -0,0 +1,104 @@
+package li
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	diffs := diffLines(
+		[]string{"a", "b", "c", "a", "b", "b", "a"},
+		[]string{"c", "b", "a", "b", "a", "c"},
+	)
+	var a, b []string
+	for _, diff := range diffs {
+		if diff.Op != LineInsert {
+			a = append(a, diff.Text)
+		}
+		if diff.Op != LineDelete {
+			b = append(b, diff.Text)
+		}
+	}
+	eq(t,
+		len(a), 7,
+		len(b), 6,
+	)
+	numEqual := 0
+	for _, diff := range diffs {
+		if diff.Op == LineEqual {
+			numEqual++
+		}
+	}
+	eq(t,
+		numEqual, 4,
+	)
+
+	aMask, bMask := intraLineDiff("foo bar baz", "foo qux baz")
+	eq(t,
+		aMask[0], false,
+		aMask[4], true,
+		bMask[6], true,
+		bMask[8], false,
+	)
+}
+
+func TestDiffLinesMany(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 70000; i++ {
+		line := strconv.Itoa(i)
+		a = append(a, line)
+		if i%1000 != 0 {
+			b = append(b, line)
+		}
+	}
+	b = append(b, "foo")
+	numDelete, numInsert := 0, 0
+	for _, diff := range diffLines(a, b) {
+		switch diff.Op {
+		case LineDelete:
+			numDelete++
+			eq(t,
+				diff.Text, a[diff.A],
+			)
+		case LineInsert:
+			numInsert++
+			eq(t,
+				diff.Text, b[diff.B],
+			)
+		case LineEqual:
+			eq(t,
+				a[diff.A], b[diff.B],
+			)
+		}
+	}
+	eq(t,
+		numDelete, 70,
+		numInsert, 1,
+	)
+}
+
+func TestReplaceMomentLines(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\n"), func(
+		moment *Moment,
+		apply ApplyChange,
+	) {
+		for _, c := range []struct {
+			begin, end int
+			lines      []string
+			expected   string
+		}{
+			{1, 1, []string{"x", "y"}, "a\nx\ny\nb\nc\n"},
+			{3, 3, []string{"x"}, "a\nb\nc\nx\n"},
+			{1, 2, []string{"x", "y"}, "a\nx\ny\nc\n"},
+			{1, 3, []string{"x"}, "a\nx\n"},
+			{0, 1, nil, "b\nc\n"},
+			{1, 3, nil, "a\n"},
+			{0, 3, nil, "\n"},
+		} {
+			m := replaceMomentLines(apply, moment, c.begin, c.end, c.lines)
+			eq(t,
+				m.GetContent(), c.expected,
+			)
+		}
+	})
+}
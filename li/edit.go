@@ -10,17 +10,46 @@ type Op uint8
 const (
 	OpInsert Op = iota
 	OpDelete
+	OpReplace
 )
 
 type Change struct {
 	Op     Op
-	String string   // for Insert
-	Begin  Position // for Insert or Delete
+	String string   // for Insert or Replace
+	Begin  Position // for Insert, Delete or Replace
 	// for Delete operation, one of End and Number must be set
-	End    Position // for Delete
+	End    Position // for Delete or Replace
 	Number int      // for Delete
 }
 
+// resolveChange converts Number of delete change to End position
+func resolveChange(moment *Moment, change Change) Change {
+	if change.Number <= 0 {
+		return change
+	}
+	change.End = change.Begin
+	// iterate
+	for change.Number > 0 {
+		line := moment.GetLine(change.End.Line)
+		if line == nil {
+			change.Number = 0
+			change.End.Line--
+			change.End.Cell = len(moment.GetLine(change.End.Line).Cells) - 1
+		} else {
+			if change.End.Cell+change.Number >= len(line.Cells) {
+				// next line
+				change.Number -= len(line.Cells) - change.End.Cell
+				change.End.Cell = 0
+				change.End.Line++
+			} else {
+				change.End.Cell += change.Number
+				change.Number = 0
+			}
+		}
+	}
+	return change
+}
+
 type ApplyChange func(
 	moment *Moment,
 	change Change,
@@ -35,6 +64,105 @@ func (_ Provide) ApplyChange(
 	linkedOne LinkedOne,
 ) ApplyChange {
 
+	insert := func(
+		moment *Moment,
+		change Change,
+	) (
+		newSegments Segments,
+		numRunesInserted int,
+	) {
+		newSegments = moment.segments.Sub(-1, change.Begin.Line)
+		line := moment.GetLine(change.Begin.Line)
+		offset := 0
+		for _, cell := range line.Cells[:change.Begin.Cell] {
+			offset += cell.Len
+		}
+		content := line.content[:offset] + change.String + line.content[offset:]
+		numRunesInserted += len([]rune(change.String))
+		changingLastLine := change.Begin.Line == moment.NumLines()-1
+		lines := splitLines(content)
+		newSegment := new(Segment)
+		for i, content := range lines {
+			if changingLastLine && i == len(lines)-1 {
+				// add newline to the last line
+				if !strings.HasSuffix(content, "\n") {
+					content += "\n"
+					numRunesInserted++
+				}
+			}
+			newSegment.lines = append(newSegment.lines, &Line{
+				content:  content,
+				initOnce: new(sync.Once),
+				config:   &config,
+			})
+		}
+		newSegments = append(newSegments, newSegment)
+		newSegments = append(newSegments, moment.segments.Sub(change.Begin.Line+1, -1)...)
+		return
+	}
+
+	del := func(
+		moment *Moment,
+		change Change,
+	) (
+		newSegments Segments,
+		resolved Change,
+	) {
+		change = resolveChange(moment, change)
+		resolved = change
+
+		if change.Begin == change.End {
+			return moment.segments, resolved
+		}
+
+		// assemble new lines
+		newSegments = moment.segments.Sub(-1, change.Begin.Line)
+		var b strings.Builder
+		for lineNum := change.Begin.Line; lineNum <= change.End.Line; lineNum++ {
+			if lineNum >= moment.NumLines() {
+				break
+			}
+			if lineNum == change.Begin.Line {
+				for _, cell := range moment.GetLine(lineNum).Cells {
+					if cell.RuneOffset >= change.Begin.Cell {
+						break
+					}
+					b.WriteRune(cell.Rune)
+				}
+			}
+			if lineNum == change.End.Line {
+				for _, cell := range moment.GetLine(lineNum).Cells {
+					if cell.RuneOffset < change.End.Cell {
+						continue
+					}
+					b.WriteRune(cell.Rune)
+				}
+			}
+		}
+		changingLastLine := change.End.Line >= moment.NumLines()-1
+		lines := splitLines(b.String())
+		newSegment := new(Segment)
+		for i, content := range lines {
+			if changingLastLine && i == len(lines)-1 {
+				// add newline to the last line
+				if !strings.HasSuffix(content, "\n") {
+					content += "\n"
+				}
+			}
+			newSegment.lines = append(newSegment.lines, &Line{
+				content:  content,
+				initOnce: new(sync.Once),
+				config:   &config,
+			})
+		}
+		newSegments = append(newSegments, newSegment)
+		res := change.End.Line + 1
+		if res < moment.NumLines() {
+			newSegments = append(newSegments, moment.segments.Sub(res, -1)...)
+		}
+		return
+	}
+
 	return func(
 		moment *Moment,
 		change Change,
@@ -60,109 +188,26 @@ func (_ Provide) ApplyChange(
 		switch change.Op {
 
 		case OpInsert:
-			newSegments = moment.segments.Sub(-1, change.Begin.Line)
-			line := moment.GetLine(change.Begin.Line)
-			offset := 0
-			for _, cell := range line.Cells[:change.Begin.Cell] {
-				offset += cell.Len
-			}
-			content := line.content[:offset] + change.String + line.content[offset:]
-			numRunesInserted += len([]rune(change.String))
-			changingLastLine := change.Begin.Line == moment.NumLines()-1
-			lines := splitLines(content)
-			newSegment := new(Segment)
-			for i, content := range lines {
-				if changingLastLine && i == len(lines)-1 {
-					// add newline to the last line
-					if !strings.HasSuffix(content, "\n") {
-						content += "\n"
-						numRunesInserted++
-					}
-				}
-				newSegment.lines = append(newSegment.lines, &Line{
-					content:  content,
-					initOnce: new(sync.Once),
-					config:   &config,
-				})
-			}
-			newSegments = append(newSegments, newSegment)
-			newSegments = append(newSegments, moment.segments.Sub(change.Begin.Line+1, -1)...)
+			newSegments, numRunesInserted = insert(moment, change)
 
 		case OpDelete:
-			// resolve change.Number
-			if change.Number > 0 {
-				change.End = change.Begin
-				// iterate
-				for change.Number > 0 {
-					line := moment.GetLine(change.End.Line)
-					if line == nil {
-						change.Number = 0
-						change.End.Line--
-						change.End.Cell = len(moment.GetLine(change.End.Line).Cells) - 1
-					} else {
-						if change.End.Cell+change.Number >= len(line.Cells) {
-							// next line
-							change.Number -= len(line.Cells) - change.End.Cell
-							change.End.Cell = 0
-							change.End.Line++
-						} else {
-							change.End.Cell += change.Number
-							change.Number = 0
-						}
-					}
-				}
-			}
-
+			newSegments, change = del(moment, change)
 			if change.Begin == change.End {
 				newMoment = moment
 				return
 			}
 
-			// assemble new lines
-			newSegments = moment.segments.Sub(-1, change.Begin.Line)
-			var b strings.Builder
-			for lineNum := change.Begin.Line; lineNum <= change.End.Line; lineNum++ {
-				if lineNum >= moment.NumLines() {
-					break
-				}
-				if lineNum == change.Begin.Line {
-					for _, cell := range moment.GetLine(lineNum).Cells {
-						if cell.RuneOffset >= change.Begin.Cell {
-							break
-						}
-						b.WriteRune(cell.Rune)
-					}
-				}
-				if lineNum == change.End.Line {
-					for _, cell := range moment.GetLine(lineNum).Cells {
-						if cell.RuneOffset < change.End.Cell {
-							continue
-						}
-						b.WriteRune(cell.Rune)
-					}
+		case OpReplace:
+			// delete then insert, in one moment
+			if change.Begin != change.End {
+				var segments Segments
+				segments, _ = del(moment, change)
+				moment = &Moment{
+					segments: segments,
 				}
 			}
-			changingLastLine := change.End.Line >= moment.NumLines()-1
-			lines := splitLines(b.String())
-			newSegment := new(Segment)
-			for i, content := range lines {
-				if changingLastLine && i == len(lines)-1 {
-					// add newline to the last line
-					if !strings.HasSuffix(content, "\n") {
-						content += "\n"
-					}
-				}
-				newSegment.lines = append(newSegment.lines, &Line{
-					content:  content,
-					initOnce: new(sync.Once),
-					config:   &config,
-				})
-			}
-			newSegments = append(newSegments, newSegment)
-			res := change.End.Line + 1
-			if res < moment.NumLines() {
-				newSegments = append(newSegments, moment.segments.Sub(res, -1)...)
-			}
+			newSegments, numRunesInserted = insert(moment, change)
+			moment = newMoment.Previous
 
 		}
 
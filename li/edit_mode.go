@@ -141,6 +141,7 @@ func (e *EditMode) StrokeSpecs() any {
 					ev KeyEvent,
 					insert InsertAtPositionFunc,
 					posCursor PosCursor,
+					deletePlaceholder DeleteSnippetPlaceholder,
 				) {
 
 					// match disable sequence
@@ -162,6 +163,9 @@ func (e *EditMode) StrokeSpecs() any {
 					}
 					e.matchStates = ts
 
+					// typing replaces selected snippet placeholder
+					deletePlaceholder()
+
 					// insert
 					fn := PositionFunc(posCursor)
 					str := string(ev.Rune())
@@ -192,7 +196,11 @@ func (e *EditMode) StrokeSpecs() any {
 			},
 			{
 				Sequence:    []string{"Tab"},
-				CommandName: "InsertTab",
+				CommandName: "NextSnippetTabstopOrInsertTab",
+			},
+			{
+				Sequence:    []string{"Backtab"},
+				CommandName: "PrevSnippetTabstop",
 			},
 
 			//
@@ -55,8 +55,14 @@ type SyncBufferMomentToFile func(
 	err error,
 )
 
+type EvBufferSynced struct {
+	Buffer *Buffer
+	Moment *Moment
+}
+
 func (_ Provide) SyncBufferMomentToFile(
 	linkedAll LinkedAll,
+	trigger Trigger,
 ) SyncBufferMomentToFile {
 	return func(
 		buffer *Buffer,
@@ -99,6 +105,11 @@ func (_ Provide) SyncBufferMomentToFile(
 		moment.FileInfo = diskFileInfo
 		buffer.LastSyncFileInfo = diskFileInfo
 
+		trigger(EvBufferSynced{
+			Buffer: buffer,
+			Moment: moment,
+		})
+
 		return
 	}
 }
@@ -0,0 +1,200 @@
+package li
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FilterConfig struct {
+	Shell          string
+	TimeoutSeconds int
+}
+
+func (_ Provide) FilterConfig(
+	get GetConfig,
+) FilterConfig {
+	var config struct {
+		Filter FilterConfig
+	}
+	config.Filter.Shell = "sh"
+	config.Filter.TimeoutSeconds = 10
+	ce(get(&config))
+	return config.Filter
+}
+
+type RunFilter func(
+	view *View,
+	r Range,
+	command string,
+)
+
+type CancelFilters func()
+
+func (_ Provide) Filter(
+	config FilterConfig,
+	run RunInMainLoop,
+	j AppendJournal,
+	show ShowMessage,
+) (
+	runFilter RunFilter,
+	cancelFilters CancelFilters,
+) {
+
+	var l sync.Mutex
+	cancels := make(map[*context.CancelFunc]bool)
+
+	runFilter = func(
+		view *View,
+		r Range,
+		command string,
+	) {
+		moment := view.GetMoment()
+		input := (&Clip{
+			Moment: moment,
+			Range:  r,
+		}).String()
+
+		timeout := time.Second * time.Duration(config.TimeoutSeconds)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		l.Lock()
+		cancels[&cancel] = true
+		l.Unlock()
+
+		go func() {
+			defer func() {
+				l.Lock()
+				delete(cancels, &cancel)
+				l.Unlock()
+				cancel()
+			}()
+
+			cmd := exec.CommandContext(ctx, config.Shell, "-c", command)
+			if view.Buffer.Path != "" {
+				cmd.Dir = filepath.Dir(view.Buffer.AbsPath)
+			}
+			cmd.Stdin = strings.NewReader(input)
+			stdout := new(bytes.Buffer)
+			stderr := new(bytes.Buffer)
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			t0 := time.Now()
+			err := cmd.Run()
+
+			var lines []string
+			if err != nil {
+				var exitErr *exec.ExitError
+				switch {
+				case errors.Is(ctx.Err(), context.DeadlineExceeded):
+					lines = append(lines, fmt.Sprintf("filter timeout after %v: %s", timeout, command))
+				case errors.Is(ctx.Err(), context.Canceled):
+					lines = append(lines, fmt.Sprintf("filter canceled: %s", command))
+				case errors.As(err, &exitErr):
+					lines = append(lines, fmt.Sprintf("filter exited with code %d: %s", exitErr.ExitCode(), command))
+				default:
+					lines = append(lines, fmt.Sprintf("filter error: %s", err.Error()))
+				}
+			}
+			if stderr.Len() > 0 {
+				lines = append(lines, strings.Split(strings.TrimRight(stderr.String(), "\n"), "\n")...)
+			}
+			if len(lines) > 0 {
+				j("%s", strings.Join(lines, "\n"))
+				show(lines)
+			}
+			if err != nil {
+				return
+			}
+
+			output := stdout.String()
+			if !strings.HasSuffix(input, "\n") {
+				output = strings.TrimSuffix(output, "\n")
+			}
+
+			run(func(
+				scope Scope,
+				apply ApplyChange,
+				moveCursor MoveCursor,
+			) {
+				if view.GetMoment() != moment {
+					j("buffer changed, filter output discarded: %s", command)
+					return
+				}
+				newMoment, _ := apply(moment, Change{
+					Op:     OpReplace,
+					Begin:  r.Begin,
+					End:    r.End,
+					String: output,
+				})
+				view.SelectionAnchor = nil
+				view.switchMoment(scope, newMoment)
+				moveCursor(Move{AbsLine: intP(r.Begin.Line), AbsCol: intP(0)})
+				j("filter %s in %v", command, time.Since(t0))
+			})
+		}()
+	}
+
+	cancelFilters = func() {
+		l.Lock()
+		defer l.Unlock()
+		for cancel := range cancels {
+			(*cancel)()
+		}
+	}
+
+	return
+}
+
+// filterRange returns the selected range, or the whole buffer
+func filterRange(view *View) Range {
+	if r := view.selectedRange(); r != nil {
+		return *r
+	}
+	moment := view.GetMoment()
+	last := moment.NumLines() - 1
+	return Range{
+		Begin: Position{},
+		End: Position{
+			Line: last,
+			Cell: len(moment.GetLine(last).Cells) - 1,
+		},
+	}
+}
+
+func FilterThroughCommand(
+	cur CurrentView,
+	showPrompt ShowPrompt,
+	runFilter RunFilter,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	r := filterRange(view)
+	showPrompt("Filter Command", "", func(_ Scope, command string) {
+		if strings.TrimSpace(command) == "" {
+			return
+		}
+		runFilter(view, r, command)
+	})
+}
+
+func (_ Command) FilterThroughCommand() (spec CommandSpec) {
+	spec.Desc = "filter selection or buffer through external command"
+	spec.Func = FilterThroughCommand
+	return
+}
+
+func (_ Command) CancelFilters() (spec CommandSpec) {
+	spec.Desc = "cancel running external filter commands"
+	spec.Func = func(cancel CancelFilters) {
+		cancel()
+	}
+	return
+}
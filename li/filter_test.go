@@ -0,0 +1,51 @@
+package li
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	withEditorBytes(t, []byte("b\na\nc\n"), func(
+		view *View,
+		moment *Moment,
+		runFilter RunFilter,
+		ctrl func(string),
+	) {
+		wait := func() {
+			deadline := time.Now().Add(time.Second * 5)
+			for view.GetMoment() == moment && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond * 10)
+				ctrl("loop")
+			}
+		}
+
+		runFilter(view, filterRange(view), "sort")
+		wait()
+		m := view.GetMoment()
+		eq(t,
+			m.GetContent(), "a\nb\nc\n",
+			m.Previous == moment, true,
+		)
+
+		// selection
+		moment = m
+		view.SelectionAnchor = &Position{Line: 1, Cell: 0}
+		view.CursorLine = 2
+		view.CursorCol = 1
+		runFilter(view, filterRange(view), "tr a-z A-Z")
+		wait()
+		eq(t,
+			view.GetMoment().GetContent(), "a\nB\nC\n",
+		)
+
+		// non-zero exit keeps content
+		moment = view.GetMoment()
+		runFilter(view, filterRange(view), "exit 3")
+		time.Sleep(time.Millisecond * 200)
+		ctrl("loop")
+		eq(t,
+			view.GetMoment() == moment, true,
+		)
+	})
+}
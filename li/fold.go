@@ -0,0 +1,417 @@
+package li
+
+import (
+	"sort"
+
+	"github.com/reusee/li/treesitter"
+)
+
+type FoldRange struct {
+	Begin int // first line, rendered as the summary line
+	End   int // last line, inclusive
+}
+
+func (r FoldRange) Contains(line int) bool {
+	return line >= r.Begin && line <= r.End
+}
+
+func (m *Moment) GetFoldRanges(scope Scope) []FoldRange {
+	m.initFoldRangesOnce.Do(func() {
+		var buffer *Buffer
+		var linked LinkedOne
+		scope.Assign(&linked)
+		linked(m, &buffer)
+
+		var ranges []FoldRange
+		if buffer != nil {
+			if spec := languageSpec(buffer.language); spec != nil && spec.FoldNodeTypes != nil {
+				if parser := m.GetParser(scope); parser != nil {
+					ranges = syntaxFoldRanges(parser, spec.FoldNodeTypes)
+				}
+			}
+		}
+		if ranges == nil {
+			ranges = indentFoldRanges(m)
+		}
+
+		// one range per begin line, the outermost wins
+		sort.SliceStable(ranges, func(i, j int) bool {
+			if ranges[i].Begin != ranges[j].Begin {
+				return ranges[i].Begin < ranges[j].Begin
+			}
+			return ranges[i].End > ranges[j].End
+		})
+		byBegin := make(map[int]FoldRange)
+		uniq := ranges[:0]
+		for _, r := range ranges {
+			if _, ok := byBegin[r.Begin]; ok {
+				continue
+			}
+			byBegin[r.Begin] = r
+			uniq = append(uniq, r)
+		}
+
+		m.foldRanges = uniq
+		m.foldRangesByBegin = byBegin
+	})
+	return m.foldRanges
+}
+
+func (m *Moment) foldRangeAt(scope Scope, line int) (r FoldRange, ok bool) {
+	m.GetFoldRanges(scope)
+	r, ok = m.foldRangesByBegin[line]
+	return
+}
+
+func syntaxFoldRanges(
+	parser *treesitter.Parser,
+	nodeTypes map[string]bool,
+) (ranges []FoldRange) {
+
+	// consecutive comment lines
+	commentBegin, commentEnd := -1, -1
+	flushComments := func() {
+		if commentEnd > commentBegin {
+			ranges = append(ranges, FoldRange{
+				Begin: commentBegin,
+				End:   commentEnd,
+			})
+		}
+		commentBegin, commentEnd = -1, -1
+	}
+
+	treesitter.Walk(parser.RootNode(), func(node treesitter.TSNode) {
+		nodeType := treesitter.NodeType(node)
+		startRow, _, endRow, endCol := treesitter.NodePosition(node)
+		if endCol == 0 && endRow > startRow {
+			// ends at the beginning of next line
+			endRow--
+		}
+
+		if nodeType == "comment" {
+			if commentBegin >= 0 && startRow == commentEnd+1 {
+				commentEnd = endRow
+				return
+			}
+			flushComments()
+			commentBegin, commentEnd = startRow, endRow
+			return
+		}
+
+		if !nodeTypes[nodeType] || endRow <= startRow {
+			return
+		}
+		ranges = append(ranges, FoldRange{
+			Begin: startRow,
+			End:   endRow,
+		})
+	})
+	flushComments()
+
+	if ranges == nil {
+		ranges = []FoldRange{}
+	}
+	return
+}
+
+func indentFoldRanges(m *Moment) (ranges []FoldRange) {
+	numLines := m.NumLines()
+	for i := 0; i < numLines; i++ {
+		line := m.GetLine(i)
+		if line.NonSpaceDisplayOffset == nil {
+			continue
+		}
+		indent := *line.NonSpaceDisplayOffset
+		end := i
+		for j := i + 1; j < numLines; j++ {
+			l := m.GetLine(j)
+			if l.NonSpaceDisplayOffset == nil {
+				// blank lines do not end a block
+				continue
+			}
+			if *l.NonSpaceDisplayOffset <= indent {
+				break
+			}
+			end = j
+		}
+		if end > i {
+			ranges = append(ranges, FoldRange{
+				Begin: i,
+				End:   end,
+			})
+		}
+	}
+	return
+}
+
+// foldAt returns the folded range beginning at line
+func (v *View) foldAt(scope Scope, moment *Moment, line int) (r FoldRange, ok bool) {
+	if !v.FoldedLines[line] {
+		return
+	}
+	return moment.foldRangeAt(scope, line)
+}
+
+// hidingFold returns the outermost folded range that hides line
+func (v *View) hidingFold(scope Scope, moment *Moment, line int) (ret FoldRange, ok bool) {
+	for begin := range v.FoldedLines {
+		if begin >= line {
+			continue
+		}
+		r, found := moment.foldRangeAt(scope, begin)
+		if !found || !r.Contains(line) {
+			continue
+		}
+		if !ok || r.Begin < ret.Begin {
+			ret = r
+			ok = true
+		}
+	}
+	return
+}
+
+func (v *View) isLineHidden(scope Scope, moment *Moment, line int) bool {
+	if len(v.FoldedLines) == 0 {
+		return false
+	}
+	_, ok := v.hidingFold(scope, moment, line)
+	return ok
+}
+
+func (v *View) nextVisibleLine(scope Scope, moment *Moment, line int) int {
+	if r, ok := v.foldAt(scope, moment, line); ok {
+		line = r.End
+	}
+	line++
+	for {
+		r, ok := v.hidingFold(scope, moment, line)
+		if !ok {
+			return line
+		}
+		line = r.End + 1
+	}
+}
+
+func (v *View) prevVisibleLine(scope Scope, moment *Moment, line int) int {
+	line--
+	for {
+		r, ok := v.hidingFold(scope, moment, line)
+		if !ok {
+			return line
+		}
+		line = r.Begin
+	}
+}
+
+// unfoldLine removes all folds that hide line
+func (v *View) unfoldLine(scope Scope, moment *Moment, line int) {
+	for {
+		r, ok := v.hidingFold(scope, moment, line)
+		if !ok {
+			return
+		}
+		delete(v.FoldedLines, r.Begin)
+		v.FoldVersion++
+	}
+}
+
+func (v *View) setFolded(line int, folded bool) {
+	if folded {
+		v.FoldedLines[line] = true
+	} else {
+		delete(v.FoldedLines, line)
+	}
+	v.FoldVersion++
+}
+
+// cursorOutOfFolds moves cursor to the summary line if it is hidden
+func cursorOutOfFolds(
+	scope Scope,
+	view *View,
+	moveCursor MoveCursor,
+) {
+	moment := view.GetMoment()
+	if r, ok := view.hidingFold(scope, moment, view.CursorLine); ok {
+		moveCursor(Move{AbsLine: intP(r.Begin)})
+	}
+}
+
+func Fold(
+	cur CurrentView,
+	scope Scope,
+	moveCursor MoveCursor,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	moment := view.GetMoment()
+	// innermost unfolded range containing cursor line
+	var target *FoldRange
+	for _, r := range moment.GetFoldRanges(scope) {
+		if r.Begin > view.CursorLine {
+			break
+		}
+		if !r.Contains(view.CursorLine) || view.FoldedLines[r.Begin] {
+			continue
+		}
+		if target == nil || r.End-r.Begin < target.End-target.Begin {
+			r := r
+			target = &r
+		}
+	}
+	if target == nil {
+		return
+	}
+	view.setFolded(target.Begin, true)
+	cursorOutOfFolds(scope, view, moveCursor)
+}
+
+func (_ Command) Fold() (spec CommandSpec) {
+	spec.Desc = "fold innermost block at cursor"
+	spec.Func = Fold
+	return
+}
+
+func Unfold(
+	cur CurrentView,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if _, ok := view.foldAt(scope, view.GetMoment(), view.CursorLine); ok {
+		view.setFolded(view.CursorLine, false)
+	}
+}
+
+func (_ Command) Unfold() (spec CommandSpec) {
+	spec.Desc = "unfold block at cursor"
+	spec.Func = Unfold
+	return
+}
+
+func ToggleFold(
+	cur CurrentView,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if _, ok := view.foldAt(scope, view.GetMoment(), view.CursorLine); ok {
+		scope.Call(Unfold)
+	} else {
+		scope.Call(Fold)
+	}
+}
+
+func (_ Command) ToggleFold() (spec CommandSpec) {
+	spec.Desc = "fold or unfold block at cursor"
+	spec.Func = ToggleFold
+	return
+}
+
+func FoldAll(
+	cur CurrentView,
+	scope Scope,
+	moveCursor MoveCursor,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	for _, r := range view.GetMoment().GetFoldRanges(scope) {
+		view.FoldedLines[r.Begin] = true
+	}
+	view.FoldVersion++
+	cursorOutOfFolds(scope, view, moveCursor)
+}
+
+func (_ Command) FoldAll() (spec CommandSpec) {
+	spec.Desc = "fold all blocks"
+	spec.Func = FoldAll
+	return
+}
+
+func UnfoldAll(
+	cur CurrentView,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	view.FoldedLines = make(map[int]bool)
+	view.FoldVersion++
+}
+
+func (_ Command) UnfoldAll() (spec CommandSpec) {
+	spec.Desc = "unfold all blocks"
+	spec.Func = UnfoldAll
+	return
+}
+
+func ToggleAllFolds(
+	cur CurrentView,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if len(view.FoldedLines) > 0 {
+		scope.Call(UnfoldAll)
+	} else {
+		scope.Call(FoldAll)
+	}
+}
+
+func (_ Command) ToggleAllFolds() (spec CommandSpec) {
+	spec.Desc = "fold all blocks or unfold all if any folded"
+	spec.Func = ToggleAllFolds
+	return
+}
+
+func (_ Provide) Folding(
+	on On,
+) OnStartup {
+	return func() {
+
+		// keep folded lines following edits
+		on(func(
+			ev EvMomentSwitched,
+		) {
+			view := ev.View
+			if ev.Old == nil || len(view.FoldedLines) == 0 {
+				return
+			}
+			var changedLine int
+			if ev.New.Previous == ev.Old {
+				changedLine = ev.New.Change.Begin.Line
+			} else if ev.Old.Previous == ev.New {
+				changedLine = ev.Old.Change.Begin.Line
+			} else {
+				return
+			}
+			delta := ev.New.NumLines() - ev.Old.NumLines()
+			if delta == 0 {
+				return
+			}
+			folded := make(map[int]bool)
+			for line := range view.FoldedLines {
+				if line > changedLine {
+					line += delta
+					if line <= changedLine {
+						// folded block deleted
+						continue
+					}
+				}
+				folded[line] = true
+			}
+			view.FoldedLines = folded
+			view.FoldVersion++
+		})
+
+	}
+}
@@ -0,0 +1,128 @@
+package li
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndentFold(t *testing.T) {
+	withEditorBytes(t, []byte("a\n  b\n  c\n\n  d\ne\n"), func(
+		view *View,
+		scope Scope,
+		moment *Moment,
+		getScreenString GetScreenString,
+		ctrl func(string),
+	) {
+		eq(t,
+			moment.GetFoldRanges(scope), []FoldRange{{0, 4}},
+		)
+
+		scope.Call(Fold)
+		eq(t,
+			view.FoldedLines[0], true,
+		)
+		ctrl("loop")
+		lines := getScreenString(view.ContentBox)
+		eq(t,
+			strings.Contains(lines[0], "[5 lines]"), true,
+			strings.HasPrefix(lines[1], "e"), true,
+		)
+
+		scope.Call(func(move MoveCursor) {
+			move(Move{RelLine: 1})
+		})
+		eq(t,
+			view.CursorLine, 5,
+		)
+		scope.Call(func(move MoveCursor) {
+			move(Move{RelLine: -1})
+		})
+		eq(t,
+			view.CursorLine, 0,
+		)
+
+		scope.Call(ToggleFold)
+		eq(t,
+			len(view.FoldedLines), 0,
+		)
+	})
+}
+
+func TestGoFold(t *testing.T) {
+	withEditorBytes(t, []byte(`package main
+
+// foo
+// bar
+func main() {
+	if true {
+		println(42)
+	}
+}
+`), func(
+		view *View,
+		scope Scope,
+		buffer *Buffer,
+		moment *Moment,
+		moveCursor MoveCursor,
+	) {
+		buffer.SetLanguage(scope, LanguageGo)
+		eq(t,
+			moment.GetFoldRanges(scope), []FoldRange{
+				{2, 3},
+				{4, 8},
+				{5, 7},
+			},
+		)
+
+		moveCursor(Move{AbsLine: intP(6)})
+		scope.Call(Fold)
+		eq(t,
+			view.FoldedLines[5], true,
+			view.CursorLine, 5,
+		)
+
+		scope.Call(FoldAll)
+		eq(t,
+			view.CursorLine, 4,
+		)
+
+		// moving into folded lines unfolds
+		moveCursor(Move{AbsLine: intP(6)})
+		eq(t,
+			view.CursorLine, 6,
+			view.FoldedLines[4], false,
+			view.FoldedLines[5], false,
+			view.FoldedLines[2], true,
+		)
+
+		scope.Call(ToggleAllFolds)
+		eq(t,
+			len(view.FoldedLines), 0,
+		)
+	})
+}
+
+func TestFoldFollowEdits(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\n  c\n  d\n"), func(
+		view *View,
+		scope Scope,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+		moveCursor MoveCursor,
+	) {
+		moveCursor(Move{AbsLine: intP(1)})
+		scope.Call(Fold)
+		eq(t,
+			view.FoldedLines[1], true,
+		)
+		moveCursor(Move{AbsLine: intP(0)})
+		insert("x\n", PositionFunc(posCursor))
+		eq(t,
+			view.FoldedLines[2], true,
+		)
+		scope.Call(Undo)
+		eq(t,
+			view.FoldedLines[1], true,
+		)
+	})
+}
@@ -13,3 +13,11 @@ func (_ Provide) FormatterConfig(
 	ce(get(&config))
 	return config.Formatter
 }
+
+// languageFormatter returns formatter of language, nil if none
+func languageFormatter(lang Language) func(path string, src []byte) ([]byte, error) {
+	if spec := languageSpec(lang); spec != nil {
+		return spec.Format
+	}
+	return nil
+}
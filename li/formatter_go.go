@@ -10,7 +10,7 @@ import (
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
-func (_ Provide) FormatterGo(
+func (_ Provide) AutoFormat(
 	on On,
 	j AppendJournal,
 	run RunInMainLoop,
@@ -22,6 +22,7 @@ func (_ Provide) FormatterGo(
 			view   *View
 			buffer *Buffer
 			moment *Moment
+			format func(path string, src []byte) ([]byte, error)
 		}
 
 		c := make(chan Job, 512)
@@ -33,7 +34,7 @@ func (_ Provide) FormatterGo(
 
 					src := job.moment.GetBytes()
 					src = bytes.TrimRight(src, "\n")
-					formatted, err := formatGoSource(job.buffer.AbsPath, src)
+					formatted, err := job.format(job.buffer.AbsPath, src)
 					if err != nil {
 						// do nothing if format error
 						continue
@@ -128,12 +129,16 @@ func (_ Provide) FormatterGo(
 			}()
 		}
 
-		// formatter for go
+		// formatters of languages
 		on(func(
 			ev EvMomentSwitched,
 			curModes CurrentModes,
 		) {
-			if ev.Buffer.language != LanguageGo {
+			format := languageFormatter(ev.Buffer.language)
+			if format == nil {
+				return
+			}
+			if ev.View.Timeline != nil {
 				return
 			}
 			if IsEditing(curModes()) {
@@ -143,6 +148,7 @@ func (_ Provide) FormatterGo(
 				view:   ev.View,
 				buffer: ev.Buffer,
 				moment: ev.New,
+				format: format,
 			}
 		})
 
@@ -155,7 +161,11 @@ func (_ Provide) FormatterGo(
 				return
 			}
 			buffer := view.Buffer
-			if buffer.language != LanguageGo {
+			format := languageFormatter(buffer.language)
+			if format == nil {
+				return
+			}
+			if view.Timeline != nil {
 				return
 			}
 			if IsEditing(ev.Modes) {
@@ -165,6 +175,7 @@ func (_ Provide) FormatterGo(
 				view:   view,
 				buffer: buffer,
 				moment: view.GetMoment(),
+				format: format,
 			}
 		})
 
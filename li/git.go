@@ -0,0 +1,534 @@
+package li
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GitConfig struct {
+	Command        string
+	Gutter         bool
+	TimeoutSeconds int
+}
+
+func (_ Provide) GitConfig(
+	get GetConfig,
+) GitConfig {
+	var config struct {
+		Git GitConfig
+	}
+	config.Git.Command = "git"
+	config.Git.Gutter = true
+	config.Git.TimeoutSeconds = 10
+	ce(get(&config))
+	return config.Git
+}
+
+type GitMark uint8
+
+const (
+	GitMarkNone GitMark = iota
+	GitMarkAdded
+	GitMarkModified
+	GitMarkDeleted // lines deleted at this line
+)
+
+type GitBuffer struct {
+	Tracked bool
+	Head    []string // lines at HEAD
+
+	// marks of Moment against Head
+	Moment  *Moment
+	Marks   map[int]GitMark
+	Hunks   []LineHunk // A is Head, B is Moment
+	Version int
+
+	Blame       bool
+	BlameMoment *Moment
+	BlameLines  []string
+
+	latest      *Moment
+	syncInfo    FileInfo
+	loadingHead bool
+	headSerial  int
+	marking     bool
+	blaming     bool
+}
+
+type GitBuffers map[*Buffer]*GitBuffer
+
+func (_ Provide) GitBuffers() GitBuffers {
+	return make(GitBuffers)
+}
+
+type SetGitBlame func(
+	buffer *Buffer,
+	enable bool,
+)
+
+func runGit(
+	config GitConfig,
+	dir string,
+	stdin string,
+	args ...string,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Second*time.Duration(config.TimeoutSeconds),
+	)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.Command, args...)
+	cmd.Dir = dir
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// gitMarks computes gutter marks and hunks of moment against head lines
+func gitMarks(head []string, moment *Moment) (marks map[int]GitMark, hunks []LineHunk) {
+	marks = make(map[int]GitMark)
+	hunks = lineHunks(diffLines(head, linesText(moment, 0, moment.NumLines()-1)))
+	for _, hunk := range hunks {
+		numDeleted := hunk.AEnd - hunk.ABegin
+		if hunk.BBegin == hunk.BEnd {
+			marks[gitHunkLine(hunk, moment)] = GitMarkDeleted
+			continue
+		}
+		for line := hunk.BBegin; line < hunk.BEnd; line++ {
+			if line-hunk.BBegin < numDeleted {
+				marks[line] = GitMarkModified
+			} else {
+				marks[line] = GitMarkAdded
+			}
+		}
+	}
+	return
+}
+
+// gitHunkLine returns the first line of hunk in moment
+func gitHunkLine(hunk LineHunk, moment *Moment) int {
+	line := hunk.BBegin
+	if line >= moment.NumLines() {
+		line = moment.NumLines() - 1
+	}
+	return line
+}
+
+type gitBlameLine struct {
+	Commit  string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// parseGitBlame parses output of git blame --porcelain
+func parseGitBlame(output string) (lines []gitBlameLine) {
+	commits := make(map[string]*gitBlameLine)
+	var current *gitBlameLine
+	var finalLine int
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "\t") {
+			// content line
+			for len(lines) <= finalLine {
+				lines = append(lines, gitBlameLine{})
+			}
+			if current != nil {
+				lines[finalLine] = *current
+			}
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields[0]) == 40 && len(fields) == 2 {
+			// header
+			nums := strings.Fields(fields[1])
+			if len(nums) >= 2 {
+				n, err := strconv.Atoi(nums[1])
+				if err == nil {
+					finalLine = n - 1
+				}
+			}
+			commit, ok := commits[fields[0]]
+			if !ok {
+				commit = &gitBlameLine{
+					Commit: fields[0][:8],
+				}
+				commits[fields[0]] = commit
+			}
+			current = commit
+			continue
+		}
+		if current == nil || len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "author":
+			current.Author = fields[1]
+		case "author-time":
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err == nil {
+				current.Time = time.Unix(sec, 0)
+			}
+		case "summary":
+			current.Summary = fields[1]
+		}
+	}
+	return
+}
+
+func (b gitBlameLine) String() string {
+	if strings.Trim(b.Commit, "0") == "" {
+		return "not committed yet"
+	}
+	return fmt.Sprintf(
+		"%s %s %s %s",
+		b.Commit,
+		b.Time.Format("2006-01-02"),
+		b.Author,
+		b.Summary,
+	)
+}
+
+func (_ Provide) Git(
+	config GitConfig,
+	run RunInMainLoop,
+	on On,
+	j AppendJournal,
+	buffers GitBuffers,
+) (
+	setBlame SetGitBlame,
+	startup OnStartup,
+) {
+
+	var updateMarks func(state *GitBuffer)
+	updateMarks = func(state *GitBuffer) {
+		if !state.Tracked || !config.Gutter {
+			return
+		}
+		if state.marking {
+			return
+		}
+		moment := state.latest
+		if moment == nil || moment == state.Moment {
+			return
+		}
+		state.marking = true
+		head := state.Head
+		serial := state.headSerial
+		go func() {
+			marks, hunks := gitMarks(head, moment)
+			run(func() {
+				state.marking = false
+				if state.Tracked && serial == state.headSerial {
+					state.Moment = moment
+					state.Marks = marks
+					state.Hunks = hunks
+					state.Version++
+				}
+				// changed while marking
+				updateMarks(state)
+			})
+		}()
+	}
+
+	loadHead := func(buffer *Buffer, state *GitBuffer) {
+		if state.loadingHead {
+			return
+		}
+		state.loadingHead = true
+		state.syncInfo = buffer.LastSyncFileInfo
+		dir := buffer.AbsDir
+		name := "HEAD:./" + filepath.Base(buffer.AbsPath)
+		go func() {
+			output, err := runGit(config, dir, "", "show", name)
+			run(func() {
+				state.loadingHead = false
+				state.headSerial++
+				if err != nil {
+					state.Tracked = false
+					state.Moment = nil
+					state.Marks = nil
+					state.Hunks = nil
+					state.Version++
+					return
+				}
+				state.Tracked = true
+				state.Head = contentLines(output)
+				state.Moment = nil
+				updateMarks(state)
+			})
+		}()
+	}
+
+	var blame func(buffer *Buffer, state *GitBuffer)
+	blame = func(buffer *Buffer, state *GitBuffer) {
+		if state.blaming {
+			return
+		}
+		moment := state.latest
+		if moment == nil || moment == state.BlameMoment {
+			return
+		}
+		state.blaming = true
+		dir := buffer.AbsDir
+		name := filepath.Base(buffer.AbsPath)
+		content := moment.GetContent()
+		go func() {
+			output, err := runGit(config, dir, content, "blame", "--porcelain", "--contents", "-", "--", name)
+			run(func(
+				show ShowMessage,
+			) {
+				state.blaming = false
+				if err != nil {
+					j("git blame: %s", err.Error())
+					show([]string{"git blame: " + err.Error()})
+					state.Blame = false
+					return
+				}
+				var lines []string
+				for _, line := range parseGitBlame(output) {
+					lines = append(lines, line.String())
+				}
+				state.BlameMoment = moment
+				state.BlameLines = lines
+				if state.Blame && state.latest != moment {
+					// changed while blaming
+					blame(buffer, state)
+				}
+			})
+		}()
+	}
+
+	setBlame = func(buffer *Buffer, enable bool) {
+		state, ok := buffers[buffer]
+		if !ok {
+			return
+		}
+		state.Blame = enable
+		if enable {
+			blame(buffer, state)
+		}
+	}
+
+	startup = func() {
+
+		on(func(
+			ev EvMomentSwitched,
+		) {
+			buffer := ev.Buffer
+			if buffer.Path == "" {
+				return
+			}
+			state, ok := buffers[buffer]
+			if !ok {
+				state = new(GitBuffer)
+				buffers[buffer] = state
+			}
+			state.latest = ev.New
+			if !ok || buffer.LastSyncFileInfo != state.syncInfo {
+				// new buffer or saved
+				loadHead(buffer, state)
+			} else {
+				updateMarks(state)
+			}
+			if state.Blame {
+				blame(buffer, state)
+			}
+		})
+
+		on(func(
+			ev EvCollectLineHints,
+			views Views,
+		) {
+			for _, view := range views {
+				state, ok := buffers[view.Buffer]
+				if !ok || !state.Blame {
+					continue
+				}
+				moment := view.GetMoment()
+				if moment != state.BlameMoment {
+					continue
+				}
+				for line := view.ViewportLine; line < view.ViewportLine+view.Box.Height(); line++ {
+					if line >= len(state.BlameLines) {
+						break
+					}
+					ev.Add(moment, line, []string{state.BlameLines[line]})
+				}
+			}
+		})
+
+		on(func(
+			ev EvCollectStatusSections,
+			cur CurrentView,
+		) {
+			view := cur()
+			if view == nil {
+				return
+			}
+			state, ok := buffers[view.Buffer]
+			if !ok || !state.Tracked || state.Moment != view.GetMoment() || len(state.Hunks) == 0 {
+				return
+			}
+			ev.Add("git", [][]any{
+				{fmt.Sprintf("%d hunks", len(state.Hunks)), AlignRight, Padding(0, 2, 0, 0)},
+			})
+		})
+
+		on(func(
+			ev EvViewClosed,
+			views Views,
+		) {
+			for _, view := range views {
+				if view.Buffer == ev.View.Buffer {
+					return
+				}
+			}
+			delete(buffers, ev.View.Buffer)
+		})
+
+	}
+
+	return
+}
+
+// currentGitBuffer returns git states of current view, marks are up to date
+func currentGitBuffer(
+	cur CurrentView,
+	buffers GitBuffers,
+) (*View, *GitBuffer) {
+	view := cur()
+	if view == nil {
+		return nil, nil
+	}
+	state, ok := buffers[view.Buffer]
+	if !ok || !state.Tracked || state.Moment != view.GetMoment() {
+		return view, nil
+	}
+	return view, state
+}
+
+func NextGitHunk(
+	cur CurrentView,
+	buffers GitBuffers,
+	moveCursor MoveCursor,
+) {
+	view, state := currentGitBuffer(cur, buffers)
+	if state == nil {
+		return
+	}
+	for _, hunk := range state.Hunks {
+		if line := gitHunkLine(hunk, state.Moment); line > view.CursorLine {
+			moveCursor(Move{AbsLine: intP(line), AbsCol: intP(0)})
+			return
+		}
+	}
+}
+
+func (_ Command) NextGitHunk() (spec CommandSpec) {
+	spec.Desc = "move cursor to next hunk changed from git HEAD"
+	spec.Func = NextGitHunk
+	return
+}
+
+func PrevGitHunk(
+	cur CurrentView,
+	buffers GitBuffers,
+	moveCursor MoveCursor,
+) {
+	view, state := currentGitBuffer(cur, buffers)
+	if state == nil {
+		return
+	}
+	for i := len(state.Hunks) - 1; i >= 0; i-- {
+		if line := gitHunkLine(state.Hunks[i], state.Moment); line < view.CursorLine {
+			moveCursor(Move{AbsLine: intP(line), AbsCol: intP(0)})
+			return
+		}
+	}
+}
+
+func (_ Command) PrevGitHunk() (spec CommandSpec) {
+	spec.Desc = "move cursor to previous hunk changed from git HEAD"
+	spec.Func = PrevGitHunk
+	return
+}
+
+func RevertGitHunk(
+	cur CurrentView,
+	buffers GitBuffers,
+	apply ApplyChange,
+	scope Scope,
+	moveCursor MoveCursor,
+) {
+	view, state := currentGitBuffer(cur, buffers)
+	if state == nil {
+		return
+	}
+	moment := state.Moment
+	for _, hunk := range state.Hunks {
+		begin := gitHunkLine(hunk, moment)
+		end := hunk.BEnd
+		if end <= begin {
+			end = begin + 1
+		}
+		if view.CursorLine < begin || view.CursorLine >= end {
+			continue
+		}
+		newMoment := replaceMomentLines(
+			apply,
+			moment,
+			hunk.BBegin,
+			hunk.BEnd,
+			state.Head[hunk.ABegin:hunk.AEnd],
+		)
+		view.switchMoment(scope, newMoment)
+		moveCursor(Move{AbsLine: intP(hunk.BBegin), AbsCol: intP(0)})
+		return
+	}
+}
+
+func (_ Command) RevertGitHunk() (spec CommandSpec) {
+	spec.Desc = "revert hunk under cursor to git HEAD"
+	spec.Func = RevertGitHunk
+	return
+}
+
+func ToggleGitBlame(
+	cur CurrentView,
+	buffers GitBuffers,
+	setBlame SetGitBlame,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	state, ok := buffers[view.Buffer]
+	if !ok {
+		return
+	}
+	setBlame(view.Buffer, !state.Blame)
+}
+
+func (_ Command) ToggleGitBlame() (spec CommandSpec) {
+	spec.Desc = "toggle git blame hints of current buffer"
+	spec.Func = ToggleGitBlame
+	return
+}
@@ -0,0 +1,171 @@
+package li
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitMarks(t *testing.T) {
+	withEditorBytes(t, []byte("a\nB\nc\nnew\ne\n"), func(
+		moment *Moment,
+	) {
+		marks, hunks := gitMarks([]string{"a", "b", "c", "d", "e", "f"}, moment)
+		eq(t,
+			len(hunks), 3,
+			marks[0], GitMarkNone,
+			marks[1], GitMarkModified,
+			marks[3], GitMarkModified,
+			marks[4], GitMarkDeleted,
+		)
+	})
+}
+
+func TestParseGitBlame(t *testing.T) {
+	lines := parseGitBlame(strings.Join([]string{
+		"0123456789012345678901234567890123456789 1 1 2",
+		"author foo",
+		"author-time 1600000000",
+		"summary init",
+		"\ta",
+		"0123456789012345678901234567890123456789 2 2",
+		"\tb",
+		"0000000000000000000000000000000000000000 3 3 1",
+		"author Not Committed Yet",
+		"summary Version of - from -",
+		"\tc",
+	}, "\n"))
+	eq(t,
+		len(lines), 3,
+		lines[1].Author, "foo",
+		strings.HasSuffix(lines[0].String(), "foo init"), true,
+		lines[2].String(), "not committed yet",
+	)
+}
+
+func TestGitGutter(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	ce(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "foo.txt")
+	ce(ioutil.WriteFile(path, []byte("a\nb\nc\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "foo.txt"},
+		{"-c", "user.name=foo", "-c", "user.email=foo@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %s", err, out)
+		}
+	}
+
+	withEditor(func(
+		scope Scope,
+		newBuffer NewBufferFromFile,
+		newView NewViewFromBuffer,
+		buffers GitBuffers,
+		ctrl func(string),
+		apply ApplyChange,
+		trigger Trigger,
+	) {
+		buffer, err := newBuffer(path)
+		ce(err)
+		view, err := newView(buffer)
+		ce(err)
+		wait := func(fn func() bool) {
+			deadline := time.Now().Add(time.Second * 5)
+			for !fn() && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond * 10)
+				ctrl("loop")
+			}
+		}
+		wait(func() bool {
+			state, ok := buffers[buffer]
+			return ok && state.Tracked && state.Moment == view.GetMoment()
+		})
+		state := buffers[buffer]
+		eq(t,
+			state.Moment == view.GetMoment(), true,
+			len(state.Hunks), 0,
+		)
+
+		// edit
+		moment := replaceMomentLines(apply, view.GetMoment(), 1, 2, []string{"B", "x"})
+		view.switchMoment(scope, moment)
+		eq(t,
+			state.Moment == moment, false,
+		)
+		wait(func() bool {
+			return state.Moment == moment
+		})
+		eq(t,
+			len(state.Hunks), 1,
+			state.Marks[1], GitMarkModified,
+			state.Marks[2], GitMarkAdded,
+		)
+
+		// navigation
+		scope.Call(NextGitHunk)
+		eq(t,
+			view.CursorLine, 1,
+		)
+		scope.Call(PrevGitHunk)
+		eq(t,
+			view.CursorLine, 1,
+		)
+
+		// blame
+		scope.Call(ToggleGitBlame)
+		wait(func() bool {
+			return state.BlameMoment == moment
+		})
+		eq(t,
+			len(state.BlameLines), 4,
+			strings.Contains(state.BlameLines[0], "foo init"), true,
+			state.BlameLines[1], "not committed yet",
+		)
+		var hints []string
+		trigger(EvCollectLineHints{
+			Add: func(m *Moment, line int, strs []string) {
+				if m == moment && line == 1 {
+					hints = append(hints, strs...)
+				}
+			},
+		})
+		eq(t,
+			len(hints), 1,
+			hints[0], "not committed yet",
+		)
+		scope.Call(ToggleGitBlame)
+
+		// revert
+		scope.Call(RevertGitHunk)
+		wait(func() bool {
+			return state.Moment == view.GetMoment()
+		})
+		eq(t,
+			view.GetMoment().GetContent(), "a\nb\nc\n",
+			len(state.Hunks), 0,
+		)
+
+		// close
+		scope.Call(func(closeView CloseView) {
+			closeView()
+		})
+		_, ok := buffers[view.Buffer]
+		eq(t,
+			ok, false,
+		)
+	})
+}
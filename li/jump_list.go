@@ -0,0 +1,121 @@
+package li
+
+import "strings"
+
+type Jump struct {
+	Path     string
+	Position Position
+}
+
+const maxJumps = 100
+
+type JumpList struct {
+	Jumps []Jump
+	Index int // len(Jumps) if not navigating
+}
+
+func (_ Provide) JumpList() *JumpList {
+	return new(JumpList)
+}
+
+// Push records position before jumping, discarding forward jumps
+func (l *JumpList) Push(jump Jump) {
+	l.Jumps = append(l.Jumps[:l.Index], jump)
+	if len(l.Jumps) > maxJumps {
+		l.Jumps = l.Jumps[len(l.Jumps)-maxJumps:]
+	}
+	l.Index = len(l.Jumps)
+}
+
+// Back returns previous jump, current is recorded to go forward to
+func (l *JumpList) Back(current Jump) (jump Jump, ok bool) {
+	if l.Index == 0 {
+		return
+	}
+	if l.Index == len(l.Jumps) {
+		l.Jumps = append(l.Jumps, current)
+	}
+	l.Index--
+	return l.Jumps[l.Index], true
+}
+
+func (l *JumpList) Forward() (jump Jump, ok bool) {
+	if l.Index+1 >= len(l.Jumps) {
+		return
+	}
+	l.Index++
+	return l.Jumps[l.Index], true
+}
+
+// viewJump returns jump of cursor position in view
+func viewJump(view *View) (jump Jump, ok bool) {
+	if view == nil || view.Buffer.Path == "" {
+		return
+	}
+	pos := view.cursorPosition()
+	if pos.Line < 0 {
+		pos = Position{Line: view.CursorLine}
+	}
+	return Jump{
+		Path:     view.Buffer.AbsPath,
+		Position: pos,
+	}, true
+}
+
+type PushJump func()
+
+func (_ Provide) PushJump(
+	cur CurrentView,
+	jumps *JumpList,
+) PushJump {
+	return func() {
+		if jump, ok := viewJump(cur()); ok {
+			jumps.Push(jump)
+		}
+	}
+}
+
+func JumpBack(
+	cur CurrentView,
+	jumps *JumpList,
+	showPosition ShowPathPosition,
+	show ShowMessage,
+) {
+	current, ok := viewJump(cur())
+	if !ok {
+		return
+	}
+	jump, ok := jumps.Back(current)
+	if !ok {
+		return
+	}
+	if _, err := showPosition(jump.Path, jump.Position); err != nil {
+		show(strings.Split(err.Error(), "\n"))
+	}
+}
+
+func (_ Command) JumpBack() (spec CommandSpec) {
+	spec.Desc = "go back to position before last jump"
+	spec.Func = JumpBack
+	return
+}
+
+func JumpForward(
+	jumps *JumpList,
+	showPosition ShowPathPosition,
+	show ShowMessage,
+) {
+	jump, ok := jumps.Forward()
+	if !ok {
+		return
+	}
+	if _, err := showPosition(jump.Path, jump.Position); err != nil {
+		show(strings.Split(err.Error(), "\n"))
+	}
+}
+
+func (_ Command) JumpForward() (spec CommandSpec) {
+	spec.Desc = "go forward in jump list"
+	spec.Func = JumpForward
+	return
+}
@@ -0,0 +1,55 @@
+package li
+
+import "testing"
+
+func TestJumpList(t *testing.T) {
+	l := new(JumpList)
+	jump := func(line int) Jump {
+		return Jump{
+			Path:     "foo",
+			Position: Position{Line: line},
+		}
+	}
+	l.Push(jump(1))
+	l.Push(jump(2))
+
+	j, ok := l.Back(jump(3))
+	eq(t,
+		ok, true,
+		j.Position.Line, 2,
+	)
+	j, ok = l.Back(jump(2))
+	eq(t,
+		ok, true,
+		j.Position.Line, 1,
+	)
+	_, ok = l.Back(jump(1))
+	eq(t,
+		ok, false,
+	)
+
+	j, ok = l.Forward()
+	eq(t,
+		ok, true,
+		j.Position.Line, 2,
+	)
+	j, ok = l.Forward()
+	eq(t,
+		ok, true,
+		j.Position.Line, 3,
+	)
+	_, ok = l.Forward()
+	eq(t,
+		ok, false,
+	)
+
+	// push discards forward jumps
+	l.Back(jump(3))
+	l.Push(jump(5))
+	_, ok = l.Forward()
+	eq(t,
+		ok, false,
+		len(l.Jumps), 2,
+		l.Jumps[1].Position.Line, 5,
+	)
+}
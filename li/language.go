@@ -1,9 +1,14 @@
 package li
 
 import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"unsafe"
 
+	"github.com/junegunn/fzf/src/util"
 	"github.com/reusee/dscope"
 	"github.com/reusee/li/treesitter"
 )
@@ -13,22 +18,217 @@ type Language int
 const (
 	LanguageUnknown Language = iota
 	LanguageGo
+	LanguageJSON
+	LanguageYAML
+	LanguageMarkdown
+	LanguagePython
+	LanguageC
+	LanguageShell
 )
 
-func LanguageFromPath(path string) Language {
-	if strings.HasSuffix(strings.ToLower(path), ".go") {
-		return LanguageGo
+func (l Language) String() string {
+	if l == LanguageUnknown {
+		return "LanguageUnknown"
 	}
-	return LanguageUnknown
+	if spec := languageSpec(l); spec != nil {
+		return "Language" + spec.Name
+	}
+	return "Language(" + strconv.Itoa(int(l)) + ")"
+}
+
+// LanguageSpec describes detection and support of a language
+type LanguageSpec struct {
+	Language Language
+	Name     string // in config keys, modelines and SetBufferLanguage
+
+	Aliases    []string // other names in modelines, lower case
+	Extensions []string // lower case, with the dot
+	FileNames  []string
+	Shebangs   []string // interpreter names without version
+
+	Parse         func(*Moment) *treesitter.Parser // nil if no grammar
+	Stainer       any                              // func returning lexical Stainer, called with editor scope
+	FoldNodeTypes map[string]bool
+
+	LineComment  string
+	BlockComment [2]string
+
+	Format        func(path string, src []byte) ([]byte, error) // nil if no formatter
+	LSPLanguageID string
+}
+
+var (
+	languageSpecs        []*LanguageSpec // in registration order
+	languageSpecsByValue = make(map[Language]*LanguageSpec)
+)
+
+// RegisterLanguage adds spec to the registry, replacing the one of the same Language.
+// A new Language is allocated if spec.Language is LanguageUnknown.
+// Should be called before editor started
+func RegisterLanguage(spec LanguageSpec) Language {
+	if spec.Language == LanguageUnknown {
+		spec.Language = LanguageUnknown + 1
+		for lang := range languageSpecsByValue {
+			if lang >= spec.Language {
+				spec.Language = lang + 1
+			}
+		}
+	}
+	if old, ok := languageSpecsByValue[spec.Language]; ok {
+		*old = spec
+		return spec.Language
+	}
+	languageSpecs = append(languageSpecs, &spec)
+	languageSpecsByValue[spec.Language] = &spec
+	return spec.Language
+}
+
+func languageSpec(lang Language) *LanguageSpec {
+	return languageSpecsByValue[lang]
+}
+
+// languageParser returns tree-sitter parse func of language, nil if not supported
+func languageParser(lang Language) func(*Moment) *treesitter.Parser {
+	if spec := languageSpec(lang); spec != nil {
+		return spec.Parse
+	}
+	return nil
 }
 
-var languageParsers = map[Language]func(*Moment) *treesitter.Parser{
-	LanguageGo: func(m *Moment) *treesitter.Parser {
-		return treesitter.ParseGo(
+func parseMoment(parse func(src unsafe.Pointer, l int) *treesitter.Parser) func(*Moment) *treesitter.Parser {
+	return func(m *Moment) *treesitter.Parser {
+		return parse(
 			unsafe.Pointer(m.GetCStringContent()),
 			len(m.GetContent()),
 		)
-	},
+	}
+}
+
+// languageByName returns language of name or alias, case insensitive
+func languageByName(name string) Language {
+	name = strings.ToLower(name)
+	for _, spec := range languageSpecs {
+		if strings.ToLower(spec.Name) == name {
+			return spec.Language
+		}
+		for _, alias := range spec.Aliases {
+			if alias == name {
+				return spec.Language
+			}
+		}
+	}
+	return LanguageUnknown
+}
+
+// LanguageFromPath detects language by file name and extension
+func LanguageFromPath(path string) Language {
+	base := filepath.Base(path)
+	for _, spec := range languageSpecs {
+		for _, name := range spec.FileNames {
+			if base == name {
+				return spec.Language
+			}
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	if ext == "" {
+		return LanguageUnknown
+	}
+	for _, spec := range languageSpecs {
+		for _, e := range spec.Extensions {
+			if ext == e {
+				return spec.Language
+			}
+		}
+	}
+	return LanguageUnknown
+}
+
+// DetectLanguage detects language by modelines, path and shebang, in that order
+func DetectLanguage(path string, moment *Moment) Language {
+	if lang := languageFromModeline(moment); lang != LanguageUnknown {
+		return lang
+	}
+	if lang := LanguageFromPath(path); lang != LanguageUnknown {
+		return lang
+	}
+	return languageFromShebang(moment)
+}
+
+const modelineLines = 5
+
+var (
+	vimModelinePattern   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):(?:.*?[\s:])?(?:ft|filetype|syntax)=([\w+-]+)`)
+	emacsModelinePattern = regexp.MustCompile(`-\*-(.*?)-\*-`)
+)
+
+// languageFromModeline detects language by vim or emacs modelines in the first or last lines
+func languageFromModeline(moment *Moment) Language {
+	n := moment.NumLines()
+	for i := 0; i < n; i++ {
+		if i == modelineLines && n-modelineLines > i {
+			i = n - modelineLines
+		}
+		line := moment.GetLine(i)
+		if line == nil {
+			break
+		}
+		if m := vimModelinePattern.FindStringSubmatch(line.content); m != nil {
+			if lang := languageByName(m[1]); lang != LanguageUnknown {
+				return lang
+			}
+		}
+		if m := emacsModelinePattern.FindStringSubmatch(line.content); m != nil {
+			name := strings.TrimSpace(m[1])
+			if strings.Contains(name, ":") {
+				// -*- mode: python; coding: utf-8 -*-
+				name = ""
+				for _, part := range strings.Split(m[1], ";") {
+					kv := strings.SplitN(part, ":", 2)
+					if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "mode" {
+						name = strings.TrimSpace(kv[1])
+					}
+				}
+			}
+			if lang := languageByName(name); lang != LanguageUnknown {
+				return lang
+			}
+		}
+	}
+	return LanguageUnknown
+}
+
+// languageFromShebang detects language by interpreter in the first line
+func languageFromShebang(moment *Moment) Language {
+	line := moment.GetLine(0)
+	if line == nil || !strings.HasPrefix(line.content, "#!") {
+		return LanguageUnknown
+	}
+	fields := strings.Fields(line.content[2:])
+	if len(fields) == 0 {
+		return LanguageUnknown
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// #!/usr/bin/env -S python3 -u
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	// python3.9 -> python
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	for _, spec := range languageSpecs {
+		for _, shebang := range spec.Shebangs {
+			if interpreter == shebang {
+				return spec.Language
+			}
+		}
+	}
+	return LanguageUnknown
 }
 
 type LanguageStainers map[Language]func() Stainer
@@ -38,11 +238,160 @@ var _ dscope.Reducer = LanguageStainers{}
 func (_ LanguageStainers) IsReducer() {}
 
 func (_ Provide) DefaultLanguageStainers(
-	newGo NewGoLexicalStainer,
+	scope Scope,
 ) LanguageStainers {
-	return LanguageStainers{
-		LanguageGo: func() Stainer {
-			return newGo()
+	stainers := make(LanguageStainers)
+	for _, spec := range languageSpecs {
+		if spec.Stainer == nil {
+			continue
+		}
+		newStainer := spec.Stainer
+		stainers[spec.Language] = func() (stainer Stainer) {
+			scope.Call(newStainer).Assign(&stainer)
+			return
+		}
+	}
+	return stainers
+}
+
+// lexicalStainer returns stainer of language, NoopStainer if not supported
+func lexicalStainer(stainers LanguageStainers, lang Language) Stainer {
+	if fn, ok := stainers[lang]; ok {
+		return fn()
+	}
+	return new(NoopStainer)
+}
+
+func (_ Provide) LanguageStainerUpdate(
+	on On,
+) OnStartup {
+	return func() {
+		// restain views of buffer
+		on(func(
+			ev EvBufferLanguageChanged,
+			views Views,
+			stainers LanguageStainers,
+		) {
+			for _, view := range views {
+				if view.Buffer != ev.Buffer {
+					continue
+				}
+				view.Stainer = &SemanticTokensStainer{
+					Buffer:  ev.Buffer,
+					Lexical: lexicalStainer(stainers, ev.NewLang),
+				}
+			}
+		})
+	}
+}
+
+func SetBufferLanguage(
+	cur CurrentView,
+	scope Scope,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	buffer := view.Buffer
+
+	type Candidate struct {
+		Name     string
+		Language Language
+		MatchLen int
+		Score    int
+	}
+	var candidates []Candidate
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: "Set Buffer Language",
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(candidates) {
+				return
+			}
+			buffer.SetLanguage(scope, candidates[i].Language)
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			candidates = candidates[:0]
+			add := func(name string, lang Language) {
+				chars := util.RunesToChars([]rune(name))
+				matched, matchLen, score := fuzzyMatched(runes, &chars)
+				if !matched {
+					return
+				}
+				candidates = append(candidates, Candidate{
+					Name:     name,
+					Language: lang,
+					MatchLen: matchLen,
+					Score:    score,
+				})
+			}
+			for _, spec := range languageSpecs {
+				add(spec.Name, spec.Language)
+			}
+			add("None", LanguageUnknown)
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return candidates[i].Score > candidates[j].Score
+			})
+
+			for i, candidate := range candidates {
+				if w := displayWidth(candidate.Name); w > maxLen {
+					maxLen = w
+				}
+				if candidate.Language == buffer.language && len(runes) == 0 {
+					initIndex = i
+				}
+				ids = append(ids, ID(i))
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			var getStyle GetStyle
+			scope.Assign(&box, &focus, &style, &getStyle)
+			s := style
+			if i == focus {
+				hlStyle := getStyle("Highlight")(s)
+				fg, _, _ := hlStyle.Decompose()
+				s = s.Foreground(fg)
+			}
+			candidate := candidates[i]
+			return Text(
+				box,
+				candidate.Name,
+				s,
+				OffsetStyleFunc(func(i int) StyleFunc {
+					fn := SameStyle
+					if i < candidate.MatchLen {
+						fn = fn.SetUnderline(true)
+					} else {
+						fn = fn.SetUnderline(false)
+					}
+					return fn
+				}),
+			)
 		},
 	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) SetBufferLanguage() (spec CommandSpec) {
+	spec.Desc = "set language of current buffer"
+	spec.Func = SetBufferLanguage
+	return
 }
@@ -0,0 +1,215 @@
+package li
+
+import "github.com/reusee/li/treesitter"
+
+func init() {
+	for _, spec := range builtinLanguages {
+		RegisterLanguage(spec)
+	}
+}
+
+var builtinLanguages = []LanguageSpec{
+
+	{
+		Language:   LanguageGo,
+		Name:       "Go",
+		Aliases:    []string{"golang"},
+		Extensions: []string{".go"},
+		Parse:      parseMoment(treesitter.ParseGo),
+		Stainer: func(newGo NewGoLexicalStainer) Stainer {
+			return newGo()
+		},
+		FoldNodeTypes: map[string]bool{
+			"function_declaration":        true,
+			"method_declaration":          true,
+			"func_literal":                true,
+			"block":                       true,
+			"composite_literal":           true,
+			"import_declaration":          true,
+			"const_declaration":           true,
+			"var_declaration":             true,
+			"type_declaration":            true,
+			"field_declaration_list":      true,
+			"interface_type":              true,
+			"expression_switch_statement": true,
+			"type_switch_statement":       true,
+			"select_statement":            true,
+		},
+		LineComment:   "//",
+		BlockComment:  [2]string{"/*", "*/"},
+		Format:        formatGoSource,
+		LSPLanguageID: "go",
+	},
+
+	{
+		Language:   LanguageJSON,
+		Name:       "JSON",
+		Extensions: []string{".json"},
+		Parse:      parseMoment(treesitter.ParseJSON),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxKeyword: {"true", "false", "null"},
+			SyntaxLiteral: {"string_content", "\"", "number", "escape_sequence"},
+			SyntaxComment: {"comment"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"object": true,
+			"array":  true,
+		},
+		LSPLanguageID: "json",
+	},
+
+	{
+		Language:   LanguageYAML,
+		Name:       "YAML",
+		Aliases:    []string{"yml"},
+		Extensions: []string{".yaml", ".yml"},
+		Parse:      parseMoment(treesitter.ParseYAML),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxType: {"tag", "anchor", "anchor_name", "alias", "alias_name"},
+			SyntaxLiteral: {
+				"double_quote_scalar", "single_quote_scalar", "block_scalar",
+				"integer_scalar", "float_scalar", "boolean_scalar", "null_scalar",
+				"escape_sequence",
+			},
+			SyntaxComment: {"comment"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"block_mapping_pair":  true,
+			"block_sequence_item": true,
+			"flow_mapping":        true,
+			"flow_sequence":       true,
+		},
+		LineComment:   "#",
+		LSPLanguageID: "yaml",
+	},
+
+	{
+		// block structure only, inline contents are not parsed
+		Language:   LanguageMarkdown,
+		Name:       "Markdown",
+		Aliases:    []string{"md"},
+		Extensions: []string{".md", ".markdown"},
+		Parse:      parseMoment(treesitter.ParseMarkdown),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxKeyword: {
+				"atx_h1_marker", "atx_h2_marker", "atx_h3_marker",
+				"atx_h4_marker", "atx_h5_marker", "atx_h6_marker",
+				"setext_h1_underline", "setext_h2_underline",
+			},
+			SyntaxType:    {"fenced_code_block_delimiter", "info_string", "language"},
+			SyntaxLiteral: {"code_fence_content", "indented_code_block"},
+			SyntaxBuiltin: {
+				"list_marker_minus", "list_marker_plus", "list_marker_star",
+				"list_marker_dot", "list_marker_parenthesis",
+				"block_quote_marker", "thematic_break",
+			},
+			SyntaxComment: {"html_block"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"section":           true,
+			"fenced_code_block": true,
+			"list":              true,
+			"block_quote":       true,
+		},
+		BlockComment:  [2]string{"<!--", "-->"},
+		LSPLanguageID: "markdown",
+	},
+
+	{
+		Language:   LanguagePython,
+		Name:       "Python",
+		Aliases:    []string{"py", "python3"},
+		Extensions: []string{".py", ".pyi", ".pyw"},
+		Shebangs:   []string{"python"},
+		Parse:      parseMoment(treesitter.ParsePython),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxKeyword: {
+				"and", "as", "assert", "async", "await", "break", "class", "continue",
+				"def", "del", "elif", "else", "except", "exec", "finally", "for", "from",
+				"global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or",
+				"pass", "print", "raise", "return", "try", "while", "with", "yield",
+			},
+			SyntaxLiteral: {
+				"string", "\"", "integer", "float", "true", "false", "none",
+				"escape_sequence",
+			},
+			SyntaxComment: {"comment"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"function_definition": true,
+			"class_definition":    true,
+			"if_statement":        true,
+			"for_statement":       true,
+			"while_statement":     true,
+			"try_statement":       true,
+			"with_statement":      true,
+			"dictionary":          true,
+			"list":                true,
+		},
+		LineComment:   "#",
+		LSPLanguageID: "python",
+	},
+
+	{
+		Language:   LanguageC,
+		Name:       "C",
+		Extensions: []string{".c", ".h"},
+		Parse:      parseMoment(treesitter.ParseC),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxKeyword: {
+				"auto", "break", "case", "const", "continue", "default", "do", "else",
+				"enum", "extern", "for", "goto", "if", "inline", "long", "register",
+				"restrict", "return", "short", "signed", "sizeof", "static", "struct",
+				"switch", "typedef", "union", "unsigned", "volatile", "_Atomic",
+				"#define", "#elif", "#else", "#endif", "#if", "#ifdef", "#ifndef", "#include",
+			},
+			SyntaxType: {"primitive_type", "type_identifier"},
+			SyntaxLiteral: {
+				"string_literal", "char_literal", "number_literal", "system_lib_string",
+				"true", "false", "null", "escape_sequence",
+			},
+			SyntaxComment: {"comment"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"function_definition":    true,
+			"compound_statement":     true,
+			"field_declaration_list": true,
+			"enumerator_list":        true,
+			"initializer_list":       true,
+			"preproc_if":             true,
+			"preproc_ifdef":          true,
+		},
+		LineComment:   "//",
+		BlockComment:  [2]string{"/*", "*/"},
+		LSPLanguageID: "c",
+	},
+
+	{
+		Language:   LanguageShell,
+		Name:       "Shell",
+		Aliases:    []string{"sh", "bash", "zsh"},
+		Extensions: []string{".sh", ".bash"},
+		FileNames:  []string{".bashrc", ".bash_profile", ".profile"},
+		Shebangs:   []string{"sh", "bash", "zsh", "dash", "ksh"},
+		Parse:      parseMoment(treesitter.ParseBash),
+		Stainer: newSyntaxStainer(map[SyntaxClass][]string{
+			SyntaxKeyword: {
+				"case", "declare", "do", "done", "elif", "else", "esac", "export", "fi",
+				"for", "function", "if", "in", "local", "readonly", "then", "typeset",
+				"unset", "unsetenv", "while",
+			},
+			SyntaxBuiltin: {"variable_name", "special_variable_name"},
+			SyntaxLiteral: {"string", "\"", "raw_string", "ansii_c_string", "heredoc_body"},
+			SyntaxComment: {"comment"},
+		}),
+		FoldNodeTypes: map[string]bool{
+			"function_definition": true,
+			"compound_statement":  true,
+			"do_group":            true,
+			"if_statement":        true,
+			"case_statement":      true,
+		},
+		LineComment:   "#",
+		LSPLanguageID: "shellscript",
+	},
+}
@@ -1,21 +1,325 @@
 package li
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
 type LanguageServerProtocolConfig struct {
-	Enable bool
+	Enable         bool
+	Format         bool                            // format on leaving edit mode
+	TimeoutSeconds int                             // of requests
+	Servers        map[string]LanguageServerConfig // by language name, like Go
+}
+
+type LanguageServerConfig struct {
+	Command               string
+	Args                  []string // ${ConfigDir} and environment variables are expanded
+	RootMarkers           []string // files or dirs marking workspace root, like go.mod
+	InitializationOptions map[string]any
+	Settings              map[string]any // returned for workspace/configuration, by section
+}
+
+// LSPServerKey identifies a running language server
+type LSPServerKey struct {
+	Language Language
+	Root     string
+}
+
+// languageConfigName returns key of language in config
+func languageConfigName(lang Language) string {
+	return strings.TrimPrefix(lang.String(), "Language")
+}
+
+// findWorkspaceRoot returns the nearest ancestor of dir containing one of markers, or dir if not found
+func findWorkspaceRoot(dir string, markers []string) string {
+	for d := dir; ; {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(d, marker)); err == nil {
+				return d
+			}
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return dir
+}
+
+var lspClientCapabilities = M{
+	"textDocument": M{
+		"publishDiagnostics": M{
+			"versionSupport": true,
+		},
+		"completion": M{
+			"completionItem": M{
+				"snippetSupport": true,
+			},
+		},
+		"rename": M{
+			"prepareSupport": true,
+		},
+		"documentSymbol": M{
+			"hierarchicalDocumentSymbolSupport": true,
+		},
+		"semanticTokens": M{
+			"requests": M{
+				"full": M{
+					"delta": true,
+				},
+			},
+			"tokenTypes":              lspSemanticTokenTypes,
+			"tokenModifiers":          lspSemanticTokenModifiers,
+			"formats":                 []string{"relative"},
+			"overlappingTokenSupport": false,
+			"multilineTokenSupport":   false,
+		},
+		"codeAction": M{
+			"codeActionLiteralSupport": M{
+				"codeActionKind": M{
+					"valueSet": []string{
+						"quickfix",
+						"refactor",
+						"refactor.extract",
+						"refactor.inline",
+						"refactor.rewrite",
+						"source",
+						"source.organizeImports",
+					},
+				},
+			},
+		},
+	},
+	"workspace": M{
+		"applyEdit":        true,
+		"configuration":    true,
+		"workspaceFolders": true,
+		"semanticTokens": M{
+			"refreshSupport": true,
+		},
+		"workspaceEdit": M{
+			"documentChanges": true,
+		},
+	},
+	"window": M{
+		"showMessage": M{
+			"messageActionItem": M{
+				"additionalPropertiesSupport": false,
+			},
+		},
+	},
+}
+
+type StartLanguageServer func(key LSPServerKey) (*LSPEndpoint, error)
+
+const (
+	lspMaxRestarts   = 5
+	lspRestartWindow = time.Minute * 5 // restart count is reset if server ran longer
+	lspExitTimeout   = time.Second * 2
+)
+
+var lspRestartDelay = time.Second // doubled on each restart
+
+func (_ Provide) StartLanguageServer(
+	getConfig GetConfig,
+	configDir ConfigDir,
+	client *LSPClient,
+	run RunInMainLoop,
+	j AppendJournal,
+) StartLanguageServer {
+	return func(key LSPServerKey) (endpoint *LSPEndpoint, err error) {
+		defer he(&err)
+
+		var c struct {
+			LanguageServerProtocol LanguageServerProtocolConfig
+		}
+		ce(getConfig(&c))
+		config, ok := c.LanguageServerProtocol.Servers[languageConfigName(key.Language)]
+		if !ok || config.Command == "" {
+			return nil, fmt.Errorf("no language server for %s", key.Language)
+		}
+
+		exePath, err := exec.LookPath(config.Command)
+		if err != nil {
+			return nil, fmt.Errorf("%s executable not found in PATH", config.Command)
+		}
+		var args []string
+		for _, arg := range config.Args {
+			args = append(args, os.Expand(arg, func(name string) string {
+				if name == "ConfigDir" {
+					return string(configDir)
+				}
+				return os.Getenv(name)
+			}))
+		}
+		cmd := exec.Command(exePath, args...)
+		cmd.Dir = key.Root
+		w, err := cmd.StdinPipe()
+		ce(err)
+		r, err := cmd.StdoutPipe()
+		ce(err)
+		ce(cmd.Start())
+
+		endpoint = NewLSPEndpoint(
+			struct {
+				io.Writer
+				io.Reader
+			}{w, r},
+			key.Language,
+			func(err error) {
+				j("language server for %s error: %v", key.Language, err)
+			},
+			func(format string, args ...any) {
+				j(format, args...)
+			},
+			func(method string, params json.RawMessage) {
+				run(func(
+					trigger Trigger,
+				) {
+					trigger(EvLSPNotification{
+						Endpoint: endpoint,
+						Method:   method,
+						Params:   params,
+					})
+				})
+			},
+			func(id json.RawMessage, method string, params json.RawMessage) {
+				run(func(
+					scope Scope,
+				) {
+					serveLSPRequest(scope, endpoint, id, method, params)
+				})
+			},
+		)
+		endpoint.Root = key.Root
+		endpoint.Settings = config.Settings
+		endpoint.Process = cmd.Process
+		endpoint.Timeout = time.Duration(c.LanguageServerProtocol.TimeoutSeconds) * time.Second
+
+		// reap and restart
+		superviseLanguageServer(client, run, j, key, endpoint, func() {
+			cmd.Wait()
+		})
+
+		rootURI := pathToURI(key.Root)
+		var ret json.RawMessage
+		if err := endpoint.Req("initialize", M{
+			"processId":             syscall.Getpid(),
+			"rootUri":               rootURI,
+			"initializationOptions": config.InitializationOptions,
+			"capabilities":          lspClientCapabilities,
+			"workspaceFolders": []M{
+				{
+					"uri":  rootURI,
+					"name": filepath.Base(key.Root),
+				},
+			},
+		}).Result(&ret); err != nil {
+			cmd.Process.Kill()
+			return nil, err
+		}
+		var result struct {
+			Capabilities json.RawMessage `json:"capabilities"`
+		}
+		if err := json.Unmarshal(ret, &result); err == nil {
+			endpoint.Capabilities = result.Capabilities
+		}
+		endpoint.Notify("initialized", M{})
+
+		j("language server for %s at %s started:\n%s", key.Language, key.Root, toJSON(ret))
+		return endpoint, nil
+	}
+}
+
+// superviseLanguageServer restarts language server of key with backoff after endpoint exited,
+// documents of endpoint in visible buffers are reopened
+func superviseLanguageServer(
+	client *LSPClient,
+	run RunInMainLoop,
+	j AppendJournal,
+	key LSPServerKey,
+	endpoint *LSPEndpoint,
+	wait func(),
+) {
+	startedAt := time.Now()
+	go func() {
+		<-endpoint.Done()
+		wait()
+		run(func() {
+			if client.Endpoints[key] != endpoint {
+				// not started or replaced
+				return
+			}
+			delete(client.Endpoints, key)
+			var buffers []*Buffer
+			for buffer, doc := range client.Documents {
+				if doc.Endpoint == endpoint {
+					buffers = append(buffers, buffer)
+					client.removeDocument(buffer)
+				}
+			}
+			if endpoint.Stopping() {
+				return
+			}
+
+			restarts := client.Restarts[key]
+			if time.Since(startedAt) > lspRestartWindow {
+				restarts = 0
+			}
+			if restarts >= lspMaxRestarts {
+				j("language server for %s at %s exited %d times, not restarting", key.Language, key.Root, restarts)
+				return
+			}
+			client.Restarts[key] = restarts + 1
+			delay := lspRestartDelay << restarts
+			j("language server for %s at %s exited, restarting in %v", key.Language, key.Root, delay)
+
+			time.AfterFunc(delay, func() {
+				run(func(
+					views Views,
+					linkedOne LinkedOne,
+					start StartLanguageServer,
+				) {
+					if _, ok := client.Endpoints[key]; ok {
+						return
+					}
+					endpoint, err := start(key)
+					if err != nil {
+						j("restart language server for %s: %v", key.Language, err)
+						return
+					}
+					client.Endpoints[key] = endpoint
+					// reopen visible buffers
+					for _, buffer := range buffers {
+						if _, ok := client.Documents[buffer]; ok {
+							continue
+						}
+						for _, view := range views {
+							if view.Buffer != buffer {
+								continue
+							}
+							var moment *Moment
+							linkedOne(buffer, &moment)
+							if moment != nil {
+								client.open(endpoint, buffer, moment)
+							}
+							break
+						}
+					}
+				})
+			})
+		})
+	}()
 }
 
 func (_ Provide) LSP(
@@ -35,323 +339,283 @@ func (_ Provide) LSP(
 			return
 		}
 
-		endpoints := make(map[string]*LSPEndpoint)
-
 		// start lsp process
 		on(func(
 			ev EvBufferLanguageChanged,
-			configDir ConfigDir,
 			linkedOne LinkedOne,
+			client *LSPClient,
+			start StartLanguageServer,
 		) {
 			j("%s changed language from %v to %v", ev.Buffer.Path, ev.OldLang, ev.NewLang)
 
-			if _, ok := endpoints[ev.Buffer.AbsDir]; ok {
+			if ev.Buffer.Path == "" {
 				return
 			}
 
-			lang := ev.NewLang
-			switch lang {
+			// reopen with new language
+			client.close(ev.Buffer)
 
-			case LanguageGo:
-				exePath, err := exec.LookPath("gopls")
-				if err != nil {
-					j("gopls executable not found in PATH")
-				} else {
-					cmd := exec.Command(
-						exePath,
-						"-logfile", filepath.Join(string(configDir), "gopls.log"),
-						"-rpc.trace",
-						"-v",
-					)
-					w, err := cmd.StdinPipe()
-					ce(err)
-					r, err := cmd.StdoutPipe()
-					ce(err)
-					ce(cmd.Start())
-
-					var endpoint *LSPEndpoint
-					endpoint = NewLSPEndpoint(
-						struct {
-							io.Writer
-							io.Reader
-						}{w, r},
-						lang,
-						func(err error) {
-							j("language server for %s error: %v", endpoint.Language, err)
-							delete(endpoints, ev.Buffer.AbsDir)
-						},
-						func(format string, args ...any) {
-							j(format, args...)
-						},
-					)
-					endpoints[ev.Buffer.AbsDir] = endpoint
-
-					var ret any
-					ce(endpoint.Req("initialize", M{
-						"processId": syscall.Getpid(),
-						"rootUri":   ev.Buffer.AbsDir,
-					}).Unmarshal(&ret))
-					endpoint.Notify("initialized", M{})
-
-					var moment *Moment
-					linkedOne(ev.Buffer, &moment)
-					if moment != nil {
-						endpoint.Notify("textDocument/didOpen", M{
-							"textDocument": M{
-								"uri":        ev.Buffer.AbsPath,
-								"languageId": "go",
-								"version":    moment.ID,
-								"text":       moment.GetContent(),
-							},
-						})
-					}
+			serverConfig, ok := config.Servers[languageConfigName(ev.NewLang)]
+			if !ok || serverConfig.Command == "" {
+				return
+			}
+			key := LSPServerKey{
+				Language: ev.NewLang,
+				Root:     findWorkspaceRoot(ev.Buffer.AbsDir, serverConfig.RootMarkers),
+			}
 
-					j("language server for %s started:\n%s", lang, toJSON(ret))
+			endpoint, ok := client.Endpoints[key]
+			if !ok {
+				var err error
+				endpoint, err = start(key)
+				if err != nil {
+					j("start language server for %s: %v", ev.NewLang, err)
+					return
 				}
+				client.Endpoints[key] = endpoint
+			}
 
+			var moment *Moment
+			linkedOne(ev.Buffer, &moment)
+			if moment != nil {
+				client.open(endpoint, ev.Buffer, moment)
 			}
 		})
 
-		// format
-		on(func(
-			ev EvMomentSwitched,
-		) {
-			endpoint, ok := endpoints[ev.Buffer.AbsDir]
-			if !ok {
-				return
-			}
-			endpoint.Req("textDocument/formatting", M{
-				"textDocument": M{
-					"uri": ev.Buffer.AbsPath,
-				},
-				"options": M{
-					"foo": "bar",
-				},
-			}).Then(func(c *LSPCall) {
-				var ret any
-				ce(c.Unmarshal(&ret))
-				j("format %s", toJSON(ret))
-				//TODO apply change
+		// format when leaving edit mode
+		if config.Format {
+			on(func(
+				ev EvModesChanged,
+				cur CurrentView,
+				format FormatWithLanguageServer,
+			) {
+				view := cur()
+				if view == nil || view.Timeline != nil || IsEditing(ev.Modes) {
+					return
+				}
+				format(view)
 			})
-		})
+		}
 
 		// sync change
 		on(func(
 			ev EvMomentSwitched,
+			client *LSPClient,
 		) {
-			//TODO
+			client.cancelPending(ev.Buffer)
+			client.sync(ev.Buffer, ev.New)
 		})
 
-	}
-}
-
-type LSPEndpoint struct {
-	*sync.Mutex
-	*sync.Cond
-
-	Language Language
-	RW       io.ReadWriter
-	OnErr    func(error)
-	OnLog    func(format string, args ...any)
+		// save
+		on(func(
+			ev EvBufferSynced,
+			client *LSPClient,
+		) {
+			client.save(ev.Buffer, ev.Moment)
+		})
 
-	calls     []*LSPCall
-	nextReqID int64
-}
+		// close
+		on(func(
+			ev EvViewClosed,
+			client *LSPClient,
+			views Views,
+		) {
+			for _, view := range views {
+				if view.Buffer == ev.View.Buffer {
+					// still visible
+					return
+				}
+			}
+			client.close(ev.View.Buffer)
+		})
 
-type LSPCall struct {
-	endpoint *LSPEndpoint
-	id       int64
-	bs       []byte
-	err      error
-	then     func(*LSPCall)
-}
+		// shutdown
+		on(func(
+			ev EvExit,
+			client *LSPClient,
+		) {
+			wg := new(sync.WaitGroup)
+			for _, endpoint := range client.Endpoints {
+				endpoint := endpoint
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					endpoint.Shutdown(lspExitTimeout)
+				}()
+			}
+			wg.Wait()
+		})
 
-func NewLSPEndpoint(
-	rw io.ReadWriter,
-	lang Language,
-	onErr func(error),
-	onLog func(format string, args ...any),
-) *LSPEndpoint {
-	l := new(sync.Mutex)
-	cond := sync.NewCond(l)
-	endpoint := &LSPEndpoint{
-		Mutex:    l,
-		Cond:     cond,
-		Language: lang,
-		RW:       rw,
-		OnErr:    onErr,
-		OnLog:    onLog,
 	}
-	go endpoint.startHandler()
-	return endpoint
 }
 
-func (l *LSPEndpoint) Req(method string, params M) *LSPCall {
-	l.Lock()
-	defer l.Unlock()
-	id := l.nextReqID
-	l.nextReqID++
-	data := M{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"method":  method,
-		"params":  params,
-	}
-	call := &LSPCall{
-		endpoint: l,
-		id:       id,
-	}
-	buf := new(bytes.Buffer)
-	err := json.NewEncoder(buf).Encode(data)
-	ce(err)
-	bs := buf.Bytes()
-	if _, err := io.WriteString(l.RW, fmt.Sprintf("Content-Length: %d\r\n\r\n", len(bs))); err != nil {
-		if l.OnErr != nil {
-			l.OnErr(err)
+// serveLSPRequest responds request from language server, called in main loop
+func serveLSPRequest(
+	scope Scope,
+	endpoint *LSPEndpoint,
+	id json.RawMessage,
+	method string,
+	params json.RawMessage,
+) {
+	switch method {
+
+	case "workspace/applyEdit":
+		var p struct {
+			Label string           `json:"label"`
+			Edit  LSPWorkspaceEdit `json:"edit"`
 		}
-		call.err = err
-		return call
-	}
-	if _, err := l.RW.Write(bs); err != nil {
-		if l.OnErr != nil {
-			l.OnErr(err)
+		if err := json.Unmarshal(params, &p); err != nil {
+			endpoint.Respond(id, nil, err)
+			return
 		}
-		call.err = err
-		return call
-	}
-	l.calls = append(l.calls, call)
-	return call
-}
-
-func (l *LSPEndpoint) Notify(method string, params M) {
-	l.Lock()
-	defer l.Unlock()
-	data := M{
-		"jsonrpc": "2.0",
-		"method":  method,
-		"params":  params,
-	}
-	buf := new(bytes.Buffer)
-	err := json.NewEncoder(buf).Encode(data)
-	ce(err)
-	bs := buf.Bytes()
-	if _, err := io.WriteString(l.RW, fmt.Sprintf("Content-Length: %d\r\n\r\n", len(bs))); err != nil {
-		if l.OnErr != nil {
-			l.OnErr(err)
+		var applyEdit ApplyWorkspaceEdit
+		scope.Assign(&applyEdit)
+		if err := applyEdit(p.Edit); err != nil {
+			endpoint.Respond(id, M{
+				"applied":       false,
+				"failureReason": err.Error(),
+			}, nil)
+			return
 		}
-		return
-	}
-	if _, err := l.RW.Write(bs); err != nil {
-		if l.OnErr != nil {
-			l.OnErr(err)
+		endpoint.Respond(id, M{
+			"applied": true,
+		}, nil)
+
+	case "workspace/configuration":
+		var p struct {
+			Items []struct {
+				Section string `json:"section"`
+			} `json:"items"`
 		}
-		return
-	}
-}
-
-func (l *LSPEndpoint) startHandler() {
-	r := bufio.NewReader(l.RW)
-	var err error
-	var contentLen int
-	for {
-		var header string
-		header, err = r.ReadString('\n')
-		if err != nil {
-			break
+		if err := json.Unmarshal(params, &p); err != nil {
+			endpoint.Respond(id, nil, err)
+			return
 		}
-		header = strings.TrimSpace(header)
-
-		if strings.HasPrefix(header, "Content-Length:") {
-			contentLen, err = strconv.Atoi(
-				strings.TrimSpace(header[len("Content-Length:"):]),
-			)
-			if err != nil {
-				break
-			}
-
-		} else if len(header) > 0 {
-			continue
-
-		} else if len(header) == 0 {
-			bs := make([]byte, contentLen)
-			if _, err = io.ReadFull(r, bs); err != nil {
-				break
+		results := make([]any, len(p.Items))
+		for i, item := range p.Items {
+			results[i] = lspSettingsSection(endpoint.Settings, item.Section)
+		}
+		endpoint.Respond(id, results, nil)
+
+	case "window/showMessageRequest":
+		var p struct {
+			Type    LSPMessageType `json:"type"`
+			Message string         `json:"message"`
+			Actions []struct {
+				Title string `json:"title"`
+			} `json:"actions"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			endpoint.Respond(id, nil, err)
+			return
+		}
+		var show ShowMessage
+		var pushOverlay PushOverlay
+		var closeOverlay CloseOverlay
+		scope.Assign(&show, &pushOverlay, &closeOverlay)
+		if len(p.Actions) == 0 {
+			show(strings.Split(p.Message, "\n"))
+			endpoint.Respond(id, nil, nil)
+			return
+		}
+		var overlayID ID
+		responded := false
+		respond := func(result any) {
+			closeOverlay(overlayID)
+			if responded {
+				return
 			}
-			var data struct {
-				ID     *int64
-				Method string
-				Params struct {
-					Type    LSPMessageType
-					Message string
+			responded = true
+			endpoint.Respond(id, result, nil)
+		}
+		overlayID = pushOverlay(OverlayObject(&SelectionDialog{
+			Title: strings.SplitN(p.Message, "\n", 2)[0],
+			OnClose: func(_ Scope) {
+				respond(nil)
+			},
+			OnSelect: func(_ Scope, i ID) {
+				if int(i) >= len(p.Actions) {
+					respond(nil)
+					return
 				}
-			}
-			if err = json.Unmarshal(bs, &data); err != nil {
-				break
-			}
-
-			if data.ID != nil {
-				// response
-				l.Lock()
-				for i := 0; i < len(l.calls); i++ {
-					call := l.calls[i]
-					if call.id == *data.ID {
-						call.bs = bs
-						l.calls = append(l.calls[:i], l.calls[i+1:]...)
-						if call.then != nil {
-							go call.then(call)
-						}
+				respond(M{
+					"title": p.Actions[i].Title,
+				})
+			},
+			OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+				for i, action := range p.Actions {
+					if !strings.Contains(action.Title, string(runes)) {
+						continue
 					}
+					if w := displayWidth(action.Title); w > maxLen {
+						maxLen = w
+					}
+					ids = append(ids, ID(i))
 				}
-				l.Unlock()
-				l.Broadcast()
-
-			} else if data.Method == "window/logMessage" && data.Params.Type <= LSPWarning {
-				if l.OnLog != nil {
-					l.OnLog("%s - %s: %s", l.Language, data.Params.Type, data.Params.Message)
+				return
+			},
+			CandidateElement: func(scope Scope, i ID) Element {
+				var box Box
+				var focus ID
+				var style Style
+				var getStyle GetStyle
+				scope.Assign(&box, &focus, &style, &getStyle)
+				s := style
+				if i == focus {
+					hlStyle := getStyle("Highlight")(s)
+					fg, _, _ := hlStyle.Decompose()
+					s = s.Foreground(fg)
 				}
-
-			}
-
-		}
-
-	}
-	if err != nil {
-		if l.OnErr != nil {
-			l.OnErr(err)
-		}
+				return Text(
+					box,
+					p.Actions[i].Title,
+					s,
+				)
+			},
+		}))
+
+	case "workspace/semanticTokens/refresh":
+		var tokens *SemanticTokens
+		scope.Assign(&tokens)
+		tokens.invalidate(endpoint)
+		endpoint.Respond(id, nil, nil)
+
+	case "client/registerCapability",
+		"client/unregisterCapability",
+		"window/workDoneProgress/create":
+		// accepted, registered capabilities are not used
+		endpoint.Respond(id, nil, nil)
+
+	default:
+		endpoint.Respond(id, nil, &LSPResponseError{
+			Code:    lspMethodNotFound,
+			Message: "method not found: " + method,
+		})
 	}
 }
 
-type LSPMessageType uint8
-
-const (
-	LSPError LSPMessageType = iota + 1
-	LSPWarning
-	LSPInfo
-	LSPLog
-)
-
-func (c *LSPCall) Unmarshal(target any) error {
-	if c.err != nil {
-		return c.err
+// lspSettingsSection returns value of dotted section in settings, nil if not found
+func lspSettingsSection(settings map[string]any, section string) any {
+	if section == "" {
+		return settings
 	}
-	c.endpoint.Lock()
-	for c.bs == nil {
-		c.endpoint.Wait()
-	}
-	c.endpoint.Unlock()
-	if err := json.Unmarshal(c.bs, target); err != nil {
-		return err
+	var value any = settings
+	for _, name := range strings.Split(section, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value, ok = m[name]
+		if !ok {
+			return nil
+		}
 	}
-	return nil
+	return value
 }
 
-func (c *LSPCall) Then(fn func(*LSPCall)) {
-	c.endpoint.Lock()
-	defer c.endpoint.Unlock()
-	if c.bs != nil {
-		go fn(c)
-	} else {
-		c.then = fn
-	}
+// EvLSPNotification is triggered in main loop for notifications from language servers
+type EvLSPNotification struct {
+	Endpoint *LSPEndpoint
+	Method   string
+	Params   json.RawMessage
 }
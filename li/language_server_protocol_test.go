@@ -1,6 +1,15 @@
 package li
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -16,3 +25,141 @@ func TestLanguageServerProtocol(t *testing.T) {
 		buffer.SetLanguage(scope, LanguageGo)
 	})
 }
+
+func TestLSPServerRequest(t *testing.T) {
+	withEditorBytes(t, []byte("foo\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+
+		clientReader, serverWriter := io.Pipe()
+		serverReader, clientWriter := io.Pipe()
+		defer clientWriter.Close()
+		defer serverWriter.Close()
+		type request struct {
+			id     json.RawMessage
+			method string
+			params json.RawMessage
+		}
+		requests := make(chan request, 1)
+		endpoint := NewLSPEndpoint(
+			struct {
+				io.Writer
+				io.Reader
+			}{clientWriter, clientReader},
+			LanguageGo,
+			nil,
+			nil,
+			nil,
+			func(id json.RawMessage, method string, params json.RawMessage) {
+				requests <- request{id, method, params}
+			},
+		)
+
+		bs, err := json.Marshal(M{
+			"jsonrpc": "2.0",
+			"id":      "1",
+			"method":  "workspace/applyEdit",
+			"params": M{
+				"edit": M{
+					"changes": M{
+						pathToURI(buffer.AbsPath): []M{
+							{
+								"range": M{
+									"start": M{"line": 0, "character": 0},
+									"end":   M{"line": 0, "character": 3},
+								},
+								"newText": "bar",
+							},
+						},
+					},
+				},
+			},
+		})
+		ce(err)
+		go io.WriteString(serverWriter, "Content-Length: "+strconv.Itoa(len(bs))+"\r\n\r\n"+string(bs))
+
+		req := <-requests
+		eq(t,
+			req.method, "workspace/applyEdit",
+		)
+		responses := make(chan string, 1)
+		go func() {
+			r := bufio.NewReader(serverReader)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimSpace(line) == "" {
+					break
+				}
+			}
+			var res struct {
+				ID     string
+				Result struct {
+					Applied bool
+				}
+			}
+			ce(json.NewDecoder(r).Decode(&res))
+			responses <- fmt.Sprintf("%s %v", res.ID, res.Result.Applied)
+		}()
+		serveLSPRequest(scope, endpoint, req.id, req.method, req.params)
+		eq(t,
+			<-responses, "1 true",
+			string(view.GetMoment().GetBytes()), "bar\n",
+		)
+	})
+}
+
+func TestLanguageServerConfig(t *testing.T) {
+	withEditor(func(
+		getConfig GetConfig,
+	) {
+		var c struct {
+			LanguageServerProtocol LanguageServerProtocolConfig
+		}
+		ce(getConfig(&c))
+		config, ok := c.LanguageServerProtocol.Servers[languageConfigName(LanguageGo)]
+		eq(t,
+			ok, true,
+			config.Command, "gopls",
+			config.RootMarkers[1], "go.mod",
+		)
+	})
+}
+
+func TestFindWorkspaceRoot(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "foo", "bar")
+	ce(os.MkdirAll(dir, 0755))
+	ce(ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module foo\n"), 0644))
+	eq(t,
+		findWorkspaceRoot(dir, []string{"go.mod"}), root,
+		findWorkspaceRoot(dir, []string{"no-such-marker"}), dir,
+		findWorkspaceRoot(dir, nil), dir,
+	)
+}
+
+func TestLSPSettingsSection(t *testing.T) {
+	settings := map[string]any{
+		"gopls": map[string]any{
+			"staticcheck": true,
+		},
+	}
+	eq(t,
+		lspSettingsSection(settings, "gopls.staticcheck"), true,
+		lspSettingsSection(settings, "gopls.foo"), nil,
+		lspSettingsSection(settings, "foo"), nil,
+		lspSettingsSection(nil, "foo"), nil,
+	)
+}
+
+func TestPathToURI(t *testing.T) {
+	eq(t,
+		pathToURI("/foo/bar baz"), "file:///foo/bar%20baz",
+		uriToPath("file:///foo/bar%20baz"), filepath.FromSlash("/foo/bar baz"),
+	)
+}
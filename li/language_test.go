@@ -0,0 +1,153 @@
+package li
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/reusee/li/treesitter"
+)
+
+func TestLanguageFromPath(t *testing.T) {
+	eq(t,
+		LanguageFromPath("foo.go"), LanguageGo,
+		LanguageFromPath("/a/b/FOO.PY"), LanguagePython,
+		LanguageFromPath("conf.yml"), LanguageYAML,
+		LanguageFromPath("/home/foo/.bashrc"), LanguageShell,
+		LanguageFromPath("README.md"), LanguageMarkdown,
+		LanguageFromPath("README"), LanguageUnknown,
+		LanguageFromPath("foo.unknown"), LanguageUnknown,
+	)
+}
+
+func TestDetectLanguage(t *testing.T) {
+	withEditor(func(
+		newMoment NewMomentFromBytes,
+	) {
+		detect := func(path string, content string) Language {
+			moment, _, err := newMoment([]byte(content))
+			ce(err)
+			return DetectLanguage(path, moment)
+		}
+		eq(t,
+			detect("foo", "#!/usr/bin/env python3\nprint(1)\n"), LanguagePython,
+			detect("foo", "#!/usr/bin/env -S python3.9 -u\n"), LanguagePython,
+			detect("foo", "#!/bin/bash\necho\n"), LanguageShell,
+			detect("foo", "#!/usr/bin/perl\n"), LanguageUnknown,
+			detect("foo.txt", "# vim: set ft=yaml:\nfoo: 1\n"), LanguageYAML,
+			detect("foo.txt", "/* vim:ft=c */\n"), LanguageC,
+			detect("foo.sh", "# -*- mode: python; coding: utf-8 -*-\n"), LanguagePython,
+			detect("foo", "# -*- sh -*-\n"), LanguageShell,
+			detect("foo", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n# vim: ft=python\n"), LanguagePython,
+			detect("foo", "1\n2\n3\n4\n5\n# vim: ft=python\n7\n8\n9\n10\n11\n12\n"), LanguageUnknown,
+			detect("foo.go", "package main\n"), LanguageGo,
+		)
+	})
+}
+
+func TestRegisterLanguage(t *testing.T) {
+	lang := RegisterLanguage(LanguageSpec{
+		Name:       "Foo",
+		Extensions: []string{".foo"},
+	})
+	defer func() {
+		delete(languageSpecsByValue, lang)
+		languageSpecs = languageSpecs[:len(languageSpecs)-1]
+	}()
+	eq(t,
+		lang > LanguageShell, true,
+		lang.String(), "LanguageFoo",
+		LanguageFromPath("bar.foo"), lang,
+		languageByName("foo"), lang,
+		languageParser(lang) == nil, true,
+	)
+}
+
+func TestSetBufferLanguage(t *testing.T) {
+	withEditorBytes(t, []byte("def foo():\n  pass\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		moment *Moment,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+		styles SyntaxStyles,
+	) {
+		// parsed as go first
+		buffer.SetLanguage(scope, LanguageGo)
+		eq(t,
+			moment.GetSyntaxAttr(scope, 0, 0), "identifier",
+		)
+
+		scope.Call(SetBufferLanguage)
+		ctrl("loop")
+		for _, r := range "pyth" {
+			emitRune(r)
+		}
+		emitKey(tcell.KeyEnter)
+		stainer, ok := view.Stainer.(*SemanticTokensStainer)
+		eq(t,
+			buffer.language, LanguagePython,
+			ok, true,
+		)
+		_, ok = stainer.Lexical.(*SyntaxStainer)
+		eq(t,
+			ok, true,
+			treesitter.NodeType(moment.GetParser(scope).RootNode()), "module",
+			moment.GetSyntaxAttr(scope, 0, 0), "def",
+		)
+
+		line := moment.GetLine(0)
+		l := LineNumber(0)
+		var fns []StyleFunc
+		scope.Fork(&moment, &line, &l).Call(stainer.Lexical.Line()).Assign(&fns)
+		eq(t,
+			len(fns), len(line.Cells),
+			fns[0](tcell.StyleDefault), styles.Keyword(tcell.StyleDefault),
+			fns[4] == nil, true,
+		)
+	})
+}
+
+func TestJSONLanguage(t *testing.T) {
+	withEditorBytes(t, []byte("{\n  \"foo\": [1, true],\n  \"bar\": null\n}\n"), func(
+		scope Scope,
+		buffer *Buffer,
+		moment *Moment,
+	) {
+		buffer.SetLanguage(scope, LanguageFromPath("foo.json"))
+		eq(t,
+			buffer.language, LanguageJSON,
+			moment.GetSyntaxAttr(scope, 1, 3), "string_content",
+			moment.GetSyntaxAttr(scope, 1, 10), "number",
+			moment.GetSyntaxAttr(scope, 1, 13), "true",
+			moment.GetSyntaxAttr(scope, 2, 9), "null",
+			moment.GetFoldRanges(scope), []FoldRange{
+				{0, 3},
+			},
+		)
+	})
+}
+
+func TestMarkdownLanguage(t *testing.T) {
+	withEditorBytes(t, []byte("# foo\n\n## bar\n\n```go\nbaz\n```\n\n- qux\n- quux\n"), func(
+		scope Scope,
+		buffer *Buffer,
+		moment *Moment,
+	) {
+		buffer.SetLanguage(scope, LanguageFromPath("README.md"))
+		eq(t,
+			buffer.language, LanguageMarkdown,
+			moment.GetSyntaxAttr(scope, 0, 0), "atx_h1_marker",
+			moment.GetSyntaxAttr(scope, 4, 0), "fenced_code_block_delimiter",
+			moment.GetSyntaxAttr(scope, 4, 3), "language",
+			moment.GetSyntaxAttr(scope, 8, 0), "list_marker_minus",
+			moment.GetFoldRanges(scope), []FoldRange{
+				{0, 9},
+				{2, 9},
+				{4, 6},
+				{8, 9},
+			},
+		)
+	})
+}
@@ -167,3 +167,68 @@ func (_ Provide) CalculateSumLineHeight(
 		return sum
 	}
 }
+
+type CalculateViewLineHeights func(
+	view *View,
+	moment *Moment,
+	lineRange [2]int,
+) (
+	info map[int]int,
+)
+
+func (_ Provide) CalculateViewLineHeights(
+	calculate CalculateLineHeights,
+	scope Scope,
+	wrapConfig SoftWrapConfig,
+) CalculateViewLineHeights {
+	return func(
+		view *View,
+		moment *Moment,
+		lineRange [2]int,
+	) (
+		info map[int]int,
+	) {
+		info = calculate(moment, lineRange)
+		if len(view.FoldedLines) == 0 && !view.SoftWrap {
+			return
+		}
+		for line := lineRange[0]; line < lineRange[1]; line++ {
+			if view.isLineHidden(scope, moment, line) {
+				info[line] = 0
+			} else if view.SoftWrap {
+				if l := moment.GetLine(line); l != nil {
+					info[line] += len(view.wrapRows(l, wrapConfig)) - 1
+				}
+			}
+		}
+		return
+	}
+}
+
+type CalculateViewSumLineHeight func(
+	view *View,
+	moment *Moment,
+	lineRange [2]int,
+) int
+
+func (_ Provide) CalculateViewSumLineHeight(
+	calculate CalculateViewLineHeights,
+) CalculateViewSumLineHeight {
+	return func(
+		view *View,
+		moment *Moment,
+		lineRange [2]int,
+	) int {
+		info := calculate(view, moment, lineRange)
+		sum := 0
+		for i := lineRange[0]; i < lineRange[1]; i++ {
+			h, ok := info[i]
+			if !ok {
+				sum += 1
+			} else {
+				sum += h
+			}
+		}
+		return sum
+	}
+}
@@ -0,0 +1,476 @@
+package li
+
+import (
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type lineRangeFunc func(view *View, moment *Moment) (begin int, end int)
+
+// paragraphLines returns the block of non-blank lines around cursor
+func paragraphLines(view *View, moment *Moment) (begin int, end int) {
+	begin = view.CursorLine
+	end = view.CursorLine
+	isBlank := func(i int) bool {
+		line := moment.GetLine(i)
+		return line == nil || line.NonSpaceDisplayOffset == nil
+	}
+	if isBlank(view.CursorLine) {
+		return
+	}
+	for !isBlank(begin - 1) {
+		begin--
+	}
+	for !isBlank(end + 1) {
+		end++
+	}
+	return
+}
+
+func currentLine(view *View, moment *Moment) (begin int, end int) {
+	return view.CursorLine, view.CursorLine
+}
+
+func currentAndNextLine(view *View, moment *Moment) (begin int, end int) {
+	return view.CursorLine, view.CursorLine + 1
+}
+
+// selectedLines returns lines of selection, or count lines from cursor, or the default range
+func selectedLines(
+	view *View,
+	withN WithContextNumber,
+	def lineRangeFunc,
+) (
+	begin int,
+	end int,
+) {
+	moment := view.GetMoment()
+	n := 0
+	withN(func(i int) {
+		n = i
+	})
+	if r := view.selectedRange(); r != nil {
+		begin = r.Begin.Line
+		end = r.End.Line
+		if r.End.Cell == 0 && end > begin {
+			// selection ends at the beginning of next line
+			end--
+		}
+	} else if n > 0 {
+		begin = view.CursorLine
+		end = view.CursorLine + n - 1
+	} else {
+		begin, end = def(view, moment)
+	}
+	if last := moment.NumLines() - 1; end > last {
+		end = last
+	}
+	return
+}
+
+type ReplaceLines func(
+	begin int,
+	end int,
+	lines []string,
+)
+
+func (_ Provide) ReplaceLines(
+	cur CurrentView,
+	scope Scope,
+	moveCursor MoveCursor,
+	apply ApplyChange,
+) ReplaceLines {
+	return func(
+		begin int,
+		end int,
+		lines []string,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		moment := view.GetMoment()
+		old := linesText(moment, begin, end)
+		if len(old) == len(lines) {
+			same := true
+			for i, line := range old {
+				if line != lines[i] {
+					same = false
+					break
+				}
+			}
+			if same {
+				return
+			}
+		}
+
+		text := strings.Join(lines, "\n")
+		if end < moment.NumLines()-1 {
+			text += "\n"
+		}
+		newMoment, _ := apply(moment, Change{
+			Op:     OpReplace,
+			Begin:  Position{Line: begin},
+			End:    Position{Line: end + 1},
+			String: text,
+		})
+		view.SelectionAnchor = nil
+		view.switchMoment(scope, newMoment)
+		moveCursor(Move{AbsLine: intP(begin), AbsCol: intP(0)})
+	}
+}
+
+// linesText returns contents of lines without line endings
+func linesText(moment *Moment, begin int, end int) (ret []string) {
+	for i := begin; i <= end; i++ {
+		line := moment.GetLine(i)
+		if line == nil {
+			break
+		}
+		ret = append(ret, strings.TrimSuffix(line.content, "\n"))
+	}
+	return
+}
+
+func transformLines(
+	def lineRangeFunc,
+	fn func(lines []string) []string,
+) func(
+	cur CurrentView,
+	withN WithContextNumber,
+	replace ReplaceLines,
+) {
+	return func(
+		cur CurrentView,
+		withN WithContextNumber,
+		replace ReplaceLines,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		begin, end := selectedLines(view, withN, def)
+		replace(begin, end, fn(linesText(view.GetMoment(), begin, end)))
+	}
+}
+
+func transformLinesWithInput(
+	title string,
+	initial string,
+	def lineRangeFunc,
+	fn func(lines []string, input string) ([]string, error),
+) func(
+	cur CurrentView,
+	withN WithContextNumber,
+	showPrompt ShowPrompt,
+	replace ReplaceLines,
+	show ShowMessage,
+) {
+	return func(
+		cur CurrentView,
+		withN WithContextNumber,
+		showPrompt ShowPrompt,
+		replace ReplaceLines,
+		show ShowMessage,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		begin, end := selectedLines(view, withN, def)
+		showPrompt(title, initial, func(_ Scope, input string) {
+			lines, err := fn(linesText(view.GetMoment(), begin, end), input)
+			if err != nil {
+				show([]string{err.Error()})
+				return
+			}
+			replace(begin, end, lines)
+		})
+	}
+}
+
+func sortLines(lines []string, less func(a, b string) bool) []string {
+	ret := append([]string(nil), lines...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return less(ret[i], ret[j])
+	})
+	return ret
+}
+
+var leadingNumberPattern = regexp.MustCompile(`^\s*[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// leadingNumber returns the number at line begin, or 0 if none
+func leadingNumber(s string) float64 {
+	match := leadingNumberPattern.FindString(s)
+	if match == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(match), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func numericLess(a, b string) bool {
+	return leadingNumber(a) < leadingNumber(b)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// naturalLess compares digit sequences by their numeric values
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			i := 0
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			j := 0
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na := strings.TrimLeft(a[:i], "0")
+			nb := strings.TrimLeft(b[:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a = a[i:]
+			b = b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a = a[1:]
+		b = b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func caseInsensitiveLess(a, b string) bool {
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// sortLinesByRegex sorts by the first submatch of pattern, or the whole match if no group
+func sortLinesByRegex(lines []string, pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	key := func(s string) string {
+		match := re.FindStringSubmatch(s)
+		if len(match) == 0 {
+			return ""
+		}
+		if len(match) > 1 {
+			return match[1]
+		}
+		return match[0]
+	}
+	return sortLines(lines, func(a, b string) bool {
+		return key(a) < key(b)
+	}), nil
+}
+
+// uniqueLines removes duplicated lines, the first occurrence is kept
+func uniqueLines(lines []string) (ret []string) {
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		ret = append(ret, line)
+	}
+	return
+}
+
+func reverseLines(lines []string) []string {
+	ret := make([]string, 0, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		ret = append(ret, lines[i])
+	}
+	return ret
+}
+
+func shuffleLines(lines []string) []string {
+	ret := append([]string(nil), lines...)
+	rand.Shuffle(len(ret), func(i, j int) {
+		ret[i], ret[j] = ret[j], ret[i]
+	})
+	return ret
+}
+
+// joinLines joins lines with sep, leading spaces of joined lines are removed
+func joinLines(lines []string, sep string) []string {
+	if len(lines) == 0 {
+		return lines
+	}
+	var b strings.Builder
+	b.WriteString(lines[0])
+	for _, line := range lines[1:] {
+		b.WriteString(sep)
+		b.WriteString(strings.TrimLeft(line, " \t"))
+	}
+	return []string{b.String()}
+}
+
+// splitLinesAtSeparator splits lines at sep, new lines keep the indentation of the original line
+func splitLinesAtSeparator(lines []string, sep string) (ret []string) {
+	if sep == "" {
+		return lines
+	}
+	for _, line := range lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		for i, part := range strings.Split(line, sep) {
+			if i > 0 {
+				part = indent + strings.TrimLeft(part, " \t")
+			}
+			ret = append(ret, part)
+		}
+	}
+	return
+}
+
+// alignColumns pads fields separated by delim to the same display width
+func alignColumns(lines []string, delim string) []string {
+	if delim == "" {
+		return lines
+	}
+	fieldsList := make([][]string, len(lines))
+	var widths []int
+	for i, line := range lines {
+		if !strings.Contains(line, delim) {
+			continue
+		}
+		fields := strings.Split(line, delim)
+		for j := range fields[:len(fields)-1] {
+			fields[j] = strings.TrimRight(fields[j], " \t")
+			w := displayWidth(fields[j])
+			if j >= len(widths) {
+				widths = append(widths, w)
+			} else if w > widths[j] {
+				widths[j] = w
+			}
+		}
+		fieldsList[i] = fields
+	}
+	ret := make([]string, len(lines))
+	for i, line := range lines {
+		fields := fieldsList[i]
+		if fields == nil {
+			ret[i] = line
+			continue
+		}
+		var b strings.Builder
+		for j, field := range fields {
+			b.WriteString(field)
+			if j == len(fields)-1 {
+				break
+			}
+			b.WriteString(strings.Repeat(" ", widths[j]-displayWidth(field)+1))
+			b.WriteString(delim)
+		}
+		ret[i] = b.String()
+	}
+	return ret
+}
+
+func (_ Command) SortLines() (spec CommandSpec) {
+	spec.Desc = "sort lines lexically"
+	spec.Func = transformLines(paragraphLines, func(lines []string) []string {
+		return sortLines(lines, func(a, b string) bool {
+			return a < b
+		})
+	})
+	return
+}
+
+func (_ Command) SortLinesNumeric() (spec CommandSpec) {
+	spec.Desc = "sort lines by leading number"
+	spec.Func = transformLines(paragraphLines, func(lines []string) []string {
+		return sortLines(lines, numericLess)
+	})
+	return
+}
+
+func (_ Command) SortLinesNatural() (spec CommandSpec) {
+	spec.Desc = "sort lines in natural order"
+	spec.Func = transformLines(paragraphLines, func(lines []string) []string {
+		return sortLines(lines, naturalLess)
+	})
+	return
+}
+
+func (_ Command) SortLinesCaseInsensitive() (spec CommandSpec) {
+	spec.Desc = "sort lines lexically, ignoring case"
+	spec.Func = transformLines(paragraphLines, func(lines []string) []string {
+		return sortLines(lines, caseInsensitiveLess)
+	})
+	return
+}
+
+func (_ Command) SortLinesByRegex() (spec CommandSpec) {
+	spec.Desc = "sort lines by key matched by regular expression"
+	spec.Func = transformLinesWithInput("Sort Key Pattern", "", paragraphLines, sortLinesByRegex)
+	return
+}
+
+func (_ Command) UniqueLines() (spec CommandSpec) {
+	spec.Desc = "remove duplicated lines"
+	spec.Func = transformLines(paragraphLines, uniqueLines)
+	return
+}
+
+func (_ Command) ReverseLines() (spec CommandSpec) {
+	spec.Desc = "reverse order of lines"
+	spec.Func = transformLines(paragraphLines, reverseLines)
+	return
+}
+
+func (_ Command) ShuffleLines() (spec CommandSpec) {
+	spec.Desc = "shuffle lines randomly"
+	spec.Func = transformLines(paragraphLines, shuffleLines)
+	return
+}
+
+func (_ Command) JoinLines() (spec CommandSpec) {
+	spec.Desc = "join lines with space"
+	spec.Func = transformLines(currentAndNextLine, func(lines []string) []string {
+		return joinLines(lines, " ")
+	})
+	return
+}
+
+func (_ Command) JoinLinesWithSeparator() (spec CommandSpec) {
+	spec.Desc = "join lines with separator"
+	spec.Func = transformLinesWithInput("Join Separator", ", ", currentAndNextLine, func(lines []string, sep string) ([]string, error) {
+		return joinLines(lines, sep), nil
+	})
+	return
+}
+
+func (_ Command) SplitLinesAtSeparator() (spec CommandSpec) {
+	spec.Desc = "split lines at separator"
+	spec.Func = transformLinesWithInput("Split Separator", ",", currentLine, func(lines []string, sep string) ([]string, error) {
+		return splitLinesAtSeparator(lines, sep), nil
+	})
+	return
+}
+
+func (_ Command) AlignColumns() (spec CommandSpec) {
+	spec.Desc = "align columns on delimiter"
+	spec.Func = transformLinesWithInput("Align Delimiter", "=", paragraphLines, func(lines []string, delim string) ([]string, error) {
+		return alignColumns(lines, delim), nil
+	})
+	return
+}
@@ -0,0 +1,84 @@
+package li
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortLines(t *testing.T) {
+	withEditorBytes(t, []byte("c\nb\na\n\nz\ny\n"), func(
+		view *View,
+		scope Scope,
+		moment *Moment,
+	) {
+		var spec CommandSpec
+		spec = Command{}.SortLines()
+		scope.Call(spec.Func)
+		m := view.GetMoment()
+		eq(t,
+			m.GetContent(), "a\nb\nc\n\nz\ny\n",
+			m.Previous == moment, true,
+			m.Change.Op, OpReplace,
+			view.CursorLine, 0,
+		)
+
+		scope.Call(func(move MoveCursor) {
+			move(Move{AbsLine: intP(4)})
+		})
+		scope.Call(spec.Func)
+		eq(t,
+			view.GetMoment().GetContent(), "a\nb\nc\n\ny\nz\n",
+		)
+	})
+}
+
+func TestLineCommandsCount(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\nd"), func(
+		view *View,
+		scope Scope,
+		setN SetContextNumber,
+	) {
+		setN(3)
+		scope.Call(Command{}.ReverseLines().Func)
+		eq(t,
+			view.GetMoment().GetContent(), "c\nb\na\nd\n",
+		)
+
+		scope.Call(func(move MoveCursor) {
+			move(Move{AbsLine: intP(2)})
+		})
+		scope.Call(Command{}.JoinLines().Func)
+		eq(t,
+			view.GetMoment().GetContent(), "c\nb\na d\n",
+		)
+	})
+}
+
+func TestLineTransforms(t *testing.T) {
+	eq(t,
+		strings.Join(sortLines([]string{"10", "9", "-1", "x"}, numericLess), ","), "-1,x,9,10",
+		strings.Join(sortLines([]string{"a10", "a9", "a1b", "a01a"}, naturalLess), ","), "a01a,a1b,a9,a10",
+		strings.Join(sortLines([]string{"b", "B", "a"}, caseInsensitiveLess), ","), "a,b,B",
+		strings.Join(uniqueLines([]string{"a", "b", "a", "c", "b"}), ","), "a,b,c",
+		strings.Join(reverseLines([]string{"a", "b", "c"}), ","), "c,b,a",
+		strings.Join(joinLines([]string{"a", "  b", "\tc"}, ", "), ","), "a, b, c",
+		strings.Join(splitLinesAtSeparator([]string{"  a,b, c"}, ","), "|"), "  a|  b|  c",
+		strings.Join(alignColumns([]string{
+			"a = 1",
+			"foo = 2",
+			"none",
+			"bar=3",
+		}, "="), "|"), "a   = 1|foo = 2|none|bar =3",
+		len(shuffleLines([]string{"a", "b", "c"})), 3,
+	)
+
+	lines, err := sortLinesByRegex([]string{"x 3", "y 1", "z 2"}, `(\d)`)
+	eq(t,
+		err, nil,
+		strings.Join(lines, ","), "y 1,z 2,x 3",
+	)
+	_, err = sortLinesByRegex(nil, `(`)
+	eq(t,
+		err != nil, true,
+	)
+}
@@ -5,14 +5,17 @@ type (
 		Moment *Moment
 		Line   int
 		Hints  []string
+		Style  string // style name, Hint if empty
 		mark   int
 	}
-	GetLineHints func() ([]LineHint, int)
-	AddLineHint  func(*Moment, int, []string)
+	GetLineHints      func() ([]LineHint, int)
+	AddLineHint       func(*Moment, int, []string)
+	AddStyledLineHint func(*Moment, int, []string, string)
 )
 
 type EvCollectLineHints struct {
-	Add AddLineHint
+	Add       AddLineHint
+	AddStyled AddStyledLineHint
 }
 
 func (_ Provide) LineHints(
@@ -27,10 +30,11 @@ func (_ Provide) LineHints(
 
 	changed := false
 	mark := 42
-	add := AddLineHint(func(
+	addStyled := AddStyledLineHint(func(
 		moment *Moment,
 		line int,
 		strs []string,
+		style string,
 	) {
 		i, j := 0, len(hints)
 
@@ -47,6 +51,10 @@ func (_ Provide) LineHints(
 					i = h + 1
 				} else if line < hint.Line {
 					j = h
+				} else if style > hint.Style {
+					i = h + 1
+				} else if style < hint.Style {
+					j = h
 				} else {
 					// found, check strs
 					same := true
@@ -71,6 +79,7 @@ func (_ Provide) LineHints(
 										Moment: moment,
 										Line:   line,
 										Hints:  strs,
+										Style:  style,
 										mark:   mark,
 									},
 								},
@@ -103,6 +112,14 @@ func (_ Provide) LineHints(
 		)
 	})
 
+	add := AddLineHint(func(
+		moment *Moment,
+		line int,
+		strs []string,
+	) {
+		addStyled(moment, line, strs, "")
+	})
+
 	version := mark
 
 	return func() ([]LineHint, int) {
@@ -119,7 +136,8 @@ func (_ Provide) LineHints(
 				mark++
 				trigger(
 					EvCollectLineHints{
-						Add: add,
+						Add:       add,
+						AddStyled: addStyled,
 					},
 				)
 				// clear unmarked entries
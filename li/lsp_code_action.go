@@ -0,0 +1,249 @@
+package li
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type LSPCommand struct {
+	Title     string            `json:"title"`
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+type LSPCodeAction struct {
+	Title       string            `json:"title"`
+	Kind        string            `json:"kind"`
+	IsPreferred bool              `json:"isPreferred"`
+	Edit        *LSPWorkspaceEdit `json:"edit"`
+	Command     *LSPCommand       `json:"command"`
+	Disabled    *struct {
+		Reason string `json:"reason"`
+	} `json:"disabled"`
+}
+
+// parseLSPCodeActions parses result of [](Command | CodeAction), disabled actions are skipped
+func parseLSPCodeActions(result json.RawMessage) (actions []LSPCodeAction, err error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(result, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var probe struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(item, &probe); err != nil {
+			return nil, err
+		}
+		if len(probe.Command) > 0 && probe.Command[0] == '"' {
+			// Command
+			var command LSPCommand
+			if err := json.Unmarshal(item, &command); err != nil {
+				return nil, err
+			}
+			actions = append(actions, LSPCodeAction{
+				Title:   command.Title,
+				Command: &command,
+			})
+			continue
+		}
+		var action LSPCodeAction
+		if err := json.Unmarshal(item, &action); err != nil {
+			return nil, err
+		}
+		if action.Disabled != nil {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	return
+}
+
+func (a LSPCodeAction) text() string {
+	if a.Kind == "" {
+		return a.Title
+	}
+	return fmt.Sprintf("%s (%s)", a.Title, a.Kind)
+}
+
+type ApplyLSPCodeAction func(endpoint *LSPEndpoint, action LSPCodeAction)
+
+func (_ Provide) ApplyLSPCodeAction(
+	applyEdit ApplyWorkspaceEdit,
+	show ShowMessage,
+	run RunInMainLoop,
+) ApplyLSPCodeAction {
+	return func(endpoint *LSPEndpoint, action LSPCodeAction) {
+		if action.Edit != nil {
+			if err := applyEdit(*action.Edit); err != nil {
+				show(strings.Split(err.Error(), "\n"))
+				return
+			}
+		}
+		if action.Command == nil {
+			return
+		}
+		params := M{
+			"command": action.Command.Command,
+		}
+		if len(action.Command.Arguments) > 0 {
+			params["arguments"] = action.Command.Arguments
+		}
+		// server may request workspace/applyEdit before responding, so not waiting in main loop
+		endpoint.Req("workspace/executeCommand", params).Then(func(call *LSPCall) {
+			if err := call.Result(nil); err != nil {
+				run(func(
+					show ShowMessage,
+				) {
+					show(strings.Split(err.Error(), "\n"))
+				})
+			}
+		})
+	}
+}
+
+// codeActionDiagnostics returns diagnostics of view in lines between begin and end
+func codeActionDiagnostics(client *LSPClient, view *View, begin int, end int) []LSPDiagnostic {
+	diagnostics := []LSPDiagnostic{}
+	d, ok := client.Diagnostics[view.Buffer.AbsPath]
+	if !ok {
+		return diagnostics
+	}
+	for i, line := range d.Lines(view.GetMoment()) {
+		if line >= begin && line <= end {
+			diagnostics = append(diagnostics, d.Items[i])
+		}
+	}
+	return diagnostics
+}
+
+func CodeActions(
+	cur CurrentView,
+	client *LSPClient,
+	applyAction ApplyLSPCodeAction,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowMessage,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	doc, ok := client.Documents[view.Buffer]
+	if !ok {
+		show([]string{"no language server for " + view.Buffer.Path})
+		return
+	}
+	moment := view.GetMoment()
+	client.sync(view.Buffer, moment)
+
+	// selection or cursor
+	var r Range
+	if selected := view.selectedRange(); selected != nil {
+		r = *selected
+	} else {
+		pos := view.cursorPosition()
+		if pos.Line < 0 {
+			pos = Position{Line: view.CursorLine}
+		}
+		r = Range{pos, pos}
+	}
+
+	var result json.RawMessage
+	if err := doc.Endpoint.Req("textDocument/codeAction", M{
+		"textDocument": M{
+			"uri": doc.URI,
+		},
+		"range": M{
+			"start": lspPosition(moment, r.Begin),
+			"end":   lspPosition(moment, r.End),
+		},
+		"context": M{
+			"diagnostics": codeActionDiagnostics(client, view, r.Begin.Line, r.End.Line),
+		},
+	}).Result(&result); err != nil {
+		show(strings.Split(err.Error(), "\n"))
+		return
+	}
+	if len(result) == 0 || string(result) == "null" {
+		show([]string{"no code actions"})
+		return
+	}
+	actions, err := parseLSPCodeActions(result)
+	if err != nil {
+		show(strings.Split(err.Error(), "\n"))
+		return
+	}
+	if len(actions) == 0 {
+		show([]string{"no code actions"})
+		return
+	}
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: "Code Actions",
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(actions) {
+				return
+			}
+			applyAction(doc.Endpoint, actions[i])
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			pattern := strings.ToLower(string(runes))
+			preferred := -1
+			for i, action := range actions {
+				text := action.text()
+				if !strings.Contains(strings.ToLower(text), pattern) {
+					continue
+				}
+				if w := displayWidth(text); w > maxLen {
+					maxLen = w
+				}
+				if action.IsPreferred && preferred < 0 {
+					preferred = len(ids)
+				}
+				ids = append(ids, ID(i))
+			}
+			if preferred >= 0 {
+				initIndex = preferred
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			var getStyle GetStyle
+			scope.Assign(&box, &focus, &style, &getStyle)
+			s := style
+			if i == focus {
+				hlStyle := getStyle("Highlight")(s)
+				fg, _, _ := hlStyle.Decompose()
+				s = s.Foreground(fg)
+			}
+			return Text(
+				box,
+				actions[i].text(),
+				s,
+			)
+		},
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) CodeActions() (spec CommandSpec) {
+	spec.Desc = "list code actions at cursor or selection"
+	spec.Func = CodeActions
+	return
+}
@@ -0,0 +1,109 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestCodeActions(t *testing.T) {
+	withEditorBytes(t, []byte("package main\n\nfunc main() {\n}\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		commands := make(chan string, 1)
+		endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+			switch method {
+			case "textDocument/codeAction":
+				return []M{
+					{
+						"title": "Organize Imports",
+						"kind":  "source.organizeImports",
+						"edit": M{
+							"changes": M{
+								pathToURI(buffer.AbsPath): []M{
+									{
+										"range": M{
+											"start": M{"line": 1, "character": 0},
+											"end":   M{"line": 1, "character": 0},
+										},
+										"newText": "import \"fmt\"\n",
+									},
+								},
+							},
+						},
+					},
+					{
+						"title":     "Run tests",
+						"command":   "gopls.run_tests",
+						"arguments": []string{"foo"},
+					},
+					{
+						"title": "Extract function",
+						"kind":  "refactor.extract",
+						"disabled": M{
+							"reason": "no selection",
+						},
+					},
+				}
+			case "workspace/executeCommand":
+				var p struct {
+					Command string
+				}
+				ce(json.Unmarshal(params, &p))
+				commands <- p.Command
+			}
+			return nil
+		})
+		client.open(endpoint, buffer, view.GetMoment())
+
+		// edit
+		scope.Call(CodeActions)
+		ctrl("loop")
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			string(view.GetMoment().GetBytes()), "package main\nimport \"fmt\"\n\nfunc main() {\n}\n",
+		)
+
+		// command
+		scope.Call(CodeActions)
+		ctrl("loop")
+		for _, r := range "run" {
+			emitRune(r)
+		}
+		emitKey(tcell.KeyEnter)
+		select {
+		case command := <-commands:
+			eq(t,
+				command, "gopls.run_tests",
+			)
+		case <-time.After(time.Second * 5):
+			t.Fatal("command not executed")
+		}
+	})
+}
+
+func TestParseLSPCodeActions(t *testing.T) {
+	actions, err := parseLSPCodeActions(json.RawMessage(`[
+		{"title": "foo", "command": "foo", "arguments": [1]},
+		{"title": "bar", "kind": "quickfix", "isPreferred": true, "command": {"title": "bar", "command": "bar"}},
+		{"title": "baz", "disabled": {"reason": "baz"}}
+	]`))
+	ce(err)
+	eq(t,
+		len(actions), 2,
+		actions[0].Command.Command, "foo",
+		len(actions[0].Command.Arguments), 1,
+		actions[1].text(), "bar (quickfix)",
+		actions[1].IsPreferred, true,
+		actions[1].Command.Command, "bar",
+	)
+}
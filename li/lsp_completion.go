@@ -0,0 +1,250 @@
+package li
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+type LSPCompletionItem struct {
+	Label               string          `json:"label"`
+	Kind                int             `json:"kind"`
+	Detail              string          `json:"detail"`
+	SortText            string          `json:"sortText"`
+	FilterText          string          `json:"filterText"`
+	InsertText          string          `json:"insertText"`
+	InsertTextFormat    int             `json:"insertTextFormat"` // 2 for snippet
+	TextEdit            json.RawMessage `json:"textEdit"`
+	AdditionalTextEdits []LSPTextEdit   `json:"additionalTextEdits"`
+}
+
+var lspCompletionKinds = []string{
+	"", "text", "method", "func", "ctor", "field", "var", "class", "interface",
+	"module", "property", "unit", "value", "enum", "keyword", "snippet",
+	"color", "file", "ref", "folder", "member", "const", "struct", "event",
+	"op", "type",
+}
+
+// rank lsp items above words matched at the same offset
+const lspCompletionRank = 100
+
+// edit returns the main edit of item, defaultRange is used if item has no text edit
+func (i LSPCompletionItem) edit(defaultRange LSPRange) LSPTextEdit {
+	if len(i.TextEdit) > 0 && string(i.TextEdit) != "null" {
+		var edit struct {
+			LSPTextEdit
+			// InsertReplaceEdit
+			Insert *LSPRange `json:"insert"`
+		}
+		if err := json.Unmarshal(i.TextEdit, &edit); err == nil {
+			if edit.Insert != nil {
+				edit.Range = *edit.Insert
+			}
+			return edit.LSPTextEdit
+		}
+	}
+	text := i.InsertText
+	if text == "" {
+		text = i.Label
+	}
+	return LSPTextEdit{
+		Range:   defaultRange,
+		NewText: text,
+	}
+}
+
+// stateCursorPosition returns cursor position of view moment state
+func stateCursorPosition(moment *Moment, state ViewMomentState) Position {
+	line := moment.GetLine(state.CursorLine)
+	if line == nil {
+		return Position{Line: state.CursorLine}
+	}
+	col := 0
+	for i, cell := range line.Cells {
+		if col >= state.CursorCol {
+			return Position{Line: state.CursorLine, Cell: i}
+		}
+		col += cell.DisplayWidth
+	}
+	return Position{Line: state.CursorLine, Cell: len(line.Cells) - 1}
+}
+
+// matchCompletion matches pattern as subsequence of text, ignoring case
+func matchCompletion(pattern []rune, text string) (offsets []int, rank float64, ok bool) {
+	if len(pattern) == 0 {
+		return []int{-1}, 1, true
+	}
+	runes := []rune(strings.ToLower(text))
+	pi, wi := 0, 0
+	for pi < len(pattern) && wi < len(runes) {
+		if pattern[pi] == runes[wi] {
+			offsets = append(offsets, wi)
+			pi++
+		}
+		wi++
+	}
+	if pi < len(pattern) {
+		return nil, 0, false
+	}
+	return offsets, float64(wi) / float64(pi), true
+}
+
+// applyLSPCompletion applies item at cursor of current view, edits refer to positions in current moment
+func applyLSPCompletion(scope Scope, item LSPCompletionItem, defaultRange LSPRange) {
+	scope.Call(func(
+		cur CurrentView,
+		curMoment CurrentMoment,
+		apply ApplyChange,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		moment := curMoment()
+		edit := item.edit(defaultRange)
+		begin := lspByteOffset(moment, edit.Range.Start)
+		end := lspByteOffset(moment, edit.Range.End)
+
+		// additional edits like imports, must not overlap the main edit
+		if len(item.AdditionalTextEdits) > 0 {
+			newMoment, err := applyTextEdits(apply, moment, item.AdditionalTextEdits)
+			if err == nil && newMoment != moment {
+				for _, e := range item.AdditionalTextEdits {
+					if lspByteOffset(moment, e.Range.Start) <= begin {
+						delta := len(e.NewText) - (lspByteOffset(moment, e.Range.End) - lspByteOffset(moment, e.Range.Start))
+						begin += delta
+						end += delta
+					}
+				}
+				moment = newMoment
+				view.switchMoment(scope, moment)
+			}
+		}
+
+		r := Range{
+			Begin: moment.ByteOffsetToPosition(begin),
+			End:   moment.ByteOffsetToPosition(end),
+		}
+		editScope := scope.Fork(
+			AsCurrentMoment(moment),
+		)
+		if item.InsertTextFormat == 2 {
+			editScope.Call(func(expand ExpandSnippet) {
+				expand(Snippet{Body: edit.NewText}, r)
+			})
+			return
+		}
+		editScope.Call(func(replace ReplaceWithinRange) {
+			replace(r, edit.NewText)
+		})
+	})
+}
+
+func (_ Provide) LSPCompletion(
+	on On,
+	j AppendJournal,
+) OnStartup {
+	return func() {
+		on(func(
+			ev EvCollectCompletionCandidate,
+			client *LSPClient,
+		) {
+			// called in completion goroutine
+			endpoint, uri, ok := client.syncedDocument(ev.View.Buffer, ev.Moment)
+			if !ok {
+				// not synced
+				return
+			}
+			cursor := stateCursorPosition(ev.Moment, ev.State)
+
+			// replace the word before cursor by default
+			pattern, begin, _, ok := completionPattern(ev.Moment, ev.State)
+			if !ok {
+				return
+			}
+			if runes := []rune(pattern); runeCategory(runes[0]) != RuneCategoryIdentifier {
+				// member access
+				if r := runes[len(runes)-1]; r != '.' && r != ':' {
+					return
+				}
+				pattern = ""
+				begin = cursor
+			}
+			defaultRange := LSPRange{
+				Start: toLSPPosition(ev.Moment, begin),
+				End:   toLSPPosition(ev.Moment, cursor),
+			}
+
+			call := endpoint.Req("textDocument/completion", M{
+				"textDocument": M{
+					"uri": uri,
+				},
+				"position": lspPosition(ev.Moment, cursor),
+			})
+			client.cancelOnChange(ev.View.Buffer, call)
+			var result json.RawMessage
+			if err := call.Result(&result); errors.Is(err, ErrLSPCanceled) {
+				return
+			} else if err != nil {
+				j("completion: %v", err)
+				return
+			}
+			var items []LSPCompletionItem
+			if err := json.Unmarshal(result, &items); err != nil {
+				var list struct {
+					Items []LSPCompletionItem
+				}
+				if err := json.Unmarshal(result, &list); err != nil {
+					return
+				}
+				items = list.Items
+			}
+			sort.SliceStable(items, func(i, j int) bool {
+				a, b := items[i].SortText, items[j].SortText
+				if a == "" {
+					a = items[i].Label
+				}
+				if b == "" {
+					b = items[j].Label
+				}
+				return a < b
+			})
+
+			patternRunes := []rune(strings.ToLower(pattern))
+			for i, item := range items {
+				item := item
+				filter := item.FilterText
+				if filter == "" {
+					filter = item.Label
+				}
+				offsets, rank, ok := matchCompletion(patternRunes, filter)
+				if !ok {
+					continue
+				}
+				if filter != item.Label {
+					// offsets are for label
+					offsets, _, ok = matchCompletion(patternRunes, item.Label)
+					if !ok {
+						offsets = []int{-1}
+					}
+				}
+				detail := item.Detail
+				if item.Kind > 0 && item.Kind < len(lspCompletionKinds) {
+					detail = strings.TrimSpace(lspCompletionKinds[item.Kind] + " " + detail)
+				}
+				ev.Add(CompletionCandidate{
+					Text:             item.Label,
+					Detail:           detail,
+					Rank:             lspCompletionRank - rank - float64(i)/float64(len(items)),
+					MatchRuneOffsets: offsets,
+					Begin:            begin,
+					End:              cursor,
+					Apply: func(scope Scope) {
+						applyLSPCompletion(scope, item, defaultRange)
+					},
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLSPCompletion(t *testing.T) {
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "textDocument/completion":
+			return M{
+				"isIncomplete": false,
+				"items": []M{
+					{
+						"label":  "Println",
+						"kind":   3,
+						"detail": "func(a ...any)",
+						"textEdit": M{
+							"range": M{
+								"start": M{"line": 3, "character": 8},
+								"end":   M{"line": 3, "character": 10},
+							},
+							"newText": "Println",
+						},
+						"additionalTextEdits": []M{
+							{
+								"range": M{
+									"start": M{"line": 1, "character": 0},
+									"end":   M{"line": 1, "character": 0},
+								},
+								"newText": "import \"fmt\"\n",
+							},
+						},
+					},
+					{
+						"label":            "Printf",
+						"kind":             3,
+						"insertText":       "Printf(${1:format})",
+						"insertTextFormat": 2,
+					},
+					{
+						"label": "Errorf",
+						"kind":  3,
+					},
+				},
+			}
+		}
+		return nil
+	})
+
+	withEditorBytes(t, []byte("package main\n\nfunc main() {\n    fmt.Pr\n}\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		trigger Trigger,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		moment := view.GetMoment()
+		client.open(endpoint, buffer, moment)
+
+		state := view.ViewMomentState
+		state.CursorLine = 3
+		state.CursorCol = 10
+		candidates := make(map[string]CompletionCandidate)
+		trigger(EvCollectCompletionCandidate{
+			Add: func(c CompletionCandidate) {
+				if c.Detail != "" {
+					candidates[c.Text] = c
+				}
+			},
+			View:   view,
+			Moment: moment,
+			State:  state,
+		})
+		eq(t,
+			len(candidates), 2,
+			candidates["Println"].Detail, "func func(a ...any)",
+			candidates["Println"].MatchRuneOffsets, []int{0, 1},
+			candidates["Println"].Begin, Position{Line: 3, Cell: 8},
+			candidates["Printf"].Detail, "func",
+		)
+
+		// apply with additional edits
+		candidates["Println"].Apply(scope.Fork(
+			AsCurrentView(view),
+			AsCurrentMoment(moment),
+		))
+		eq(t,
+			string(view.GetMoment().GetBytes()), "package main\nimport \"fmt\"\n\nfunc main() {\n    fmt.Println\n}\n",
+		)
+
+		// snippet
+		view.switchMoment(scope, moment)
+		candidates["Printf"].Apply(scope.Fork(
+			AsCurrentView(view),
+			AsCurrentMoment(moment),
+		))
+		eq(t,
+			string(view.GetMoment().GetBytes()), "package main\n\nfunc main() {\n    fmt.Printf(format)\n}\n",
+			view.Snippet != nil, true,
+		)
+	})
+}
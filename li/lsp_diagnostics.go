@@ -0,0 +1,446 @@
+package li
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type LSPSeverity int
+
+const (
+	LSPSeverityError LSPSeverity = iota + 1
+	LSPSeverityWarning
+	LSPSeverityInformation
+	LSPSeverityHint
+)
+
+func (s LSPSeverity) String() string {
+	switch s {
+	case LSPSeverityError:
+		return "error"
+	case LSPSeverityWarning:
+		return "warning"
+	case LSPSeverityInformation:
+		return "info"
+	case LSPSeverityHint:
+		return "hint"
+	}
+	return "error"
+}
+
+// StyleName returns style name for hints and signs
+func (s LSPSeverity) StyleName() string {
+	switch s {
+	case LSPSeverityWarning:
+		return "DiagnosticWarning"
+	case LSPSeverityInformation:
+		return "DiagnosticInfo"
+	case LSPSeverityHint:
+		return "DiagnosticHint"
+	}
+	return "DiagnosticError"
+}
+
+func (s LSPSeverity) Sign() rune {
+	switch s {
+	case LSPSeverityWarning:
+		return 'W'
+	case LSPSeverityInformation:
+		return 'I'
+	case LSPSeverityHint:
+		return 'H'
+	}
+	return 'E'
+}
+
+type LSPDiagnostic struct {
+	Range    LSPRange    `json:"range"`
+	Severity LSPSeverity `json:"severity"`
+	Source   string      `json:"source"`
+	Message  string      `json:"message"`
+	// kept to send back in code action requests
+	Code json.RawMessage `json:"code,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// LSPFileDiagnostics holds diagnostics published for a file
+type LSPFileDiagnostics struct {
+	Path   string
+	Moment *Moment // the moment diagnostics refer to, nil if file is not opened
+	Items  []LSPDiagnostic
+
+	mapped *Moment
+	lines  []int
+}
+
+// Lines returns line numbers of items in moment
+func (d *LSPFileDiagnostics) Lines(moment *Moment) []int {
+	if d.mapped == moment && d.lines != nil {
+		return d.lines
+	}
+	lines := make([]int, len(d.Items))
+	var mapping []int
+	if d.Moment != nil && d.Moment != moment {
+		mapping = mapMomentLines(d.Moment, moment)
+	}
+	for i, item := range d.Items {
+		line := item.Range.Start.Line
+		if mapping != nil && line < len(mapping) {
+			line = mapping[line]
+		}
+		if max := moment.NumLines() - 1; line > max {
+			line = max
+		}
+		lines[i] = line
+	}
+	d.mapped = moment
+	d.lines = lines
+	return lines
+}
+
+// Signs returns the most severe diagnostic severity of lines in moment
+func (d *LSPFileDiagnostics) Signs(moment *Moment) map[int]LSPSeverity {
+	signs := make(map[int]LSPSeverity)
+	for i, line := range d.Lines(moment) {
+		severity := d.Items[i].Severity
+		if s, ok := signs[line]; !ok || severity < s {
+			signs[line] = severity
+		}
+	}
+	return signs
+}
+
+// Position returns position of i-th item in moment
+func (d *LSPFileDiagnostics) Position(moment *Moment, i int) Position {
+	return lspToPosition(moment, LSPPosition{
+		Line:      d.Lines(moment)[i],
+		Character: d.Items[i].Range.Start.Character,
+	})
+}
+
+// mapMomentLines maps line numbers of from to to, changed lines map to the beginning of their hunk
+func mapMomentLines(from *Moment, to *Moment) []int {
+	ret := make([]int, from.NumLines())
+	next := 0
+	for _, diff := range diffMomentLines(from, to) {
+		switch diff.Op {
+		case LineEqual:
+			ret[diff.A] = diff.B
+			next = diff.B + 1
+		case LineDelete:
+			ret[diff.A] = next
+		}
+	}
+	return ret
+}
+
+func (i LSPDiagnostic) hint() string {
+	message := strings.SplitN(strings.TrimSpace(i.Message), "\n", 2)[0]
+	if i.Source != "" {
+		return fmt.Sprintf("%s: %s (%s)", i.Severity, message, i.Source)
+	}
+	return fmt.Sprintf("%s: %s", i.Severity, message)
+}
+
+func (_ Provide) LSPDiagnostics(
+	on On,
+	j AppendJournal,
+) OnStartup {
+	return func() {
+
+		// store
+		on(func(
+			ev EvLSPNotification,
+			client *LSPClient,
+		) {
+			if ev.Method != "textDocument/publishDiagnostics" {
+				return
+			}
+			var params struct {
+				URI         string          `json:"uri"`
+				Version     *int            `json:"version"`
+				Diagnostics []LSPDiagnostic `json:"diagnostics"`
+			}
+			if err := json.Unmarshal(ev.Params, &params); err != nil {
+				j("bad diagnostics: %v", err)
+				return
+			}
+			path := uriToPath(params.URI)
+			client.DiagnosticsVersion++
+			if len(params.Diagnostics) == 0 {
+				delete(client.Diagnostics, path)
+				return
+			}
+			diagnostics := &LSPFileDiagnostics{
+				Path:  path,
+				Items: params.Diagnostics,
+			}
+			for i, item := range diagnostics.Items {
+				if item.Severity == 0 {
+					diagnostics.Items[i].Severity = LSPSeverityError
+				}
+			}
+			sort.SliceStable(diagnostics.Items, func(i, j int) bool {
+				a := diagnostics.Items[i].Range.Start
+				b := diagnostics.Items[j].Range.Start
+				if a.Line != b.Line {
+					return a.Line < b.Line
+				}
+				return a.Character < b.Character
+			})
+			if doc := client.document(path); doc != nil {
+				diagnostics.Moment = doc.Moment
+				if params.Version != nil {
+					if m, ok := doc.Moments[*params.Version]; ok {
+						diagnostics.Moment = m
+					}
+				}
+			}
+			client.Diagnostics[path] = diagnostics
+		})
+
+		// hints
+		on(func(
+			ev EvCollectLineHints,
+			views Views,
+			client *LSPClient,
+		) {
+			for _, view := range views {
+				diagnostics, ok := client.Diagnostics[view.Buffer.AbsPath]
+				if !ok {
+					continue
+				}
+				moment := view.GetMoment()
+				bottom := view.ViewportLine + view.Box.Height()
+				hints := make(map[int]map[LSPSeverity][]string)
+				for i, line := range diagnostics.Lines(moment) {
+					if line < view.ViewportLine || line >= bottom {
+						continue
+					}
+					item := diagnostics.Items[i]
+					if hints[line] == nil {
+						hints[line] = make(map[LSPSeverity][]string)
+					}
+					hints[line][item.Severity] = append(hints[line][item.Severity], item.hint())
+				}
+				for line, severities := range hints {
+					for severity, strs := range severities {
+						ev.AddStyled(moment, line, strs, severity.StyleName())
+					}
+				}
+			}
+		})
+
+		// status
+		on(func(
+			ev EvCollectStatusSections,
+			cur CurrentView,
+			client *LSPClient,
+		) {
+			view := cur()
+			if view == nil {
+				return
+			}
+			diagnostics, ok := client.Diagnostics[view.Buffer.AbsPath]
+			if !ok {
+				return
+			}
+			counts := make(map[LSPSeverity]int)
+			for _, item := range diagnostics.Items {
+				counts[item.Severity]++
+			}
+			var lines [][]any
+			for severity := LSPSeverityError; severity <= LSPSeverityHint; severity++ {
+				n := counts[severity]
+				if n == 0 {
+					continue
+				}
+				lines = append(lines, []any{
+					fmt.Sprintf("%d %s", n, severity),
+					AlignRight,
+					Padding(0, 2, 0, 0),
+				})
+			}
+			ev.Add("diagnostics", lines)
+		})
+
+	}
+}
+
+func currentDiagnostics(
+	cur CurrentView,
+	client *LSPClient,
+) (*View, *LSPFileDiagnostics) {
+	view := cur()
+	if view == nil {
+		return nil, nil
+	}
+	diagnostics, ok := client.Diagnostics[view.Buffer.AbsPath]
+	if !ok {
+		return nil, nil
+	}
+	return view, diagnostics
+}
+
+func NextDiagnostic(
+	cur CurrentView,
+	client *LSPClient,
+	moveCursor MoveCursor,
+) {
+	view, diagnostics := currentDiagnostics(cur, client)
+	if diagnostics == nil {
+		return
+	}
+	moment := view.GetMoment()
+	for i, line := range diagnostics.Lines(moment) {
+		if line > view.CursorLine {
+			moveCursorToCell(view, diagnostics.Position(moment, i), moveCursor)
+			return
+		}
+	}
+}
+
+func (_ Command) NextDiagnostic() (spec CommandSpec) {
+	spec.Desc = "move cursor to next diagnostic from language server"
+	spec.Func = NextDiagnostic
+	return
+}
+
+func PrevDiagnostic(
+	cur CurrentView,
+	client *LSPClient,
+	moveCursor MoveCursor,
+) {
+	view, diagnostics := currentDiagnostics(cur, client)
+	if diagnostics == nil {
+		return
+	}
+	moment := view.GetMoment()
+	lines := diagnostics.Lines(moment)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] < view.CursorLine {
+			moveCursorToCell(view, diagnostics.Position(moment, i), moveCursor)
+			return
+		}
+	}
+}
+
+func (_ Command) PrevDiagnostic() (spec CommandSpec) {
+	spec.Desc = "move cursor to previous diagnostic from language server"
+	spec.Func = PrevDiagnostic
+	return
+}
+
+func ShowDiagnostics(
+	client *LSPClient,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	showPosition ShowPathPosition,
+	moveCursor MoveCursor,
+	show ShowMessage,
+	getStyle GetStyle,
+) {
+
+	type entry struct {
+		diagnostics *LSPFileDiagnostics
+		index       int
+		text        string
+	}
+	var entries []entry
+	paths := make([]string, 0, len(client.Diagnostics))
+	for path := range client.Diagnostics {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		diagnostics := client.Diagnostics[path]
+		for i, item := range diagnostics.Items {
+			entries = append(entries, entry{
+				diagnostics: diagnostics,
+				index:       i,
+				text: fmt.Sprintf(
+					"%s:%d:%d %s",
+					filepath.Base(path),
+					item.Range.Start.Line+1,
+					item.Range.Start.Character+1,
+					item.hint(),
+				),
+			})
+		}
+	}
+	if len(entries) == 0 {
+		show([]string{"no diagnostics"})
+		return
+	}
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: "Diagnostics",
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(entries) {
+				return
+			}
+			e := entries[i]
+			view, err := showPosition(e.diagnostics.Path, Position{
+				Line: e.diagnostics.Items[e.index].Range.Start.Line,
+			})
+			if err != nil {
+				show(strings.Split(err.Error(), "\n"))
+				return
+			}
+			// position in current moment
+			moment := view.GetMoment()
+			moveCursorToCell(view, e.diagnostics.Position(moment, e.index), moveCursor)
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			for i, e := range entries {
+				if !strings.Contains(e.text, string(runes)) {
+					continue
+				}
+				if w := displayWidth(e.text); w > maxLen {
+					maxLen = w
+				}
+				ids = append(ids, ID(i))
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			scope.Assign(&box, &focus, &style)
+			e := entries[i]
+			s := getStyle(e.diagnostics.Items[e.index].Severity.StyleName())(style)
+			if i == focus {
+				hlStyle := getStyle("Highlight")(style)
+				fg, _, _ := hlStyle.Decompose()
+				s = style.Foreground(fg)
+			}
+			return Text(
+				box,
+				e.text,
+				s,
+			)
+		},
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) ShowDiagnostics() (spec CommandSpec) {
+	spec.Desc = "list diagnostics from language servers"
+	spec.Func = ShowDiagnostics
+	return
+}
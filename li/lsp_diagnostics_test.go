@@ -0,0 +1,205 @@
+package li
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestLSPDiagnostics(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\nd\n"), func(
+		view *View,
+		buffer *Buffer,
+		moment *Moment,
+		apply ApplyChange,
+		scope Scope,
+		client *LSPClient,
+		trigger Trigger,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		endpoint := NewLSPEndpoint(
+			struct {
+				io.Writer
+				io.Reader
+			}{new(bytes.Buffer), pr},
+			LanguageGo,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		client.open(endpoint, buffer, moment)
+		version := client.Documents[buffer].Version
+
+		// insert a line before diagnostics arrive
+		m1, _ := apply(moment, Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: 0},
+			String: "x\n",
+		})
+		view.switchMoment(scope, m1)
+
+		params, err := json.Marshal(M{
+			"uri":     pathToURI(buffer.AbsPath),
+			"version": version,
+			"diagnostics": []M{
+				{
+					"range": M{
+						"start": M{"line": 2, "character": 0},
+						"end":   M{"line": 2, "character": 1},
+					},
+					"severity": 2,
+					"message":  "foo",
+				},
+				{
+					"range": M{
+						"start": M{"line": 1, "character": 0},
+						"end":   M{"line": 1, "character": 1},
+					},
+					"message": "bar\nbaz",
+					"source":  "compiler",
+				},
+			},
+		})
+		ce(err)
+		trigger(EvLSPNotification{
+			Endpoint: endpoint,
+			Method:   "textDocument/publishDiagnostics",
+			Params:   params,
+		})
+
+		diagnostics := client.Diagnostics[buffer.AbsPath]
+		eq(t,
+			diagnostics.Moment, moment,
+			diagnostics.Items[0].Message, "bar\nbaz",
+			diagnostics.Items[0].Severity, LSPSeverityError,
+			diagnostics.Lines(m1), []int{2, 3},
+			diagnostics.Signs(m1)[3], LSPSeverityWarning,
+		)
+
+		var hints []string
+		var styles []string
+		trigger(EvCollectLineHints{
+			AddStyled: func(m *Moment, line int, strs []string, style string) {
+				if m == m1 && line == 2 {
+					hints = append(hints, strs...)
+					styles = append(styles, style)
+				}
+			},
+		})
+		eq(t,
+			hints, []string{"error: bar (compiler)"},
+			styles, []string{"DiagnosticError"},
+		)
+
+		scope.Call(NextDiagnostic)
+		eq(t,
+			view.CursorLine, 2,
+		)
+		scope.Call(NextDiagnostic)
+		eq(t,
+			view.CursorLine, 3,
+		)
+		scope.Call(PrevDiagnostic)
+		eq(t,
+			view.CursorLine, 2,
+		)
+
+		// cleared
+		params, err = json.Marshal(M{
+			"uri":         pathToURI(buffer.AbsPath),
+			"diagnostics": []M{},
+		})
+		ce(err)
+		trigger(EvLSPNotification{
+			Endpoint: endpoint,
+			Method:   "textDocument/publishDiagnostics",
+			Params:   params,
+		})
+		_, ok := client.Diagnostics[buffer.AbsPath]
+		eq(t,
+			ok, false,
+		)
+	})
+}
+
+func TestDiagnosticInEmptyFile(t *testing.T) {
+	withEditorBytes(t, []byte(""), func(
+		view *View,
+		buffer *Buffer,
+		moment *Moment,
+		scope Scope,
+		client *LSPClient,
+		trigger Trigger,
+		emitKey EmitKey,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		endpoint := NewLSPEndpoint(
+			struct {
+				io.Writer
+				io.Reader
+			}{new(bytes.Buffer), pr},
+			LanguageGo,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		client.open(endpoint, buffer, moment)
+		params, err := json.Marshal(M{
+			"uri": pathToURI(buffer.AbsPath),
+			"diagnostics": []M{
+				{
+					"range": M{
+						"start": M{"line": 0, "character": 0},
+						"end":   M{"line": 0, "character": 0},
+					},
+					"message": "expected 'package', found 'EOF'",
+				},
+			},
+		})
+		ce(err)
+		trigger(EvLSPNotification{
+			Endpoint: endpoint,
+			Method:   "textDocument/publishDiagnostics",
+			Params:   params,
+		})
+
+		// no cell in line
+		scope.Call(ShowDiagnostics)
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			view.CursorLine, 0,
+			view.CursorCol, 0,
+		)
+	})
+}
+
+func TestMapMomentLines(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\nd\n"), func(
+		moment *Moment,
+		apply ApplyChange,
+	) {
+		m1, _ := apply(moment, Change{
+			Op:    OpDelete,
+			Begin: Position{Line: 1},
+			End:   Position{Line: 2},
+		})
+		m2, _ := apply(m1, Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: 2},
+			String: "x\ny\n",
+		})
+		eq(t,
+			m2.GetContent(), "a\nc\nx\ny\nd\n",
+			mapMomentLines(moment, m2), []int{0, 1, 1, 4},
+		)
+	})
+}
@@ -0,0 +1,271 @@
+package li
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+type LSPDocument struct {
+	Buffer     *Buffer
+	Endpoint   *LSPEndpoint
+	URI        string
+	LanguageID string
+	Version    int
+	Moment     *Moment         // last synced
+	Moments    map[int]*Moment // recently synced, by version
+}
+
+type LSPClient struct {
+	Endpoints          map[LSPServerKey]*LSPEndpoint
+	Documents          map[*Buffer]*LSPDocument
+	Versions           map[string]int                 // by uri, survives reopening
+	Diagnostics        map[string]*LSPFileDiagnostics // by path
+	DiagnosticsVersion int
+	Restarts           map[LSPServerKey]int
+
+	pendingLock sync.Mutex
+	pending     map[*Buffer][]*LSPCall // canceled when buffer changed
+
+	// Documents and synced moments are written in main loop, locked for readers in other goroutines
+	documentsLock sync.RWMutex
+}
+
+func (_ Provide) LSPClient() *LSPClient {
+	return &LSPClient{
+		Endpoints:   make(map[LSPServerKey]*LSPEndpoint),
+		Documents:   make(map[*Buffer]*LSPDocument),
+		Versions:    make(map[string]int),
+		Diagnostics: make(map[string]*LSPFileDiagnostics),
+		Restarts:    make(map[LSPServerKey]int),
+		pending:     make(map[*Buffer][]*LSPCall),
+	}
+}
+
+// cancelOnChange cancels call if moment of buffer switched before response, safe to call in any goroutine
+func (c *LSPClient) cancelOnChange(buffer *Buffer, call *LSPCall) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	calls := c.pending[buffer][:0]
+	for _, pending := range c.pending[buffer] {
+		if !pending.finished() {
+			calls = append(calls, pending)
+		}
+	}
+	c.pending[buffer] = append(calls, call)
+}
+
+func (c *LSPClient) cancelPending(buffer *Buffer) {
+	c.pendingLock.Lock()
+	calls := c.pending[buffer]
+	delete(c.pending, buffer)
+	c.pendingLock.Unlock()
+	for _, call := range calls {
+		call.Cancel()
+	}
+}
+
+// number of synced moments kept for mapping versioned responses
+const lspDocumentMoments = 64
+
+// syncedDocument returns endpoint and uri of document of buffer if moment is the last synced one, safe to call in any goroutine
+func (c *LSPClient) syncedDocument(buffer *Buffer, moment *Moment) (endpoint *LSPEndpoint, uri string, ok bool) {
+	c.documentsLock.RLock()
+	defer c.documentsLock.RUnlock()
+	doc, ok := c.Documents[buffer]
+	if !ok || doc.Moment != moment {
+		return nil, "", false
+	}
+	return doc.Endpoint, doc.URI, true
+}
+
+func (c *LSPClient) removeDocument(buffer *Buffer) {
+	c.documentsLock.Lock()
+	delete(c.Documents, buffer)
+	c.documentsLock.Unlock()
+}
+
+func (d *LSPDocument) setMoment(version int, moment *Moment) {
+	d.Version = version
+	d.Moment = moment
+	d.Moments[version] = moment
+	delete(d.Moments, version-lspDocumentMoments)
+}
+
+// document returns opened document of path
+func (c *LSPClient) document(path string) *LSPDocument {
+	for _, doc := range c.Documents {
+		if doc.Buffer.AbsPath == path {
+			return doc
+		}
+	}
+	return nil
+}
+
+func lspLanguageID(lang Language) string {
+	if spec := languageSpec(lang); spec != nil && spec.LSPLanguageID != "" {
+		return spec.LSPLanguageID
+	}
+	return strings.ToLower(languageConfigName(lang))
+}
+
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// windows drive letter
+		path = "/" + path
+	}
+	return (&url.URL{
+		Scheme: "file",
+		Path:   path,
+	}).String()
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		// windows drive letter
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// lspPosition converts position to line and utf16 character offset
+func lspPosition(moment *Moment, pos Position) M {
+	p := toLSPPosition(moment, pos)
+	return M{
+		"line":      p.Line,
+		"character": p.Character,
+	}
+}
+
+func toLSPPosition(moment *Moment, pos Position) LSPPosition {
+	character := 0
+	if line := moment.GetLine(pos.Line); line != nil {
+		if pos.Cell < len(line.Cells) {
+			character = line.Cells[pos.Cell].UTF16Offset / 2
+		} else if n := len(line.Cells); n > 0 {
+			last := line.Cells[n-1]
+			character = last.UTF16Offset/2 + len(utf16.Encode([]rune{last.Rune}))
+		}
+	}
+	return LSPPosition{
+		Line:      pos.Line,
+		Character: character,
+	}
+}
+
+// lspChangeEvent converts change applied to moment to incremental content change, ok is false if full sync is required
+func lspChangeEvent(moment *Moment, change Change) (event M, ok bool) {
+	change = resolveChange(moment, change)
+	end := change.End
+	if change.Op == OpInsert {
+		end = change.Begin
+	}
+	last := moment.NumLines() - 1
+	if change.Begin.Line >= last || end.Line >= last {
+		// changes to the last line may add line break
+		return nil, false
+	}
+	text := ""
+	if change.Op != OpDelete {
+		text = change.String
+	}
+	return M{
+		"range": M{
+			"start": lspPosition(moment, change.Begin),
+			"end":   lspPosition(moment, end),
+		},
+		"text": text,
+	}, true
+}
+
+func (c *LSPClient) open(endpoint *LSPEndpoint, buffer *Buffer, moment *Moment) {
+	if _, ok := c.Documents[buffer]; ok {
+		return
+	}
+	uri := pathToURI(buffer.AbsPath)
+	c.Versions[uri]++
+	doc := &LSPDocument{
+		Buffer:     buffer,
+		Endpoint:   endpoint,
+		URI:        uri,
+		LanguageID: lspLanguageID(buffer.language),
+		Moments:    make(map[int]*Moment),
+	}
+	doc.setMoment(c.Versions[uri], moment)
+	c.documentsLock.Lock()
+	c.Documents[buffer] = doc
+	c.documentsLock.Unlock()
+	endpoint.Notify("textDocument/didOpen", M{
+		"textDocument": M{
+			"uri":        doc.URI,
+			"languageId": doc.LanguageID,
+			"version":    doc.Version,
+			"text":       moment.GetContent(),
+		},
+	})
+}
+
+// sync sends changes from last synced moment to moment
+func (c *LSPClient) sync(buffer *Buffer, moment *Moment) {
+	doc, ok := c.Documents[buffer]
+	if !ok || doc.Moment == moment {
+		return
+	}
+	var event M
+	ok = false
+	if moment.Previous == doc.Moment {
+		event, ok = lspChangeEvent(doc.Moment, moment.Change)
+	}
+	if !ok {
+		// unrelated moment, full sync
+		event = M{
+			"text": moment.GetContent(),
+		}
+	}
+	c.Versions[doc.URI]++
+	c.documentsLock.Lock()
+	doc.setMoment(c.Versions[doc.URI], moment)
+	c.documentsLock.Unlock()
+	doc.Endpoint.Notify("textDocument/didChange", M{
+		"textDocument": M{
+			"uri":     doc.URI,
+			"version": doc.Version,
+		},
+		"contentChanges": []M{event},
+	})
+}
+
+func (c *LSPClient) save(buffer *Buffer, moment *Moment) {
+	doc, ok := c.Documents[buffer]
+	if !ok {
+		return
+	}
+	c.sync(buffer, moment)
+	doc.Endpoint.Notify("textDocument/didSave", M{
+		"textDocument": M{
+			"uri": doc.URI,
+		},
+		"text": moment.GetContent(),
+	})
+}
+
+func (c *LSPClient) close(buffer *Buffer) {
+	doc, ok := c.Documents[buffer]
+	if !ok {
+		return
+	}
+	c.removeDocument(buffer)
+	doc.Endpoint.Notify("textDocument/didClose", M{
+		"textDocument": M{
+			"uri": doc.URI,
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package li
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lspMessages decodes messages written to language server
+func lspMessages(t *testing.T, bs []byte) (ret []M) {
+	r := bufio.NewReader(bytes.NewReader(bs))
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return
+		}
+		ce(err)
+		header = strings.TrimSpace(header)
+		if !strings.HasPrefix(header, "Content-Length:") {
+			t.Fatalf("bad header %q", header)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(header[len("Content-Length:"):]))
+		ce(err)
+		_, err = r.ReadString('\n')
+		ce(err)
+		body := make([]byte, n)
+		_, err = io.ReadFull(r, body)
+		ce(err)
+		var msg M
+		ce(json.Unmarshal(body, &msg))
+		ret = append(ret, msg)
+	}
+}
+
+func TestLSPDocumentSync(t *testing.T) {
+	withEditorBytes(t, []byte("a\nfoo 世界\nb\nc\n"), func(
+		buffer *Buffer,
+		moment *Moment,
+		apply ApplyChange,
+		client *LSPClient,
+	) {
+		buffer.Path = "foo.go"
+		buffer.AbsPath = "/tmp/foo.go"
+		written := new(bytes.Buffer)
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		endpoint := NewLSPEndpoint(
+			struct {
+				io.Writer
+				io.Reader
+			}{written, pr},
+			LanguageGo,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+
+		client.open(endpoint, buffer, moment)
+
+		// incremental
+		m1, _ := apply(moment, Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: 1, Cell: 5},
+			String: "X",
+		})
+		client.sync(buffer, m1)
+		m2, _ := apply(m1, Change{
+			Op:     OpDelete,
+			Begin:  Position{Line: 0, Cell: 1},
+			Number: 2,
+		})
+		client.sync(buffer, m2)
+
+		// full, undo
+		client.sync(buffer, m1)
+
+		client.save(buffer, m1)
+		client.close(buffer)
+		client.open(endpoint, buffer, m1)
+
+		msgs := lspMessages(t, written.Bytes())
+		eq(t,
+			len(msgs), 7,
+		)
+		params := func(i int) M {
+			return msgs[i]["params"].(M)
+		}
+		doc := func(i int) M {
+			return params(i)["textDocument"].(M)
+		}
+		change := func(i int) M {
+			return params(i)["contentChanges"].([]any)[0].(M)
+		}
+		eq(t,
+			msgs[0]["method"], "textDocument/didOpen",
+			doc(0)["uri"], "file:///tmp/foo.go",
+			doc(0)["version"], float64(1),
+
+			msgs[1]["method"], "textDocument/didChange",
+			doc(1)["version"], float64(2),
+			change(1)["text"], "X",
+			change(1)["range"].(M)["start"].(M)["line"], float64(1),
+			change(1)["range"].(M)["start"].(M)["character"], float64(5),
+
+			doc(2)["version"], float64(3),
+			change(2)["text"], "",
+			change(2)["range"].(M)["end"].(M)["line"], float64(1),
+			change(2)["range"].(M)["end"].(M)["character"], float64(1),
+
+			doc(3)["version"], float64(4),
+			change(3)["range"], nil,
+			change(3)["text"], m1.GetContent(),
+
+			msgs[4]["method"], "textDocument/didSave",
+			msgs[5]["method"], "textDocument/didClose",
+
+			// version keeps increasing after reopen
+			msgs[6]["method"], "textDocument/didOpen",
+			doc(6)["version"], float64(5),
+		)
+
+		// utf16 offset
+		pos := lspPosition(m1, Position{Line: 1, Cell: 8})
+		eq(t,
+			pos["character"], 8,
+		)
+	})
+}
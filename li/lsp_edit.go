@@ -0,0 +1,253 @@
+package li
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // utf16 code units
+}
+
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+type LSPTextEdit struct {
+	Range   LSPRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type LSPTextDocumentEdit struct {
+	TextDocument struct {
+		URI     string `json:"uri"`
+		Version *int   `json:"version"`
+	} `json:"textDocument"`
+	Edits []LSPTextEdit `json:"edits"`
+	// resource operations
+	Kind string `json:"kind"`
+}
+
+type LSPWorkspaceEdit struct {
+	Changes         map[string][]LSPTextEdit `json:"changes"`
+	DocumentChanges []LSPTextDocumentEdit    `json:"documentChanges"`
+}
+
+// lspByteOffset converts line and utf16 character offset to byte offset in moment content
+func lspByteOffset(moment *Moment, pos LSPPosition) int {
+	offset := 0
+	numLines := moment.NumLines()
+	if pos.Line >= numLines {
+		for i := 0; i < numLines; i++ {
+			offset += len(moment.GetLine(i).content)
+		}
+		return offset
+	}
+	for i := 0; i < pos.Line; i++ {
+		offset += len(moment.GetLine(i).content)
+	}
+	line := moment.GetLine(pos.Line)
+	line.init()
+	character := 0
+	for _, cell := range line.Cells {
+		if character >= pos.Character || cell.Rune == '\n' {
+			break
+		}
+		character += len(utf16.Encode([]rune{cell.Rune}))
+		offset += cell.Len
+	}
+	return offset
+}
+
+// lspToPosition converts line and utf16 character offset to position in moment
+func lspToPosition(moment *Moment, pos LSPPosition) Position {
+	if pos.Line >= moment.NumLines() {
+		pos.Line = moment.NumLines() - 1
+		pos.Character = 0
+	}
+	ret := moment.ByteOffsetToPosition(lspByteOffset(moment, pos))
+	if ret.Line != pos.Line {
+		// past line end
+		ret = Position{Line: pos.Line}
+	}
+	return ret
+}
+
+// applyTextEdits applies edits as a single change, edits refer to positions in moment
+func applyTextEdits(
+	apply ApplyChange,
+	moment *Moment,
+	edits []LSPTextEdit,
+) (*Moment, error) {
+	if len(edits) == 0 {
+		return moment, nil
+	}
+
+	type span struct {
+		begin int
+		end   int
+		text  string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, edit := range edits {
+		s := span{
+			begin: lspByteOffset(moment, edit.Range.Start),
+			end:   lspByteOffset(moment, edit.Range.End),
+			text:  edit.NewText,
+		}
+		if s.end < s.begin {
+			return nil, fmt.Errorf("bad edit range: %+v", edit.Range)
+		}
+		spans = append(spans, s)
+	}
+	// stable, so inserts at the same position keep their order
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].begin < spans[j].begin
+	})
+
+	content := moment.GetContent()
+	begin := spans[0].begin
+	end := begin
+	buf := new(strings.Builder)
+	for _, s := range spans {
+		if s.begin < end {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+		buf.WriteString(content[end:s.begin])
+		buf.WriteString(s.text)
+		end = s.end
+	}
+	text := buf.String()
+	if content[begin:end] == text {
+		return moment, nil
+	}
+	if end == len(content) && end > 0 {
+		// moment keeps the trailing line break, change the text before it instead
+		if !strings.HasSuffix(content[:begin]+text, "\n") {
+			text += "\n"
+		}
+		if begin == end || text == "" {
+			begin--
+			text = content[begin:begin+1] + text
+		}
+		end--
+		text = text[:len(text)-1]
+	}
+
+	newMoment, _ := apply(moment, Change{
+		Op:     OpReplace,
+		Begin:  moment.ByteOffsetToPosition(begin),
+		End:    moment.ByteOffsetToPosition(end),
+		String: text,
+	})
+	return newMoment, nil
+}
+
+type ApplyWorkspaceEdit func(edit LSPWorkspaceEdit) error
+
+func (_ Provide) ApplyWorkspaceEdit(
+	scope Scope,
+	views Views,
+	cur CurrentView,
+	newBuffer NewBufferFromFile,
+	newView NewViewFromBuffer,
+	apply ApplyChange,
+	client *LSPClient,
+) ApplyWorkspaceEdit {
+	return func(edit LSPWorkspaceEdit) (err error) {
+		defer he(&err)
+
+		type docEdits struct {
+			path    string
+			version *int
+			edits   []LSPTextEdit
+		}
+		var docs []docEdits
+		for _, change := range edit.DocumentChanges {
+			if change.Kind != "" {
+				return fmt.Errorf("resource operation %s is not supported", change.Kind)
+			}
+			docs = append(docs, docEdits{
+				path:    uriToPath(change.TextDocument.URI),
+				version: change.TextDocument.Version,
+				edits:   change.Edits,
+			})
+		}
+		if len(edit.DocumentChanges) == 0 {
+			uris := make([]string, 0, len(edit.Changes))
+			for uri := range edit.Changes {
+				uris = append(uris, uri)
+			}
+			sort.Strings(uris)
+			for _, uri := range uris {
+				docs = append(docs, docEdits{
+					path:  uriToPath(uri),
+					edits: edit.Changes[uri],
+				})
+			}
+		}
+
+		// find or open views, and check versions before changing anything
+		current := cur()
+		targets := make([]*View, len(docs))
+		for i, doc := range docs {
+			for _, view := range views {
+				if view.Buffer.AbsPath == doc.path {
+					targets[i] = view
+					break
+				}
+			}
+			if targets[i] == nil {
+				buffer, err := newBuffer(doc.path)
+				ce(err)
+				view, err := newView(buffer)
+				ce(err)
+				targets[i] = view
+			}
+			if doc.version != nil {
+				if d, ok := client.Documents[targets[i].Buffer]; ok && d.Version != *doc.version {
+					return fmt.Errorf("%s changed since version %d", doc.path, *doc.version)
+				}
+			}
+		}
+		if current != nil && cur() != current {
+			cur(current)
+		}
+
+		// compute all new moments before switching any view
+		newMoments := make([]*Moment, len(docs))
+		pending := make(map[*Buffer]*Moment)
+		for i, doc := range docs {
+			buffer := targets[i].Buffer
+			moment, ok := pending[buffer]
+			if !ok {
+				moment = targets[i].GetMoment()
+			}
+			newMoment, err := applyTextEdits(apply, moment, doc.edits)
+			if err != nil {
+				return fmt.Errorf("%s: %w", doc.path, err)
+			}
+			newMoments[i] = newMoment
+			pending[buffer] = newMoment
+		}
+
+		for i, newMoment := range newMoments {
+			target := targets[i]
+			moment := target.GetMoment()
+			if newMoment == moment {
+				continue
+			}
+			for _, view := range views {
+				if view.Buffer == target.Buffer && view.GetMoment() == moment {
+					view.switchMoment(scope, newMoment)
+				}
+			}
+		}
+
+		return
+	}
+}
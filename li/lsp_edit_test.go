@@ -0,0 +1,168 @@
+package li
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyTextEdits(t *testing.T) {
+	withEditorBytes(t, []byte("foo 世界 bar\nbaz\n"), func(
+		moment *Moment,
+		apply ApplyChange,
+	) {
+		edit := func(l1, c1, l2, c2 int, text string) LSPTextEdit {
+			return LSPTextEdit{
+				Range: LSPRange{
+					Start: LSPPosition{Line: l1, Character: c1},
+					End:   LSPPosition{Line: l2, Character: c2},
+				},
+				NewText: text,
+			}
+		}
+
+		// out of order, utf16 offsets
+		m, err := applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(0, 7, 0, 10, "qux"),
+			edit(0, 0, 0, 3, "FOO"),
+			edit(1, 0, 1, 0, "> "),
+		})
+		ce(err)
+		eq(t,
+			m.GetContent(), "FOO 世界 qux\n> baz\n",
+			m.Previous == moment, true,
+		)
+
+		// to end of document
+		m, err = applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(0, 3, 2, 0, "\n"),
+		})
+		ce(err)
+		eq(t,
+			m.GetContent(), "foo\n",
+		)
+
+		m, err = applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(1, 0, 2, 0, ""),
+		})
+		ce(err)
+		eq(t,
+			m.GetContent(), "foo 世界 bar\n",
+		)
+		m, err = applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(2, 0, 2, 0, "qux\n"),
+		})
+		ce(err)
+		eq(t,
+			m.GetContent(), "foo 世界 bar\nbaz\nqux\n",
+		)
+
+		// no-op
+		m, err = applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(1, 0, 1, 3, "baz"),
+		})
+		ce(err)
+		eq(t,
+			m == moment, true,
+		)
+
+		// overlapping
+		_, err = applyTextEdits(apply, moment, []LSPTextEdit{
+			edit(0, 0, 0, 5, ""),
+			edit(0, 3, 0, 6, ""),
+		})
+		eq(t,
+			err != nil, true,
+		)
+	})
+}
+
+func TestApplyWorkspaceEdit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	ce(err)
+	defer os.RemoveAll(dir)
+	fooPath := filepath.Join(dir, "foo")
+	barPath := filepath.Join(dir, "bar")
+	ce(ioutil.WriteFile(fooPath, []byte("foo\n"), 0644))
+	ce(ioutil.WriteFile(barPath, []byte("bar\n"), 0644))
+
+	withEditor(func(
+		newBuffer NewBufferFromFile,
+		newView NewViewFromBuffer,
+		cur CurrentView,
+		views Views,
+		applyEdit ApplyWorkspaceEdit,
+	) {
+		buffer, err := newBuffer(fooPath)
+		ce(err)
+		view, err := newView(buffer)
+		ce(err)
+
+		ce(applyEdit(LSPWorkspaceEdit{
+			Changes: map[string][]LSPTextEdit{
+				pathToURI(fooPath): {
+					{
+						Range:   LSPRange{End: LSPPosition{Character: 3}},
+						NewText: "FOO",
+					},
+				},
+				pathToURI(barPath): {
+					{
+						Range:   LSPRange{Start: LSPPosition{Character: 3}, End: LSPPosition{Character: 3}},
+						NewText: "!",
+					},
+				},
+			},
+		}))
+
+		eq(t,
+			view.GetMoment().GetContent(), "FOO\n",
+			cur() == view, true,
+			len(views), 2,
+		)
+		for _, v := range views {
+			if v != view {
+				eq(t,
+					v.Buffer.AbsPath, barPath,
+					v.GetMoment().GetContent(), "bar!\n",
+				)
+			}
+		}
+
+		// invalid edit of the second document leaves the first untouched
+		doc := func(path string, edit LSPTextEdit) (ret LSPTextDocumentEdit) {
+			ret.TextDocument.URI = pathToURI(path)
+			ret.Edits = []LSPTextEdit{edit}
+			return
+		}
+		fooMoment := view.GetMoment()
+		err = applyEdit(LSPWorkspaceEdit{
+			DocumentChanges: []LSPTextDocumentEdit{
+				doc(fooPath, LSPTextEdit{
+					Range:   LSPRange{End: LSPPosition{Character: 3}},
+					NewText: "foo",
+				}),
+				doc(barPath, LSPTextEdit{
+					Range:   LSPRange{Start: LSPPosition{Character: 2}, End: LSPPosition{Character: 1}},
+					NewText: "?",
+				}),
+			},
+		})
+		eq(t,
+			err != nil, true,
+			view.GetMoment() == fooMoment, true,
+			view.GetMoment().GetContent(), "FOO\n",
+		)
+
+		// resource operations are not supported
+		err = applyEdit(LSPWorkspaceEdit{
+			DocumentChanges: []LSPTextDocumentEdit{
+				{Kind: "create"},
+			},
+		})
+		eq(t,
+			err != nil, true,
+		)
+	})
+}
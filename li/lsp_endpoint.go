@@ -0,0 +1,426 @@
+package li
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLSPTimeout  = errors.New("language server request timeout")
+	ErrLSPCanceled = errors.New("language server request canceled")
+	ErrLSPClosed   = errors.New("language server closed")
+)
+
+const lspDefaultTimeout = time.Second * 10
+
+type LSPEndpoint struct {
+	sync.Mutex
+
+	Language Language
+	Root     string
+	Settings map[string]any // for workspace/configuration
+	Timeout  time.Duration  // of requests, lspDefaultTimeout if zero
+	Process  *os.Process    // killed if not exited on shutdown
+	// capabilities of server, from initialize result
+	Capabilities json.RawMessage
+	RW           io.ReadWriter
+	OnErr        func(error)
+	OnLog        func(format string, args ...any)
+	OnNotify     func(method string, params json.RawMessage)
+	// requests from server, must be responded by Respond
+	OnRequest func(id json.RawMessage, method string, params json.RawMessage)
+
+	writeLock sync.Mutex
+	calls     map[int64]*LSPCall
+	nextReqID int64
+	done      chan struct{}
+	closeErr  error
+	stopping  bool
+}
+
+type LSPCall struct {
+	endpoint *LSPEndpoint
+	id       int64
+	method   string
+	done     chan struct{}
+	bs       []byte
+	err      error
+}
+
+func NewLSPEndpoint(
+	rw io.ReadWriter,
+	lang Language,
+	onErr func(error),
+	onLog func(format string, args ...any),
+	onNotify func(method string, params json.RawMessage),
+	onRequest func(id json.RawMessage, method string, params json.RawMessage),
+) *LSPEndpoint {
+	endpoint := &LSPEndpoint{
+		Language: lang,
+		RW:       rw,
+		OnErr:    onErr,
+		OnLog:    onLog,
+		OnNotify: onNotify,
+
+		OnRequest: onRequest,
+
+		calls: make(map[int64]*LSPCall),
+		done:  make(chan struct{}),
+	}
+	go endpoint.startHandler()
+	return endpoint
+}
+
+// Done is closed when the connection is closed
+func (l *LSPEndpoint) Done() <-chan struct{} {
+	return l.done
+}
+
+// Stopping reports whether Shutdown is called
+func (l *LSPEndpoint) Stopping() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.stopping
+}
+
+func (l *LSPEndpoint) closed() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.closeErr != nil
+}
+
+func (l *LSPEndpoint) write(data M) error {
+	bs, err := json.Marshal(data)
+	ce(err)
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	if _, err := io.WriteString(l.RW, fmt.Sprintf("Content-Length: %d\r\n\r\n", len(bs))); err != nil {
+		if l.OnErr != nil {
+			l.OnErr(err)
+		}
+		return err
+	}
+	if _, err := l.RW.Write(bs); err != nil {
+		if l.OnErr != nil {
+			l.OnErr(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (l *LSPEndpoint) Req(method string, params M) *LSPCall {
+	l.Lock()
+	id := l.nextReqID
+	l.nextReqID++
+	call := &LSPCall{
+		endpoint: l,
+		id:       id,
+		method:   method,
+		done:     make(chan struct{}),
+	}
+	if l.closeErr != nil {
+		call.err = l.closeErr
+		close(call.done)
+		l.Unlock()
+		return call
+	}
+	l.calls[id] = call
+	l.Unlock()
+
+	if err := l.write(M{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}); err != nil {
+		l.finishCall(id, nil, err)
+	}
+	return call
+}
+
+// finishCall sets response or error of pending call, returns false if not pending
+func (l *LSPEndpoint) finishCall(id int64, bs []byte, err error) bool {
+	l.Lock()
+	call, ok := l.calls[id]
+	if ok {
+		delete(l.calls, id)
+	}
+	l.Unlock()
+	if !ok {
+		return false
+	}
+	call.bs = bs
+	call.err = err
+	close(call.done)
+	return true
+}
+
+func (l *LSPEndpoint) Notify(method string, params M) {
+	if l.closed() {
+		return
+	}
+	l.write(M{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// Respond sends response of request from server
+func (l *LSPEndpoint) Respond(id json.RawMessage, result any, respErr error) {
+	if l.closed() {
+		return
+	}
+	data := M{
+		"jsonrpc": "2.0",
+		"id":      id,
+	}
+	var lspErr *LSPResponseError
+	if errors.As(respErr, &lspErr) {
+		data["error"] = M{
+			"code":    lspErr.Code,
+			"message": lspErr.Message,
+		}
+	} else if respErr != nil {
+		data["error"] = M{
+			"code":    -32603, // internal error
+			"message": respErr.Error(),
+		}
+	} else {
+		data["result"] = result
+	}
+	l.write(data)
+}
+
+// Shutdown requests server to exit, and kills the process if not exited after timeout
+func (l *LSPEndpoint) Shutdown(timeout time.Duration) {
+	l.Lock()
+	if l.closeErr != nil || l.stopping {
+		l.Unlock()
+		return
+	}
+	l.stopping = true
+	l.Unlock()
+
+	call := l.Req("shutdown", nil)
+	select {
+	case <-call.done:
+	case <-time.After(timeout):
+		call.Cancel()
+	}
+	l.Notify("exit", nil)
+
+	select {
+	case <-l.done:
+	case <-time.After(timeout):
+		if l.Process != nil {
+			l.Process.Kill()
+		}
+	}
+}
+
+func (l *LSPEndpoint) startHandler() {
+	r := bufio.NewReader(l.RW)
+	var err error
+	var contentLen int
+	for {
+		var header string
+		header, err = r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		header = strings.TrimSpace(header)
+
+		if strings.HasPrefix(header, "Content-Length:") {
+			contentLen, err = strconv.Atoi(
+				strings.TrimSpace(header[len("Content-Length:"):]),
+			)
+			if err != nil {
+				break
+			}
+
+		} else if len(header) > 0 {
+			continue
+
+		} else if len(header) == 0 {
+			bs := make([]byte, contentLen)
+			if _, err = io.ReadFull(r, bs); err != nil {
+				break
+			}
+			var data struct {
+				ID     json.RawMessage
+				Method string
+				Params json.RawMessage
+			}
+			if err = json.Unmarshal(bs, &data); err != nil {
+				break
+			}
+
+			if len(data.ID) > 0 && data.Method != "" {
+				// request
+				if l.OnRequest != nil {
+					l.OnRequest(data.ID, data.Method, data.Params)
+				} else {
+					go l.Respond(data.ID, nil, nil)
+				}
+
+			} else if len(data.ID) > 0 {
+				// response
+				var id int64
+				if err := json.Unmarshal(data.ID, &id); err != nil {
+					continue
+				}
+				l.finishCall(id, bs, nil)
+
+			} else if data.Method == "window/logMessage" {
+				var params struct {
+					Type    LSPMessageType
+					Message string
+				}
+				if err = json.Unmarshal(data.Params, &params); err != nil {
+					break
+				}
+				if params.Type <= LSPWarning && l.OnLog != nil {
+					l.OnLog("%s - %s: %s", l.Language, params.Type, params.Message)
+				}
+
+			} else if data.Method != "" {
+				// notification
+				if l.OnNotify != nil {
+					l.OnNotify(data.Method, data.Params)
+				}
+
+			}
+
+		}
+
+	}
+
+	// fail pending calls
+	l.Lock()
+	if err == nil || err == io.EOF {
+		l.closeErr = ErrLSPClosed
+	} else {
+		l.closeErr = fmt.Errorf("%w: %v", ErrLSPClosed, err)
+	}
+	stopping := l.stopping
+	var ids []int64
+	for id := range l.calls {
+		ids = append(ids, id)
+	}
+	l.Unlock()
+	for _, id := range ids {
+		l.finishCall(id, nil, l.closeErr)
+	}
+	if err != nil && !stopping {
+		if l.OnErr != nil {
+			l.OnErr(err)
+		}
+	}
+	close(l.done)
+}
+
+type LSPMessageType uint8
+
+const (
+	LSPError LSPMessageType = iota + 1
+	LSPWarning
+	LSPInfo
+	LSPLog
+)
+
+// wait blocks until response, cancellation or timeout
+func (c *LSPCall) wait() error {
+	timeout := c.endpoint.Timeout
+	if timeout == 0 {
+		timeout = lspDefaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+	case <-timer.C:
+		if c.endpoint.finishCall(c.id, nil, fmt.Errorf("%s: %w", c.method, ErrLSPTimeout)) {
+			c.endpoint.Notify("$/cancelRequest", M{
+				"id": c.id,
+			})
+		}
+		<-c.done
+	}
+	return c.err
+}
+
+// Cancel sends $/cancelRequest if call is pending, waiting calls return ErrLSPCanceled
+func (c *LSPCall) Cancel() {
+	if c.endpoint.finishCall(c.id, nil, fmt.Errorf("%s: %w", c.method, ErrLSPCanceled)) {
+		c.endpoint.Notify("$/cancelRequest", M{
+			"id": c.id,
+		})
+	}
+}
+
+// finished reports whether response or error is set
+func (c *LSPCall) finished() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *LSPCall) Unmarshal(target any) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(c.bs, target); err != nil {
+		return err
+	}
+	return nil
+}
+
+type LSPResponseError struct {
+	Code    int
+	Message string
+}
+
+const lspMethodNotFound = -32601
+
+func (e *LSPResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Result unmarshals result of response to target, response error is returned as *LSPResponseError
+func (c *LSPCall) Result(target any) error {
+	var res struct {
+		Result json.RawMessage
+		Error  *LSPResponseError
+	}
+	if err := c.Unmarshal(&res); err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if target == nil || len(res.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result, target)
+}
+
+// Then calls fn in new goroutine after call finished
+func (c *LSPCall) Then(fn func(*LSPCall)) {
+	go func() {
+		c.wait()
+		fn(c)
+	}()
+}
@@ -0,0 +1,404 @@
+package li
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLSPNoResponse is returned by fake server handlers to leave requests unanswered
+var fakeLSPNoResponse = new(struct{})
+
+// fakeLSPServer returns endpoint connected to an in-process server, handle returns result of requests
+func fakeLSPServer(
+	t *testing.T,
+	handle func(method string, params json.RawMessage) any,
+) *LSPEndpoint {
+	endpoint, _ := fakeLSPServerWithClose(t, handle)
+	return endpoint
+}
+
+// fakeLSPServerWithClose also returns a function closing the server side, like a crash
+func fakeLSPServerWithClose(
+	t *testing.T,
+	handle func(method string, params json.RawMessage) any,
+) (*LSPEndpoint, func()) {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	closeServer := func() {
+		serverWriter.Close()
+		serverReader.Close()
+	}
+	t.Cleanup(func() {
+		clientWriter.Close()
+		closeServer()
+	})
+
+	go func() {
+		r := bufio.NewReader(serverReader)
+		length := 0
+		for {
+			header, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			header = strings.TrimSpace(header)
+			if strings.HasPrefix(header, "Content-Length:") {
+				length, err = strconv.Atoi(strings.TrimSpace(header[len("Content-Length:"):]))
+				if err != nil {
+					return
+				}
+				continue
+			} else if header != "" {
+				continue
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg struct {
+				ID     *int64
+				Method string
+				Params json.RawMessage
+			}
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return
+			}
+			if msg.Method == "" {
+				// response
+				continue
+			}
+			result := handle(msg.Method, msg.Params)
+			if msg.Method == "exit" {
+				closeServer()
+				return
+			}
+			if msg.ID == nil || result == fakeLSPNoResponse {
+				continue
+			}
+			bs, err := json.Marshal(M{
+				"jsonrpc": "2.0",
+				"id":      *msg.ID,
+				"result":  result,
+			})
+			if err != nil {
+				return
+			}
+			if _, err := io.WriteString(
+				serverWriter,
+				"Content-Length: "+strconv.Itoa(len(bs))+"\r\n\r\n"+string(bs),
+			); err != nil {
+				return
+			}
+		}
+	}()
+
+	return NewLSPEndpoint(
+		struct {
+			io.Writer
+			io.Reader
+		}{clientWriter, clientReader},
+		LanguageGo,
+		nil,
+		nil,
+		nil,
+		nil,
+	), closeServer
+}
+
+func TestLSPEndpointRequest(t *testing.T) {
+	notified := make(chan string, 1)
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "echo":
+			var p struct {
+				Text string
+			}
+			ce(json.Unmarshal(params, &p))
+			return p.Text
+		case "notify":
+			notified <- method
+		}
+		return nil
+	})
+
+	var text string
+	ce(endpoint.Req("echo", M{"text": "foo"}).Result(&text))
+	eq(t,
+		text, "foo",
+	)
+
+	// concurrent
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		i := i
+		go func() {
+			var text string
+			err := endpoint.Req("echo", M{"text": strconv.Itoa(i)}).Result(&text)
+			if err == nil && text != strconv.Itoa(i) {
+				err = errors.New("bad response " + text)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		ce(<-errs)
+	}
+
+	// then
+	done := make(chan string, 1)
+	endpoint.Req("echo", M{"text": "bar"}).Then(func(call *LSPCall) {
+		var text string
+		ce(call.Result(&text))
+		done <- text
+	})
+	eq(t,
+		<-done, "bar",
+	)
+
+	endpoint.Notify("notify", nil)
+	eq(t,
+		<-notified, "notify",
+	)
+}
+
+func TestLSPEndpointTimeout(t *testing.T) {
+	canceled := make(chan int64, 1)
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "hang":
+			return fakeLSPNoResponse
+		case "$/cancelRequest":
+			var p struct {
+				ID int64
+			}
+			ce(json.Unmarshal(params, &p))
+			canceled <- p.ID
+		}
+		return nil
+	})
+	endpoint.Timeout = time.Millisecond * 50
+
+	call := endpoint.Req("hang", nil)
+	err := call.Result(nil)
+	eq(t,
+		errors.Is(err, ErrLSPTimeout), true,
+		<-canceled, call.id,
+	)
+
+	// not affecting later requests
+	ce(endpoint.Req("ok", nil).Result(nil))
+}
+
+func TestLSPEndpointCancel(t *testing.T) {
+	canceled := make(chan int64, 1)
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "hang":
+			return fakeLSPNoResponse
+		case "$/cancelRequest":
+			var p struct {
+				ID int64
+			}
+			ce(json.Unmarshal(params, &p))
+			canceled <- p.ID
+		}
+		return nil
+	})
+
+	call := endpoint.Req("hang", nil)
+	done := make(chan error, 1)
+	call.Then(func(call *LSPCall) {
+		done <- call.Result(nil)
+	})
+	call.Cancel()
+	eq(t,
+		errors.Is(<-done, ErrLSPCanceled), true,
+		<-canceled, call.id,
+		call.finished(), true,
+	)
+
+	// canceling finished call is no-op
+	call = endpoint.Req("ok", nil)
+	ce(call.Result(nil))
+	call.Cancel()
+	ce(call.Result(nil))
+}
+
+func TestLSPEndpointClosed(t *testing.T) {
+	endpoint, closeServer := fakeLSPServerWithClose(t, func(method string, params json.RawMessage) any {
+		return fakeLSPNoResponse
+	})
+	var errs []error
+	endpoint.OnErr = func(err error) {
+		errs = append(errs, err)
+	}
+
+	call := endpoint.Req("hang", nil)
+	closeServer()
+	err := call.Result(nil)
+	<-endpoint.Done()
+	eq(t,
+		errors.Is(err, ErrLSPClosed), true,
+		errors.Is(endpoint.Req("foo", nil).Result(nil), ErrLSPClosed), true,
+		len(errs) > 0, true,
+		endpoint.Stopping(), false,
+	)
+	// no panic
+	endpoint.Notify("foo", nil)
+}
+
+func TestLSPEndpointShutdown(t *testing.T) {
+	methods := make(chan string, 2)
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		methods <- method
+		return nil
+	})
+	var errs []error
+	endpoint.OnErr = func(err error) {
+		errs = append(errs, err)
+	}
+
+	endpoint.Shutdown(time.Second)
+	select {
+	case <-endpoint.Done():
+	default:
+		t.Fatal("not closed")
+	}
+	eq(t,
+		<-methods, "shutdown",
+		<-methods, "exit",
+		endpoint.Stopping(), true,
+		len(errs), 0,
+	)
+	// idempotent
+	endpoint.Shutdown(time.Second)
+}
+
+func TestLSPClientCancelPending(t *testing.T) {
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		if method == "hang" {
+			return fakeLSPNoResponse
+		}
+		return nil
+	})
+	withEditorBytes(t, []byte("foo\n"), func(
+		buffer *Buffer,
+		client *LSPClient,
+	) {
+		done := endpoint.Req("ok", nil)
+		ce(done.Result(nil))
+		hang := endpoint.Req("hang", nil)
+		client.cancelOnChange(buffer, done)
+		client.cancelOnChange(buffer, hang)
+		eq(t,
+			len(client.pending[buffer]), 1,
+		)
+		client.cancelPending(buffer)
+		eq(t,
+			errors.Is(hang.Result(nil), ErrLSPCanceled), true,
+			done.Result(nil), nil,
+			len(client.pending[buffer]), 0,
+		)
+	})
+}
+
+func TestLSPServerRestart(t *testing.T) {
+	defer func(delay time.Duration) {
+		lspRestartDelay = delay
+	}(lspRestartDelay)
+	lspRestartDelay = time.Millisecond * 50
+
+	withEditorBytes(t, []byte("foo\n"), func(
+		buffer *Buffer,
+		moment *Moment,
+		client *LSPClient,
+		run RunInMainLoop,
+		j AppendJournal,
+		derive Derive,
+		ctrl func(string),
+	) {
+		key := LSPServerKey{
+			Language: LanguageGo,
+		}
+		opened := make(chan *LSPEndpoint, 16)
+		var endpoints []*LSPEndpoint
+		var closes []func()
+		var startTimes []time.Time
+		start := func(key LSPServerKey) (*LSPEndpoint, error) {
+			var endpoint *LSPEndpoint
+			endpoint, closeServer := fakeLSPServerWithClose(t, func(method string, params json.RawMessage) any {
+				if method == "textDocument/didOpen" {
+					opened <- endpoint
+				}
+				return nil
+			})
+			endpoints = append(endpoints, endpoint)
+			closes = append(closes, closeServer)
+			startTimes = append(startTimes, time.Now())
+			superviseLanguageServer(client, run, j, key, endpoint, func() {})
+			return endpoint, nil
+		}
+		derive(func() StartLanguageServer {
+			return start
+		})
+		ctrl("loop")
+
+		wait := func(fn func() bool) {
+			deadline := time.Now().Add(time.Second * 5)
+			for !fn() && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond * 10)
+				ctrl("loop")
+			}
+		}
+
+		endpoint, err := start(key)
+		ce(err)
+		client.Endpoints[key] = endpoint
+		client.open(endpoint, buffer, moment)
+		eq(t,
+			<-opened == endpoint, true,
+		)
+
+		for i := 1; i <= 2; i++ {
+			// exit unexpectedly
+			exitAt := time.Now()
+			closes[i-1]()
+			wait(func() bool {
+				return len(endpoints) > i
+			})
+			endpoint := endpoints[i]
+			eq(t,
+				len(endpoints), i+1,
+				client.Endpoints[key] == endpoint, true,
+				client.Restarts[key], i,
+				client.Documents[buffer].Endpoint == endpoint, true,
+				<-opened == endpoint, true,
+				// delay doubled on each restart
+				startTimes[i].Sub(exitAt) >= lspRestartDelay<<(i-1), true,
+			)
+		}
+
+		// not restarting after too many exits
+		client.Restarts[key] = lspMaxRestarts
+		closes[2]()
+		wait(func() bool {
+			_, ok := client.Endpoints[key]
+			return !ok
+		})
+		time.Sleep(lspRestartDelay * 2)
+		ctrl("loop")
+		_, ok := client.Endpoints[key]
+		_, docOK := client.Documents[buffer]
+		eq(t,
+			ok, false,
+			docOK, false,
+			len(endpoints), 3,
+		)
+	})
+}
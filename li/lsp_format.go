@@ -0,0 +1,81 @@
+package li
+
+import "errors"
+
+type FormatWithLanguageServer func(view *View)
+
+func (_ Provide) FormatWithLanguageServer(
+	client *LSPClient,
+	config BufferConfig,
+	run RunInMainLoop,
+	j AppendJournal,
+) FormatWithLanguageServer {
+	return func(view *View) {
+		doc, ok := client.Documents[view.Buffer]
+		if !ok {
+			return
+		}
+		moment := view.GetMoment()
+		client.sync(view.Buffer, moment)
+		call := doc.Endpoint.Req("textDocument/formatting", M{
+			"textDocument": M{
+				"uri": doc.URI,
+			},
+			"options": M{
+				"tabSize":      config.TabWidth,
+				"insertSpaces": config.ExpandTabs,
+			},
+		})
+		client.cancelOnChange(view.Buffer, call)
+		call.Then(func(c *LSPCall) {
+			var edits []LSPTextEdit
+			if err := c.Result(&edits); errors.Is(err, ErrLSPCanceled) {
+				return
+			} else if err != nil {
+				j("format %s: %v", view.Buffer.Path, err)
+				return
+			}
+			if len(edits) == 0 {
+				return
+			}
+			run(func(
+				scope Scope,
+				apply ApplyChange,
+			) {
+				if view.GetMoment() != moment {
+					// changed while formatting
+					return
+				}
+				newMoment, err := applyTextEdits(apply, moment, edits)
+				if err != nil {
+					j("format %s: %v", view.Buffer.Path, err)
+					return
+				}
+				if newMoment != moment {
+					view.switchMoment(scope, newMoment)
+				}
+			})
+		})
+	}
+}
+
+func (_ Command) FormatWithLanguageServer() (spec CommandSpec) {
+	spec.Desc = "format current buffer with language server"
+	spec.Func = func(
+		cur CurrentView,
+		client *LSPClient,
+		format FormatWithLanguageServer,
+		show ShowMessage,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		if _, ok := client.Documents[view.Buffer]; !ok {
+			show([]string{"no language server for " + view.Buffer.Path})
+			return
+		}
+		format(view)
+	}
+	return
+}
@@ -0,0 +1,404 @@
+package li
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+)
+
+// LSPPopup shows styled lines near the cursor
+type LSPPopup struct {
+	Box    Box
+	Lines  []MarkdownLine
+	Offset int
+}
+
+var _ Element = new(LSPPopup)
+
+var _ KeyStrokeHandler = new(LSPPopup)
+
+func (p *LSPPopup) RenderFunc() any {
+	return func(
+		getStyle GetStyle,
+	) Element {
+		style := getStyle("Popup")
+		styles := map[MarkdownStyle]StyleFunc{
+			MarkdownPlain:   style,
+			MarkdownBold:    style.SetBold(true),
+			MarkdownCode:    style.And(getStyle("PopupCode")),
+			MarkdownHeading: style.SetBold(true).SetUnderline(true),
+		}
+
+		box := p.Box
+		box.Left++
+		box.Bottom = box.Top + 1
+		var texts []Element
+		for i := p.Offset; i < len(p.Lines) && box.Top < p.Box.Bottom; i++ {
+			line := p.Lines[i]
+			texts = append(texts, Text(
+				box,
+				string(line.Runes),
+				OffsetStyleFunc(func(i int) StyleFunc {
+					if i < len(line.Styles) {
+						return styles[line.Styles[i]]
+					}
+					return style
+				}),
+			))
+			box.Top++
+			box.Bottom++
+		}
+
+		return Rect(
+			p.Box,
+			Fill(true),
+			style,
+			texts,
+		)
+	}
+}
+
+func (p *LSPPopup) StrokeSpecs() any {
+	return func() []StrokeSpec {
+		scroll := func(n int) func() {
+			return func() {
+				p.Offset += n
+				if max := len(p.Lines) - p.Box.Height(); p.Offset > max {
+					p.Offset = max
+				}
+				if p.Offset < 0 {
+					p.Offset = 0
+				}
+			}
+		}
+		return []StrokeSpec{
+			{
+				Sequence: []string{"Ctrl+N"},
+				Func:     scroll(1),
+			},
+			{
+				Sequence: []string{"Ctrl+P"},
+				Func:     scroll(-1),
+			},
+		}
+	}
+}
+
+// lspPopupBox returns box near cursor of view that fits lines
+func lspPopupBox(
+	view *View,
+	moment *Moment,
+	cursorPosition ViewCursorScreenPosition,
+	maxWidth Width,
+	maxHeight Height,
+	lines []MarkdownLine,
+) Box {
+	width := 0
+	for _, line := range lines {
+		if w := displayWidth(string(line.Runes)); w > width {
+			width = w
+		}
+	}
+	width += 2 // padding
+	if width > int(maxWidth)-10 {
+		width = int(maxWidth) - 10
+	}
+	cursorX, cursorY := cursorPosition(view, moment)
+	height := len(lines)
+	below := true
+	var maxH int
+	if cursorY < int(maxHeight)/2 {
+		maxH = int(maxHeight) - cursorY - 1
+	} else {
+		below = false
+		maxH = cursorY
+	}
+	if max := int(maxHeight) / 2; maxH > max {
+		maxH = max
+	}
+	if height > maxH {
+		height = maxH
+	}
+	left := cursorX - 1
+	if left+width > int(maxWidth) {
+		left = int(maxWidth) - width
+	}
+	if left < 0 {
+		left = 0
+	}
+	top := cursorY + 1
+	bottom := top + height
+	if !below {
+		bottom = cursorY
+		top = bottom - height
+	}
+	return Box{top, left, bottom, left + width}
+}
+
+// lspPopupWidth is the width markdown wraps at
+func lspPopupWidth(maxWidth Width) int {
+	width := int(maxWidth) - 12
+	if width > 80 {
+		width = 80
+	}
+	return width
+}
+
+type LSPPopups struct {
+	Hover     ID
+	Signature ID
+}
+
+func (_ Provide) LSPPopups() *LSPPopups {
+	return new(LSPPopups)
+}
+
+// hoverMarkdown converts contents of hover result to markdown
+func hoverMarkdown(contents json.RawMessage) string {
+	var markup struct {
+		Kind     string
+		Language string
+		Value    *string
+	}
+	if err := json.Unmarshal(contents, &markup); err == nil && markup.Value != nil {
+		if markup.Language != "" {
+			return "```" + markup.Language + "\n" + *markup.Value + "\n```"
+		}
+		if markup.Kind == "plaintext" {
+			return "```\n" + *markup.Value + "\n```"
+		}
+		return *markup.Value
+	}
+	var str string
+	if err := json.Unmarshal(contents, &str); err == nil {
+		return str
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(contents, &list); err == nil {
+		var parts []string
+		for _, item := range list {
+			parts = append(parts, hoverMarkdown(item))
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+func ShowHover(
+	cur CurrentView,
+	client *LSPClient,
+	popups *LSPPopups,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowMessage,
+	cursorPosition ViewCursorScreenPosition,
+	maxWidth Width,
+	maxHeight Height,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	call, err := lspRequestAtCursor(client, view, "textDocument/hover", nil)
+	if err != nil {
+		show([]string{err.Error()})
+		return
+	}
+	var result *struct {
+		Contents json.RawMessage
+	}
+	if err := call.Result(&result); err != nil {
+		show(strings.Split(err.Error(), "\n"))
+		return
+	}
+	if popups.Hover > 0 {
+		closeOverlay(popups.Hover)
+		popups.Hover = 0
+	}
+	if result == nil {
+		return
+	}
+	lines := renderMarkdown(hoverMarkdown(result.Contents), lspPopupWidth(maxWidth))
+	if len(lines) == 0 {
+		return
+	}
+	popups.Hover = pushOverlay(OverlayObject(&LSPPopup{
+		Box:   lspPopupBox(view, view.GetMoment(), cursorPosition, maxWidth, maxHeight, lines),
+		Lines: lines,
+	}))
+}
+
+func (_ Command) ShowHover() (spec CommandSpec) {
+	spec.Desc = "show documentation of symbol under cursor"
+	spec.Func = ShowHover
+	return
+}
+
+type LSPSignatureHelp struct {
+	Signatures []struct {
+		Label         string
+		Documentation json.RawMessage
+		Parameters    []struct {
+			Label json.RawMessage
+		}
+		ActiveParameter *int
+	}
+	ActiveSignature int
+	ActiveParameter int
+}
+
+// signatureLines renders active signature with active parameter in bold
+func signatureLines(help LSPSignatureHelp, width int) []MarkdownLine {
+	if len(help.Signatures) == 0 {
+		return nil
+	}
+	index := help.ActiveSignature
+	if index < 0 || index >= len(help.Signatures) {
+		index = 0
+	}
+	sig := help.Signatures[index]
+	active := help.ActiveParameter
+	if sig.ActiveParameter != nil {
+		active = *sig.ActiveParameter
+	}
+
+	// active parameter range in runes
+	runes := []rune(sig.Label)
+	begin, end := -1, -1
+	if active >= 0 && active < len(sig.Parameters) {
+		label := sig.Parameters[active].Label
+		var str string
+		var offsets [2]int
+		if err := json.Unmarshal(label, &str); err == nil {
+			if i := strings.Index(sig.Label, str); i >= 0 {
+				begin = len([]rune(sig.Label[:i]))
+				end = begin + len([]rune(str))
+			}
+		} else if err := json.Unmarshal(label, &offsets); err == nil {
+			// utf16 offsets
+			units := utf16.Encode(runes)
+			if offsets[0] <= offsets[1] && offsets[1] <= len(units) {
+				begin = len(utf16.Decode(units[:offsets[0]]))
+				end = len(utf16.Decode(units[:offsets[1]]))
+			}
+		}
+	}
+
+	var line MarkdownLine
+	for i, r := range runes {
+		style := MarkdownCode
+		if i >= begin && i < end {
+			style = MarkdownBold
+		}
+		line.add(r, style)
+	}
+	lines := wrapMarkdownLine(line, width)
+	if doc := hoverMarkdown(sig.Documentation); doc != "" {
+		lines = append(lines, MarkdownLine{})
+		lines = append(lines, renderMarkdown(doc, width)...)
+	}
+	return lines
+}
+
+func (_ Provide) LSPSignatureHelp(
+	on On,
+	run RunInMainLoop,
+) OnStartup {
+	return func() {
+
+		// hover is dismissed on cursor move
+		on(func(
+			ev EvCursorMoved,
+			popups *LSPPopups,
+			closeOverlay CloseOverlay,
+		) {
+			if popups.Hover > 0 {
+				closeOverlay(popups.Hover)
+				popups.Hover = 0
+			}
+		})
+
+		on(func(
+			ev EvModesChanged,
+			popups *LSPPopups,
+			closeOverlay CloseOverlay,
+		) {
+			if popups.Signature > 0 && !IsEditing(ev.Modes) {
+				closeOverlay(popups.Signature)
+				popups.Signature = 0
+			}
+		})
+
+		var serial int64
+		on(func(
+			ev EvKeyEventHandled,
+			cur CurrentView,
+			curModes CurrentModes,
+			client *LSPClient,
+			popups *LSPPopups,
+			getLastKey GetLastKeyEvent,
+		) {
+			if !IsEditing(curModes()) {
+				return
+			}
+			view := cur()
+			if view == nil {
+				return
+			}
+			if popups.Signature == 0 {
+				// only trigger on call arguments
+				key := getLastKey()
+				if key == nil || (key.Rune() != '(' && key.Rune() != ',') {
+					return
+				}
+			}
+			call, err := lspRequestAtCursor(client, view, "textDocument/signatureHelp", nil)
+			if err != nil {
+				return
+			}
+			client.cancelOnChange(view.Buffer, call)
+			serial++
+			s := serial
+			moment := view.GetMoment()
+			state := view.ViewMomentState
+			call.Then(func(call *LSPCall) {
+				var help *LSPSignatureHelp
+				err := call.Result(&help)
+				run(func(
+					cur CurrentView,
+					popups *LSPPopups,
+					pushOverlay PushOverlay,
+					closeOverlay CloseOverlay,
+					cursorPosition ViewCursorScreenPosition,
+					maxWidth Width,
+					maxHeight Height,
+					j AppendJournal,
+				) {
+					if s != serial || cur() != view || view.ViewMomentState != state {
+						// outdated
+						return
+					}
+					if err != nil {
+						j("signature help: %v", err)
+					}
+					if popups.Signature > 0 {
+						closeOverlay(popups.Signature)
+						popups.Signature = 0
+					}
+					if help == nil {
+						return
+					}
+					lines := signatureLines(*help, lspPopupWidth(maxWidth))
+					if len(lines) == 0 {
+						return
+					}
+					popups.Signature = pushOverlay(OverlayObject(&LSPPopup{
+						Box:   lspPopupBox(view, moment, cursorPosition, maxWidth, maxHeight, lines),
+						Lines: lines,
+					}))
+				})
+			})
+		})
+
+	}
+}
@@ -0,0 +1,85 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestShowHover(t *testing.T) {
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "textDocument/hover":
+			return M{
+				"contents": M{
+					"kind":  "markdown",
+					"value": "```go\nfunc foo()\n```\n\nfoo does nothing",
+				},
+			}
+		}
+		return nil
+	})
+
+	withEditorBytes(t, []byte("foo()\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		popups *LSPPopups,
+		ctrl func(string),
+		moveCursor MoveCursor,
+		mainScope *Scope,
+	) {
+		getOverlays := func() (overlays []Overlay) {
+			mainScope.Assign(&overlays)
+			return
+		}
+		buffer.AbsPath = "/tmp/foo.go"
+		client.open(endpoint, buffer, view.GetMoment())
+
+		scope.Call(ShowHover)
+		ctrl("loop")
+		eq(t,
+			popups.Hover > 0, true,
+		)
+		var popup *LSPPopup
+		for _, overlay := range getOverlays() {
+			if overlay.ID == popups.Hover {
+				popup = overlay.Element.(*LSPPopup)
+			}
+		}
+		eq(t,
+			len(popup.Lines), 3,
+			string(popup.Lines[2].Runes), "foo does nothing",
+		)
+
+		// dismissed on cursor move
+		moveCursor(Move{RelRune: 1})
+		ctrl("loop")
+		eq(t,
+			popups.Hover, ID(0),
+			len(getOverlays()), 0,
+		)
+	})
+}
+
+func TestSignatureLines(t *testing.T) {
+	var help LSPSignatureHelp
+	ce(json.Unmarshal([]byte(`{
+		"signatures": [{
+			"label": "foo(a int, b string)",
+			"documentation": "foo does nothing",
+			"parameters": [{"label": "a int"}, {"label": [11, 19]}]
+		}],
+		"activeParameter": 1
+	}`), &help))
+	lines := signatureLines(help, 80)
+	eq(t,
+		len(lines), 3,
+		string(lines[0].Runes), "foo(a int, b string)",
+		lines[0].Styles[4], MarkdownCode,
+		lines[0].Styles[11], MarkdownBold,
+		lines[0].Styles[18], MarkdownBold,
+		lines[0].Styles[19], MarkdownCode,
+		string(lines[2].Runes), "foo does nothing",
+	)
+}
@@ -0,0 +1,295 @@
+package li
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type LSPLocation struct {
+	URI   string   `json:"uri"`
+	Range LSPRange `json:"range"`
+}
+
+// parseLSPLocations parses result of Location, []Location or []LocationLink
+func parseLSPLocations(result json.RawMessage) (locations []LSPLocation, err error) {
+	result = json.RawMessage(strings.TrimSpace(string(result)))
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+	if result[0] == '{' {
+		result = json.RawMessage("[" + string(result) + "]")
+	}
+	var items []struct {
+		LSPLocation
+		TargetURI            string   `json:"targetUri"`
+		TargetSelectionRange LSPRange `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(result, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.TargetURI != "" {
+			locations = append(locations, LSPLocation{
+				URI:   item.TargetURI,
+				Range: item.TargetSelectionRange,
+			})
+		} else {
+			locations = append(locations, item.LSPLocation)
+		}
+	}
+	return
+}
+
+// lspRequestAtCursor sends request with text document and cursor position of view
+func lspRequestAtCursor(
+	client *LSPClient,
+	view *View,
+	method string,
+	params M,
+) (*LSPCall, error) {
+	doc, ok := client.Documents[view.Buffer]
+	if !ok {
+		return nil, fmt.Errorf("no language server for %s", view.Buffer.Path)
+	}
+	moment := view.GetMoment()
+	client.sync(view.Buffer, moment)
+	pos := view.cursorPosition()
+	if pos.Line < 0 {
+		pos = Position{Line: view.CursorLine}
+	}
+	if params == nil {
+		params = M{}
+	}
+	params["textDocument"] = M{
+		"uri": doc.URI,
+	}
+	params["position"] = lspPosition(moment, pos)
+	return doc.Endpoint.Req(method, params), nil
+}
+
+type ShowLSPLocation func(loc LSPLocation) error
+
+func (_ Provide) ShowLSPLocation(
+	showPosition ShowPathPosition,
+	moveCursor MoveCursor,
+) ShowLSPLocation {
+	return func(loc LSPLocation) error {
+		view, err := showPosition(uriToPath(loc.URI), Position{
+			Line: loc.Range.Start.Line,
+		})
+		if err != nil {
+			return err
+		}
+		moment := view.GetMoment()
+		pos := lspToPosition(moment, loc.Range.Start)
+		if line := moment.GetLine(pos.Line); line != nil && pos.Cell < len(line.Cells) {
+			col := line.Cells[pos.Cell].DisplayOffset
+			moveCursor(Move{AbsLine: &pos.Line, AbsCol: &col})
+		}
+		return nil
+	}
+}
+
+// lspLocationPreviews returns text of lines of locations, from open views or files
+func lspLocationPreviews(views Views, locations []LSPLocation) []string {
+	files := make(map[string][]string)
+	previews := make([]string, len(locations))
+	for i, loc := range locations {
+		path := uriToPath(loc.URI)
+		lines, ok := files[path]
+		if !ok {
+			for _, view := range views {
+				if view.Buffer.AbsPath == path {
+					moment := view.GetMoment()
+					lines = linesText(moment, 0, moment.NumLines()-1)
+					break
+				}
+			}
+			if lines == nil {
+				if content, err := ioutil.ReadFile(path); err == nil {
+					lines = contentLines(string(content))
+				}
+			}
+			files[path] = lines
+		}
+		if line := loc.Range.Start.Line; line < len(lines) {
+			previews[i] = strings.TrimSpace(lines[line])
+		}
+	}
+	return previews
+}
+
+type ShowLSPLocations func(title string, locations []LSPLocation)
+
+func (_ Provide) ShowLSPLocations(
+	views Views,
+	pushJump PushJump,
+	showLocation ShowLSPLocation,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowMessage,
+) ShowLSPLocations {
+	return func(title string, locations []LSPLocation) {
+		if len(locations) == 0 {
+			show([]string{"no " + strings.ToLower(title) + " found"})
+			return
+		}
+
+		jump := func(loc LSPLocation) {
+			pushJump()
+			if err := showLocation(loc); err != nil {
+				show(strings.Split(err.Error(), "\n"))
+			}
+		}
+		if len(locations) == 1 {
+			jump(locations[0])
+			return
+		}
+
+		sort.SliceStable(locations, func(i, j int) bool {
+			a, b := locations[i], locations[j]
+			if a.URI != b.URI {
+				return a.URI < b.URI
+			}
+			return a.Range.Start.Line < b.Range.Start.Line
+		})
+		previews := lspLocationPreviews(views, locations)
+		texts := make([]string, len(locations))
+		for i, loc := range locations {
+			texts[i] = fmt.Sprintf(
+				"%s:%d: %s",
+				filepath.Base(uriToPath(loc.URI)),
+				loc.Range.Start.Line+1,
+				previews[i],
+			)
+		}
+
+		var id ID
+		dialog := &SelectionDialog{
+
+			Title: title,
+
+			OnClose: func(_ Scope) {
+				closeOverlay(id)
+			},
+
+			OnSelect: func(_ Scope, i ID) {
+				closeOverlay(id)
+				if int(i) >= len(locations) {
+					return
+				}
+				jump(locations[i])
+			},
+
+			OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+				for i, text := range texts {
+					if !strings.Contains(text, string(runes)) {
+						continue
+					}
+					if w := displayWidth(text); w > maxLen {
+						maxLen = w
+					}
+					ids = append(ids, ID(i))
+				}
+				return
+			},
+
+			CandidateElement: func(scope Scope, i ID) Element {
+				var box Box
+				var focus ID
+				var style Style
+				var getStyle GetStyle
+				scope.Assign(&box, &focus, &style, &getStyle)
+				s := style
+				if i == focus {
+					hlStyle := getStyle("Highlight")(s)
+					fg, _, _ := hlStyle.Decompose()
+					s = s.Foreground(fg)
+				}
+				return Text(
+					box,
+					texts[i],
+					s,
+				)
+			},
+		}
+
+		id = pushOverlay(OverlayObject(dialog))
+	}
+}
+
+// lspGoto requests locations at cursor and shows them
+func lspGoto(
+	scope Scope,
+	title string,
+	method string,
+	params M,
+) {
+	scope.Call(func(
+		cur CurrentView,
+		client *LSPClient,
+		showLocations ShowLSPLocations,
+		show ShowMessage,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		call, err := lspRequestAtCursor(client, view, method, params)
+		if err != nil {
+			show([]string{err.Error()})
+			return
+		}
+		var result json.RawMessage
+		if err := call.Result(&result); err != nil {
+			show(strings.Split(err.Error(), "\n"))
+			return
+		}
+		locations, err := parseLSPLocations(result)
+		if err != nil {
+			show(strings.Split(err.Error(), "\n"))
+			return
+		}
+		showLocations(title, locations)
+	})
+}
+
+func (_ Command) GotoDefinition() (spec CommandSpec) {
+	spec.Desc = "go to definition of symbol under cursor"
+	spec.Func = func(scope Scope) {
+		lspGoto(scope, "Definitions", "textDocument/definition", nil)
+	}
+	return
+}
+
+func (_ Command) GotoTypeDefinition() (spec CommandSpec) {
+	spec.Desc = "go to type definition of symbol under cursor"
+	spec.Func = func(scope Scope) {
+		lspGoto(scope, "Type Definitions", "textDocument/typeDefinition", nil)
+	}
+	return
+}
+
+func (_ Command) GotoImplementation() (spec CommandSpec) {
+	spec.Desc = "go to implementations of symbol under cursor"
+	spec.Func = func(scope Scope) {
+		lspGoto(scope, "Implementations", "textDocument/implementation", nil)
+	}
+	return
+}
+
+func (_ Command) FindReferences() (spec CommandSpec) {
+	spec.Desc = "list references of symbol under cursor"
+	spec.Func = func(scope Scope) {
+		lspGoto(scope, "References", "textDocument/references", M{
+			"context": M{
+				"includeDeclaration": true,
+			},
+		})
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package li
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLSPLocations(t *testing.T) {
+	locations, err := parseLSPLocations(json.RawMessage(`null`))
+	ce(err)
+	eq(t,
+		len(locations), 0,
+	)
+
+	locations, err = parseLSPLocations(json.RawMessage(`{
+		"uri": "file:///foo",
+		"range": {"start": {"line": 1, "character": 2}, "end": {"line": 1, "character": 3}}
+	}`))
+	ce(err)
+	eq(t,
+		len(locations), 1,
+		locations[0].URI, "file:///foo",
+		locations[0].Range.Start.Character, 2,
+	)
+
+	locations, err = parseLSPLocations(json.RawMessage(`[{
+		"targetUri": "file:///bar",
+		"targetRange": {"start": {"line": 1, "character": 0}, "end": {"line": 3, "character": 0}},
+		"targetSelectionRange": {"start": {"line": 2, "character": 5}, "end": {"line": 2, "character": 8}}
+	}]`))
+	ce(err)
+	eq(t,
+		len(locations), 1,
+		locations[0].URI, "file:///bar",
+		locations[0].Range.Start.Line, 2,
+	)
+}
+
+func TestGotoDefinition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	ce(err)
+	defer os.RemoveAll(dir)
+	aPath := filepath.Join(dir, "a.go")
+	bPath := filepath.Join(dir, "b.go")
+	ce(ioutil.WriteFile(aPath, []byte("package a\n\nfunc foo() {}\n"), 0644))
+	ce(ioutil.WriteFile(bPath, []byte("package a\n\nvar _ = foo\n"), 0644))
+
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "textDocument/definition":
+			return M{
+				"uri": pathToURI(aPath),
+				"range": M{
+					"start": M{"line": 2, "character": 5},
+					"end":   M{"line": 2, "character": 8},
+				},
+			}
+		}
+		return nil
+	})
+
+	withEditor(func(
+		scope Scope,
+		newBuffer NewBufferFromFile,
+		newView NewViewFromBuffer,
+		cur CurrentView,
+		client *LSPClient,
+		moveCursor MoveCursor,
+	) {
+		buffer, err := newBuffer(bPath)
+		ce(err)
+		view, err := newView(buffer)
+		ce(err)
+		client.open(endpoint, buffer, view.GetMoment())
+		moveCursor(Move{AbsLine: intP(2), AbsCol: intP(8)})
+
+		scope.Call(Command{}.GotoDefinition().Func)
+		target := cur()
+		eq(t,
+			target.Buffer.AbsPath, aPath,
+			target.CursorLine, 2,
+			target.CursorCol, 5,
+		)
+
+		scope.Call(JumpBack)
+		eq(t,
+			cur(), view,
+			view.CursorLine, 2,
+			view.CursorCol, 8,
+		)
+		scope.Call(JumpForward)
+		eq(t,
+			cur(), target,
+		)
+	})
+}
@@ -0,0 +1,132 @@
+package li
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// cursorWord returns the identifier under cursor of view
+func cursorWord(view *View) string {
+	pos := view.cursorPosition()
+	line := view.GetMoment().GetLine(pos.Line)
+	if line == nil {
+		return ""
+	}
+	runes := line.Runes()
+	if pos.Cell >= len(runes) || runeCategory(runes[pos.Cell]) != RuneCategoryIdentifier {
+		return ""
+	}
+	begin, end := pos.Cell, pos.Cell+1
+	for begin > 0 && runeCategory(runes[begin-1]) == RuneCategoryIdentifier {
+		begin--
+	}
+	for end < len(runes) && runeCategory(runes[end]) == RuneCategoryIdentifier {
+		end++
+	}
+	return string(runes[begin:end])
+}
+
+// prepareRenameText returns current text of symbol to rename, ok is false if not renamable
+func prepareRenameText(moment *Moment, result json.RawMessage) (text string, ok bool) {
+	if len(result) == 0 || string(result) == "null" {
+		return "", false
+	}
+	var res struct {
+		LSPRange
+		Range           *LSPRange `json:"range"`
+		Placeholder     string    `json:"placeholder"`
+		DefaultBehavior bool      `json:"defaultBehavior"`
+	}
+	if err := json.Unmarshal(result, &res); err != nil {
+		return "", false
+	}
+	if res.Placeholder != "" {
+		return res.Placeholder, true
+	}
+	if res.DefaultBehavior {
+		return "", true
+	}
+	r := res.LSPRange
+	if res.Range != nil {
+		r = *res.Range
+	}
+	begin := lspByteOffset(moment, r.Start)
+	end := lspByteOffset(moment, r.End)
+	if end < begin {
+		return "", false
+	}
+	return string(moment.GetBytes()[begin:end]), true
+}
+
+func RenameSymbol(
+	cur CurrentView,
+	client *LSPClient,
+	showPrompt ShowPrompt,
+	show ShowMessage,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+
+	call, err := lspRequestAtCursor(client, view, "textDocument/prepareRename", nil)
+	if err != nil {
+		show([]string{err.Error()})
+		return
+	}
+	var result json.RawMessage
+	initial := ""
+	var respErr *LSPResponseError
+	if err := call.Result(&result); errors.As(err, &respErr) && respErr.Code == lspMethodNotFound {
+		// not supported by server
+	} else if err != nil {
+		show(strings.Split(err.Error(), "\n"))
+		return
+	} else {
+		var ok bool
+		initial, ok = prepareRenameText(view.GetMoment(), result)
+		if !ok {
+			show([]string{"cannot rename symbol at cursor"})
+			return
+		}
+	}
+	if initial == "" {
+		initial = cursorWord(view)
+	}
+
+	showPrompt("Rename", initial, func(scope Scope, name string) {
+		name = strings.TrimSpace(name)
+		if name == "" || name == initial {
+			return
+		}
+		scope.Call(func(
+			applyEdit ApplyWorkspaceEdit,
+		) {
+			call, err := lspRequestAtCursor(client, view, "textDocument/rename", M{
+				"newName": name,
+			})
+			if err != nil {
+				show([]string{err.Error()})
+				return
+			}
+			var edit *LSPWorkspaceEdit
+			if err := call.Result(&edit); err != nil {
+				show(strings.Split(err.Error(), "\n"))
+				return
+			}
+			if edit == nil {
+				return
+			}
+			if err := applyEdit(*edit); err != nil {
+				show(strings.Split(err.Error(), "\n"))
+			}
+		})
+	})
+}
+
+func (_ Command) RenameSymbol() (spec CommandSpec) {
+	spec.Desc = "rename symbol under cursor"
+	spec.Func = RenameSymbol
+	return
+}
@@ -0,0 +1,93 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestRenameSymbol(t *testing.T) {
+	withEditorBytes(t, []byte("foo := 1\nbar(foo)\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+			switch method {
+			case "textDocument/prepareRename":
+				return M{
+					"start": M{"line": 0, "character": 0},
+					"end":   M{"line": 0, "character": 3},
+				}
+			case "textDocument/rename":
+				var p struct {
+					NewName string
+				}
+				ce(json.Unmarshal(params, &p))
+				return M{
+					"changes": M{
+						pathToURI(buffer.AbsPath): []M{
+							{
+								"range": M{
+									"start": M{"line": 0, "character": 0},
+									"end":   M{"line": 0, "character": 3},
+								},
+								"newText": p.NewName,
+							},
+							{
+								"range": M{
+									"start": M{"line": 1, "character": 4},
+									"end":   M{"line": 1, "character": 7},
+								},
+								"newText": p.NewName,
+							},
+						},
+					},
+				}
+			}
+			return nil
+		})
+		client.open(endpoint, buffer, view.GetMoment())
+
+		scope.Call(RenameSymbol)
+		ctrl("loop")
+		emitRune('2')
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			string(view.GetMoment().GetBytes()), "foo2 := 1\nbar(foo2)\n",
+		)
+	})
+}
+
+func TestPrepareRenameText(t *testing.T) {
+	withEditorBytes(t, []byte("foo := 1\n"), func(
+		view *View,
+	) {
+		moment := view.GetMoment()
+		text, ok := prepareRenameText(moment, json.RawMessage(`null`))
+		eq(t,
+			ok, false,
+		)
+		text, ok = prepareRenameText(moment, json.RawMessage(`{"range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 3}}, "placeholder": "bar"}`))
+		eq(t,
+			ok, true,
+			text, "bar",
+		)
+		text, ok = prepareRenameText(moment, json.RawMessage(`{"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 3}}`))
+		eq(t,
+			ok, true,
+			text, "foo",
+		)
+		text, ok = prepareRenameText(moment, json.RawMessage(`{"defaultBehavior": true}`))
+		eq(t,
+			ok, true,
+			text, "",
+		)
+	})
+}
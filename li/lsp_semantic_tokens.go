@@ -0,0 +1,439 @@
+package li
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var lspSemanticTokenTypes = []string{
+	"namespace", "type", "class", "enum", "interface", "struct",
+	"typeParameter", "parameter", "variable", "property", "enumMember",
+	"event", "function", "method", "macro", "keyword", "modifier",
+	"comment", "string", "number", "regexp", "operator", "decorator",
+}
+
+var lspSemanticTokenModifiers = []string{
+	"declaration", "definition", "readonly", "static", "deprecated",
+	"abstract", "async", "modification", "documentation", "defaultLibrary",
+}
+
+type LSPSemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+type lspSemanticTokensSupport struct {
+	Legend LSPSemanticTokensLegend
+	Full   bool
+	Delta  bool
+}
+
+// semanticTokensSupport parses semanticTokensProvider in server capabilities, nil if not supported
+func semanticTokensSupport(capabilities json.RawMessage) *lspSemanticTokensSupport {
+	if len(capabilities) == 0 {
+		return nil
+	}
+	var c struct {
+		SemanticTokensProvider *struct {
+			Legend LSPSemanticTokensLegend `json:"legend"`
+			Full   json.RawMessage         `json:"full"` // bool or {delta}
+		} `json:"semanticTokensProvider"`
+	}
+	if err := json.Unmarshal(capabilities, &c); err != nil || c.SemanticTokensProvider == nil {
+		return nil
+	}
+	provider := c.SemanticTokensProvider
+	support := &lspSemanticTokensSupport{
+		Legend: provider.Legend,
+	}
+	if err := json.Unmarshal(provider.Full, &support.Full); err != nil {
+		var full struct {
+			Delta bool `json:"delta"`
+		}
+		if err := json.Unmarshal(provider.Full, &full); err == nil {
+			support.Full = true
+			support.Delta = full.Delta
+		}
+	}
+	if !support.Full {
+		return nil
+	}
+	return support
+}
+
+type SemanticToken struct {
+	Begin     int // cell
+	End       int // cell, exclusive
+	Type      string
+	Modifiers []string
+}
+
+// utf16Cell returns index of the first cell at or after utf16 offset in line
+func utf16Cell(line *Line, offset int) int {
+	return sort.Search(len(line.Cells), func(i int) bool {
+		return line.Cells[i].UTF16Offset/2 >= offset
+	})
+}
+
+// decodeSemanticTokens decodes relative encoded tokens against lines of moment, by line
+func decodeSemanticTokens(
+	moment *Moment,
+	data []uint32,
+	legend LSPSemanticTokensLegend,
+) map[int][]SemanticToken {
+	lines := make(map[int][]SemanticToken)
+	lineNum := 0
+	char := 0
+	for i := 0; i+5 <= len(data); i += 5 {
+		deltaLine := int(data[i])
+		deltaChar := int(data[i+1])
+		length := int(data[i+2])
+		if deltaLine > 0 {
+			lineNum += deltaLine
+			char = deltaChar
+		} else {
+			char += deltaChar
+		}
+		line := moment.GetLine(lineNum)
+		if line == nil {
+			break
+		}
+		token := SemanticToken{
+			Begin: utf16Cell(line, char),
+			End:   utf16Cell(line, char+length),
+		}
+		if t := int(data[i+3]); t < len(legend.TokenTypes) {
+			token.Type = legend.TokenTypes[t]
+		}
+		for bit, modifier := range legend.TokenModifiers {
+			if data[i+4]&(1<<uint(bit)) != 0 {
+				token.Modifiers = append(token.Modifiers, modifier)
+			}
+		}
+		lines[lineNum] = append(lines[lineNum], token)
+	}
+	return lines
+}
+
+type lspSemanticTokensEdit struct {
+	Start       int      `json:"start"`
+	DeleteCount int      `json:"deleteCount"`
+	Data        []uint32 `json:"data"`
+}
+
+// applySemanticTokensEdits returns new data, edits refer to offsets in data
+func applySemanticTokensEdits(data []uint32, edits []lspSemanticTokensEdit) ([]uint32, error) {
+	edits = append(edits[:0:0], edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Start > edits[j].Start
+	})
+	ret := append(data[:0:0], data...)
+	end := len(ret)
+	for _, edit := range edits {
+		if edit.Start < 0 || edit.DeleteCount < 0 || edit.Start+edit.DeleteCount > end {
+			return nil, errors.New("bad semantic tokens edit")
+		}
+		tail := append(edit.Data[:len(edit.Data):len(edit.Data)], ret[edit.Start+edit.DeleteCount:]...)
+		ret = append(ret[:edit.Start], tail...)
+		end = edit.Start
+	}
+	return ret, nil
+}
+
+type SemanticTokens struct {
+	buffers  map[*Buffer]*semanticTokensState
+	supports map[*LSPEndpoint]*lspSemanticTokensSupport
+	version  int
+
+	// read by stainers in render goroutines
+	sync.RWMutex
+	snapshots map[*Buffer]*semanticTokensSnapshot
+}
+
+// semanticTokensState is accessed in main loop only
+type semanticTokensState struct {
+	endpoint  *LSPEndpoint
+	resultID  string
+	data      []uint32
+	version   int
+	requested *Moment
+	pending   bool
+	outdated  bool // refresh requested by server
+}
+
+// semanticTokensSnapshot is immutable after published
+type semanticTokensSnapshot struct {
+	moment *Moment // of tokens
+	lines  map[int][]SemanticToken
+}
+
+func (_ Provide) SemanticTokens() *SemanticTokens {
+	return &SemanticTokens{
+		buffers:   make(map[*Buffer]*semanticTokensState),
+		supports:  make(map[*LSPEndpoint]*lspSemanticTokensSupport),
+		snapshots: make(map[*Buffer]*semanticTokensSnapshot),
+	}
+}
+
+// invalidate marks tokens of documents of endpoint to be requested again
+func (s *SemanticTokens) invalidate(endpoint *LSPEndpoint) {
+	for _, state := range s.buffers {
+		if state.endpoint == endpoint {
+			state.outdated = true
+		}
+	}
+}
+
+func (s *SemanticTokens) publish(buffer *Buffer, snapshot *semanticTokensSnapshot) {
+	s.Lock()
+	if snapshot == nil {
+		delete(s.snapshots, buffer)
+	} else {
+		s.snapshots[buffer] = snapshot
+	}
+	s.Unlock()
+}
+
+// UpdateSemanticTokens requests language server for tokens of moment if outdated, called in main loop.
+// Returned version changes when new tokens of buffer are available
+type UpdateSemanticTokens func(buffer *Buffer, moment *Moment) (version int)
+
+func (_ Provide) UpdateSemanticTokens(
+	tokens *SemanticTokens,
+	client *LSPClient,
+	run RunInMainLoop,
+	j AppendJournal,
+) UpdateSemanticTokens {
+
+	request := func(doc *LSPDocument, support *lspSemanticTokensSupport, state *semanticTokensState, moment *Moment) {
+		state.pending = true
+		state.requested = moment
+		state.outdated = false
+		client.sync(doc.Buffer, moment)
+		var call *LSPCall
+		delta := support.Delta && state.resultID != "" && state.data != nil
+		if delta {
+			call = doc.Endpoint.Req("textDocument/semanticTokens/full/delta", M{
+				"textDocument": M{
+					"uri": doc.URI,
+				},
+				"previousResultId": state.resultID,
+			})
+		} else {
+			call = doc.Endpoint.Req("textDocument/semanticTokens/full", M{
+				"textDocument": M{
+					"uri": doc.URI,
+				},
+			})
+		}
+		client.cancelOnChange(doc.Buffer, call)
+
+		call.Then(func(call *LSPCall) {
+			var result *struct {
+				ResultID string                  `json:"resultId"`
+				Data     []uint32                `json:"data"`
+				Edits    []lspSemanticTokensEdit `json:"edits"`
+			}
+			err := call.Result(&result)
+			run(func() {
+				state.pending = false
+				if tokens.buffers[doc.Buffer] != state {
+					// closed
+					return
+				}
+				if errors.Is(err, ErrLSPCanceled) {
+					state.requested = nil
+					return
+				}
+				if err != nil {
+					j("semantic tokens: %v", err)
+					// full request next time
+					state.resultID = ""
+					return
+				}
+				if result == nil {
+					return
+				}
+				data := result.Data
+				if delta && result.Data == nil {
+					data, err = applySemanticTokensEdits(state.data, result.Edits)
+					if err != nil {
+						j("semantic tokens: %v", err)
+						state.resultID = ""
+						state.requested = nil
+						return
+					}
+				}
+				state.resultID = result.ResultID
+				state.data = data
+				tokens.version++
+				state.version = tokens.version
+				tokens.publish(doc.Buffer, &semanticTokensSnapshot{
+					moment: moment,
+					lines:  decodeSemanticTokens(moment, data, support.Legend),
+				})
+			})
+		})
+	}
+
+	return func(buffer *Buffer, moment *Moment) int {
+		doc, ok := client.Documents[buffer]
+		if !ok {
+			return 0
+		}
+		support, ok := tokens.supports[doc.Endpoint]
+		if !ok {
+			support = semanticTokensSupport(doc.Endpoint.Capabilities)
+			tokens.supports[doc.Endpoint] = support
+		}
+		if support == nil {
+			return 0
+		}
+
+		state, ok := tokens.buffers[buffer]
+		if !ok || state.endpoint != doc.Endpoint {
+			state = &semanticTokensState{
+				endpoint: doc.Endpoint,
+			}
+			tokens.buffers[buffer] = state
+			tokens.publish(buffer, nil)
+		}
+		if !state.pending && (state.requested != moment || state.outdated) {
+			request(doc, support, state, moment)
+		}
+		return state.version
+	}
+}
+
+// GetSemanticTokens returns tokens of line in moment, safe to call in render goroutines.
+// Tokens of previous moment are returned for unchanged lines while requesting
+type GetSemanticTokens func(buffer *Buffer, moment *Moment, line int) []SemanticToken
+
+func (_ Provide) GetSemanticTokens(
+	tokens *SemanticTokens,
+) GetSemanticTokens {
+	return func(buffer *Buffer, moment *Moment, line int) []SemanticToken {
+		tokens.RLock()
+		snapshot, ok := tokens.snapshots[buffer]
+		tokens.RUnlock()
+		if !ok {
+			return nil
+		}
+		if snapshot.moment != moment {
+			// use previous tokens if line not changed
+			prev := snapshot.moment.GetLine(line)
+			cur := moment.GetLine(line)
+			if prev == nil || cur == nil || prev.content != cur.content {
+				return nil
+			}
+		}
+		return snapshot.lines[line]
+	}
+}
+
+// SemanticTokenStyle returns style of token type and modifiers, nil if not configured
+type SemanticTokenStyle func(tokenType string, modifiers []string) StyleFunc
+
+func (_ Provide) SemanticTokenStyle(
+	config StyleConfig,
+) SemanticTokenStyle {
+	// Style.SemanticTypeParameter for typeParameter
+	styles := make(map[string]StyleFunc)
+	for key, spec := range config {
+		if !strings.HasPrefix(key, "Semantic") || len(key) == len("Semantic") {
+			continue
+		}
+		name := []rune(strings.TrimPrefix(key, "Semantic"))
+		name[0] = unicode.ToLower(name[0])
+		styles[string(name)] = spec.ToFunc()
+	}
+	return func(tokenType string, modifiers []string) StyleFunc {
+		fn := styles[tokenType]
+		for _, modifier := range modifiers {
+			if f, ok := styles[modifier]; ok {
+				if fn == nil {
+					fn = f
+				} else {
+					fn = fn.And(f)
+				}
+			}
+		}
+		return fn
+	}
+}
+
+func (_ Provide) SemanticTokensCleanup(
+	on On,
+	tokens *SemanticTokens,
+) OnStartup {
+	return func() {
+		on(func(
+			ev EvViewClosed,
+			views Views,
+		) {
+			for _, view := range views {
+				if view.Buffer == ev.View.Buffer {
+					return
+				}
+			}
+			delete(tokens.buffers, ev.View.Buffer)
+			tokens.publish(ev.View.Buffer, nil)
+		})
+	}
+}
+
+// SemanticTokensStainer overlays styles of semantic tokens from language server on lexical styles
+type SemanticTokensStainer struct {
+	Buffer  *Buffer
+	Lexical Stainer
+}
+
+var _ Stainer = new(SemanticTokensStainer)
+
+func (s *SemanticTokensStainer) Line() dyn {
+	return func(
+		scope Scope,
+		moment *Moment,
+		line *Line,
+		lineNum LineNumber,
+		getTokens GetSemanticTokens,
+		tokenStyle SemanticTokenStyle,
+	) (
+		colors []*Color,
+		fns []StyleFunc,
+	) {
+		scope.Call(s.Lexical.Line()).Assign(&colors, &fns)
+
+		tokens := getTokens(s.Buffer, moment, int(lineNum))
+		if len(tokens) == 0 {
+			return
+		}
+		// lexical styles may be cached
+		styled := make([]StyleFunc, len(line.Cells))
+		copy(styled, fns)
+		for i, color := range colors {
+			if color != nil && i < len(styled) {
+				styled[i] = SetFG(*color)
+			}
+		}
+		for _, token := range tokens {
+			fn := tokenStyle(token.Type, token.Modifiers)
+			if fn == nil {
+				continue
+			}
+			for i := token.Begin; i < token.End && i < len(styled); i++ {
+				if styled[i] == nil {
+					styled[i] = fn
+				} else {
+					styled[i] = styled[i].And(fn)
+				}
+			}
+		}
+		// colors take precedence over style funcs when rendering, converted above
+		return nil, styled
+	}
+}
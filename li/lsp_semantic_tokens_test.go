@@ -0,0 +1,195 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestSemanticTokensSupport(t *testing.T) {
+	support := semanticTokensSupport(json.RawMessage(`{
+		"semanticTokensProvider": {
+			"legend": {"tokenTypes": ["variable"], "tokenModifiers": ["readonly"]},
+			"full": {"delta": true}
+		}
+	}`))
+	eq(t,
+		support != nil, true,
+		support.Full, true,
+		support.Delta, true,
+		support.Legend.TokenTypes, []string{"variable"},
+		semanticTokensSupport(json.RawMessage(`{"semanticTokensProvider": {"full": true}}`)).Delta, false,
+		semanticTokensSupport(json.RawMessage(`{"semanticTokensProvider": {"range": true}}`)) == nil, true,
+		semanticTokensSupport(json.RawMessage(`{}`)) == nil, true,
+	)
+}
+
+func TestDecodeSemanticTokens(t *testing.T) {
+	withEditorBytes(t, []byte("a 好 b\n😀x y\n"), func(
+		moment *Moment,
+	) {
+		lines := decodeSemanticTokens(moment, []uint32{
+			0, 0, 1, 0, 0,
+			0, 2, 1, 1, 1,
+			0, 2, 1, 0, 3,
+			1, 2, 1, 1, 0,
+			0, 2, 1, 5, 0, // unknown type
+		}, LSPSemanticTokensLegend{
+			TokenTypes:     []string{"variable", "parameter"},
+			TokenModifiers: []string{"readonly", "static"},
+		})
+		eq(t,
+			lines[0], []SemanticToken{
+				{Begin: 0, End: 1, Type: "variable"},
+				{Begin: 2, End: 3, Type: "parameter", Modifiers: []string{"readonly"}},
+				{Begin: 4, End: 5, Type: "variable", Modifiers: []string{"readonly", "static"}},
+			},
+			lines[1], []SemanticToken{
+				{Begin: 1, End: 2, Type: "parameter"},
+				{Begin: 3, End: 4},
+			},
+		)
+	})
+}
+
+func TestApplySemanticTokensEdits(t *testing.T) {
+	data := []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	ret, err := applySemanticTokensEdits(data, []lspSemanticTokensEdit{
+		{Start: 0, DeleteCount: 5, Data: []uint32{10}},
+		{Start: 7, DeleteCount: 1, Data: []uint32{20, 21}},
+		{Start: 10, DeleteCount: 0, Data: []uint32{30}},
+	})
+	ce(err)
+	eq(t,
+		ret, []uint32{10, 5, 6, 20, 21, 8, 9, 30},
+		data[0], uint32(0),
+	)
+	_, err = applySemanticTokensEdits(data, []lspSemanticTokensEdit{
+		{Start: 8, DeleteCount: 5},
+	})
+	eq(t,
+		err != nil, true,
+	)
+}
+
+func TestSemanticTokensStainer(t *testing.T) {
+	requests := make(chan string, 10)
+	endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+		switch method {
+		case "textDocument/semanticTokens/full":
+			requests <- method
+			return M{
+				"resultId": "1",
+				"data":     []uint32{1, 0, 3, 0, 0},
+			}
+		case "textDocument/semanticTokens/full/delta":
+			var p struct {
+				PreviousResultID string `json:"previousResultId"`
+			}
+			ce(json.Unmarshal(params, &p))
+			requests <- method + " " + p.PreviousResultID
+			return M{
+				"resultId": "2",
+				"edits": []M{
+					{"start": 2, "deleteCount": 1, "data": []uint32{2}},
+				},
+			}
+		}
+		return nil
+	})
+	endpoint.Capabilities = json.RawMessage(`{
+		"semanticTokensProvider": {
+			"legend": {"tokenTypes": ["parameter"], "tokenModifiers": []},
+			"full": {"delta": true}
+		}
+	}`)
+
+	withEditorBytes(t, []byte("package main\nfoo\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		moment *Moment,
+		client *LSPClient,
+		ctrl func(string),
+		getTokens GetSemanticTokens,
+		apply ApplyChange,
+		getStyle GetStyle,
+		contents GetSimScreenContents,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		client.open(endpoint, buffer, moment)
+		wait := func(fn func() bool) {
+			deadline := time.Now().Add(time.Second * 5)
+			for !fn() && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond * 10)
+				ctrl("loop")
+			}
+		}
+
+		// full, requested when rendering
+		eq(t,
+			len(getTokens(buffer, moment, 1)), 0,
+		)
+		ctrl("loop")
+		eq(t,
+			<-requests, "textDocument/semanticTokens/full",
+		)
+		wait(func() bool {
+			return len(getTokens(buffer, moment, 1)) > 0
+		})
+		eq(t,
+			getTokens(buffer, moment, 1), []SemanticToken{
+				{Begin: 0, End: 3, Type: "parameter"},
+			},
+		)
+
+		// stainer
+		line := moment.GetLine(1)
+		l := LineNumber(1)
+		var fns []StyleFunc
+		scope.Fork(&moment, &line, &l).Call(view.Stainer.Line()).Assign(&fns)
+		style := getStyle("SemanticParameter")(tcell.StyleDefault)
+		eq(t,
+			len(fns), 4,
+			fns[0] != nil, true,
+			fns[0](tcell.StyleDefault), style,
+			fns[3] == nil, true,
+		)
+
+		// rendered
+		ctrl("loop")
+		cells, width, _ := contents()
+		box := view.ContentBox
+		fg, _, _ := cells[(box.Top+1)*width+box.Left].Style.Decompose()
+		wantFG, _, _ := style.Decompose()
+		eq(t,
+			fg, wantFG,
+		)
+
+		// delta after edit, unchanged lines keep tokens while requesting
+		moment2, _ := apply(moment, Change{
+			Op:     OpInsert,
+			Begin:  Position{Line: 0, Cell: 0},
+			String: "// ",
+		})
+		view.switchMoment(scope, moment2)
+		eq(t,
+			len(getTokens(buffer, moment2, 1)), 1,
+			len(getTokens(buffer, moment2, 0)), 0,
+		)
+		ctrl("loop")
+		eq(t,
+			<-requests, "textDocument/semanticTokens/full/delta 1",
+		)
+		wait(func() bool {
+			return getTokens(buffer, moment2, 1)[0].End == 2
+		})
+		eq(t,
+			getTokens(buffer, moment2, 1), []SemanticToken{
+				{Begin: 0, End: 2, Type: "parameter"},
+			},
+		)
+	})
+}
@@ -0,0 +1,195 @@
+package li
+
+import (
+	"strings"
+)
+
+type MarkdownStyle uint8
+
+const (
+	MarkdownPlain MarkdownStyle = iota
+	MarkdownBold
+	MarkdownCode
+	MarkdownHeading
+)
+
+type MarkdownLine struct {
+	Runes  []rune
+	Styles []MarkdownStyle // per rune
+}
+
+func (l *MarkdownLine) add(r rune, style MarkdownStyle) {
+	l.Runes = append(l.Runes, r)
+	l.Styles = append(l.Styles, style)
+}
+
+// renderMarkdown renders the subset of markdown used by language servers to styled lines wrapped at width
+func renderMarkdown(text string, width int) (lines []MarkdownLine) {
+	inFence := false
+	blank := false
+	for _, src := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(src)
+
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+
+		var line MarkdownLine
+		switch {
+
+		case inFence:
+			for _, r := range expandTabs(src) {
+				line.add(r, MarkdownCode)
+			}
+
+		case trimmed == "":
+			// collapse blank lines
+			if blank || len(lines) == 0 {
+				continue
+			}
+			blank = true
+			lines = append(lines, line)
+			continue
+
+		case strings.HasPrefix(trimmed, "#"):
+			for _, r := range strings.TrimSpace(strings.TrimLeft(trimmed, "#")) {
+				line.add(r, MarkdownHeading)
+			}
+
+		default:
+			line = renderMarkdownInline(src)
+		}
+		blank = false
+
+		lines = append(lines, wrapMarkdownLine(line, width)...)
+	}
+
+	// trailing blank
+	for len(lines) > 0 && len(lines[len(lines)-1].Runes) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return
+}
+
+func renderMarkdownInline(src string) (line MarkdownLine) {
+	runes := []rune(src)
+	bold := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		style := MarkdownPlain
+		if bold {
+			style = MarkdownBold
+		}
+		switch {
+
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\`*_{}[]()#+-.!<>|", runes[i+1]):
+			// escaped
+			i++
+			line.add(runes[i], style)
+
+		case r == '`':
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] == '`' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				line.add(r, style)
+				continue
+			}
+			for _, c := range runes[i+1 : end] {
+				line.add(c, MarkdownCode)
+			}
+			i = end
+
+		case (r == '*' || r == '_') && i+1 < len(runes) && runes[i+1] == r:
+			bold = !bold
+			i++
+
+		case r == '[':
+			// link
+			closing := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] == ']' {
+					closing = j
+					break
+				}
+			}
+			if closing < 0 || closing+1 >= len(runes) || runes[closing+1] != '(' {
+				line.add(r, style)
+				continue
+			}
+			end := -1
+			for j := closing + 2; j < len(runes); j++ {
+				if runes[j] == ')' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				line.add(r, style)
+				continue
+			}
+			for _, c := range runes[i+1 : closing] {
+				line.add(c, style)
+			}
+			i = end
+
+		case r == '\t':
+			for _, c := range expandTabs("\t") {
+				line.add(c, style)
+			}
+
+		default:
+			line.add(r, style)
+		}
+	}
+	return
+}
+
+// wrapMarkdownLine wraps line by display width
+func wrapMarkdownLine(line MarkdownLine, width int) (lines []MarkdownLine) {
+	if width <= 0 {
+		return []MarkdownLine{line}
+	}
+	for {
+		w := 0
+		n := 0
+		for n < len(line.Runes) {
+			rw := runeDisplayWidth(line.Runes[n])
+			if w+rw > width {
+				break
+			}
+			w += rw
+			n++
+		}
+		if n >= len(line.Runes) || n == 0 {
+			lines = append(lines, line)
+			return
+		}
+		// break at space if possible
+		if i := lastSpace(line.Runes[:n]); i > 0 {
+			n = i + 1
+		}
+		lines = append(lines, MarkdownLine{
+			Runes:  line.Runes[:n],
+			Styles: line.Styles[:n],
+		})
+		line = MarkdownLine{
+			Runes:  line.Runes[n:],
+			Styles: line.Styles[n:],
+		}
+	}
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,27 @@
+package li
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	lines := renderMarkdown("# Foo\n\n\n```go\nfunc foo()\n```\nsee **bar** and `baz` in [doc](http://x)\\.", 80)
+	eq(t,
+		len(lines), 4,
+		string(lines[0].Runes), "Foo",
+		lines[0].Styles[0], MarkdownHeading,
+		len(lines[1].Runes), 0,
+		string(lines[2].Runes), "func foo()",
+		lines[2].Styles[0], MarkdownCode,
+		string(lines[3].Runes), "see bar and baz in doc.",
+		lines[3].Styles[4], MarkdownBold,
+		lines[3].Styles[8], MarkdownPlain,
+		lines[3].Styles[12], MarkdownCode,
+	)
+
+	// wrap
+	lines = renderMarkdown("foo bar baz", 8)
+	eq(t,
+		len(lines), 2,
+		string(lines[0].Runes), "foo bar ",
+		string(lines[1].Runes), "baz",
+	)
+}
@@ -42,10 +42,17 @@ type Moment struct {
 	initBytesOnce          sync.Once
 	bytes                  []byte
 
-	initParserOnce sync.Once
+	parserLock     sync.Mutex
 	parser         *treesitter.Parser
+	parserLanguage Language // parse again if buffer language changed
 	syntaxAttrs    sync.Map
 
+	initFoldRangesOnce sync.Once
+	foldRanges         []FoldRange
+	foldRangesByBegin  map[int]FoldRange
+
+	undoBoundaries UndoUnit
+
 	finalizeFuncs sync.Map
 }
 
@@ -129,37 +136,150 @@ func (m *Moment) GetBytes() []byte {
 }
 
 func (m *Moment) GetParser(scope Scope) *treesitter.Parser {
+	parser, _ := m.getParser(scope)
+	return parser
+}
+
+func (m *Moment) getParser(scope Scope) (*treesitter.Parser, Language) {
 	var buffer *Buffer
 	var linked LinkedOne
 	scope.Assign(&linked)
 	linked(m, &buffer)
-	if buffer.language == LanguageUnknown {
-		return nil
+	lang := buffer.language
+	parse := languageParser(lang)
+	if parse == nil {
+		return nil, lang
+	}
+
+	m.parserLock.Lock()
+	defer m.parserLock.Unlock()
+	if m.parser != nil && m.parserLanguage == lang {
+		return m.parser, lang
 	}
-	m.initParserOnce.Do(func() {
-		//TODO utilize tree-sitter incremental parsing
-		if fn, ok := languageParsers[buffer.language]; ok {
-			m.parser = fn(m)
+
+	var parser *treesitter.Parser
+	// reuse tree of previous moment if parsed, not parsing the ancestry
+	if prev := m.Previous; prev != nil {
+		prev.parserLock.Lock()
+		if prev.parser != nil && prev.parserLanguage == lang {
+			if edit, ok := treeSitterEdit(prev, m); ok {
+				parser = prev.parser.Reparse(
+					unsafe.Pointer(m.GetCStringContent()),
+					len(m.GetContent()),
+					edit,
+				)
+			}
 		}
-		m.finalizeFuncs.Store(rand.Int63(), func() {
-			m.parser.Close()
-		})
+		prev.parserLock.Unlock()
+	}
+	if parser == nil {
+		parser = parse(m)
+	}
+	// replaced parser may be in use, close when finalizing
+	m.finalizeFuncs.Store(rand.Int63(), func() {
+		parser.Close()
 	})
-	return m.parser
+	m.parser = parser
+	m.parserLanguage = lang
+	return parser, lang
 }
 
-func (m *Moment) GetSyntaxAttr(scope Scope, lineNum int, runeOffset int) string {
-	key := Position{
-		Line: lineNum,
-		Cell: runeOffset,
+// treeSitterEdit describes the change from prev to m as tree-sitter edit, ok is false if not derivable
+func treeSitterEdit(prev *Moment, m *Moment) (edit treesitter.InputEdit, ok bool) {
+	if m.Previous != prev || len(m.segments) == 0 {
+		return
 	}
-	if v, ok := m.syntaxAttrs.Load(key); ok {
-		return v.(string)
+	change := resolveChange(prev, m.Change)
+	end := change.End
+	if change.Op == OpInsert {
+		end = change.Begin
+	}
+	if end.Line < change.Begin.Line ||
+		end.Line == change.Begin.Line && end.Cell < change.Begin.Cell {
+		return
+	}
+
+	// points are in byte columns
+	column := func(moment *Moment, pos Position) (int, bool) {
+		line := moment.GetLine(pos.Line)
+		if line == nil {
+			return 0, false
+		}
+		if pos.Cell >= len(line.Cells) {
+			return len(line.content), true
+		}
+		return line.Cells[pos.Cell].ByteOffset, true
+	}
+	momentEnd := func(moment *Moment) (int, treesitter.TSPoint) {
+		n := moment.NumLines()
+		line := moment.GetLine(n - 1)
+		if strings.HasSuffix(line.content, "\n") {
+			return len(moment.GetContent()), treesitter.Point(n, 0)
+		}
+		return len(moment.GetContent()), treesitter.Point(n-1, len(line.content))
+	}
+
+	startCol, ok := column(prev, change.Begin)
+	if !ok {
+		return
+	}
+	edit.StartByte = prev.PositionToByteOffset(change.Begin)
+	edit.StartPoint = treesitter.Point(change.Begin.Line, startCol)
+
+	last := prev.NumLines() - 1
+	if change.Begin.Line >= last || end.Line >= last {
+		// changes to the last line may add line break, edit to the end
+		edit.OldEndByte, edit.OldEndPoint = momentEnd(prev)
+		edit.NewEndByte, edit.NewEndPoint = momentEnd(m)
+		return edit, edit.NewEndByte >= edit.StartByte
+	}
+
+	endCol, ok := column(prev, end)
+	if !ok {
+		return
+	}
+	edit.OldEndByte = prev.PositionToByteOffset(end)
+	edit.OldEndPoint = treesitter.Point(end.Line, endCol)
+	text := ""
+	if change.Op != OpDelete {
+		text = change.String
 	}
-	parser := m.GetParser(scope)
+	edit.NewEndByte = edit.StartByte + len(text)
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		edit.NewEndPoint = treesitter.Point(
+			change.Begin.Line+strings.Count(text, "\n"),
+			len(text)-i-1,
+		)
+	} else {
+		edit.NewEndPoint = treesitter.Point(change.Begin.Line, startCol+len(text))
+	}
+	// content of m must be consistent with the edit
+	if len(prev.GetContent())-(edit.OldEndByte-edit.StartByte)+len(text) != len(m.GetContent()) {
+		return edit, false
+	}
+	return edit, true
+}
+
+type syntaxAttrKey struct {
+	Language
+	Position
+}
+
+func (m *Moment) GetSyntaxAttr(scope Scope, lineNum int, runeOffset int) string {
+	parser, lang := m.getParser(scope)
 	if parser == nil {
 		return ""
 	}
+	key := syntaxAttrKey{
+		Language: lang,
+		Position: Position{
+			Line: lineNum,
+			Cell: runeOffset,
+		},
+	}
+	if v, ok := m.syntaxAttrs.Load(key); ok {
+		return v.(string)
+	}
 	node := parser.NodeAt(treesitter.Point(lineNum, runeOffset))
 	nodeType := treesitter.NodeType(node)
 	attr := nodeType
@@ -194,6 +314,26 @@ func (m *Moment) ByteOffsetToPosition(offset int) (pos Position) {
 	return
 }
 
+func (m *Moment) PositionToByteOffset(pos Position) (offset int) {
+	for i := 0; i < pos.Line; i++ {
+		line := m.GetLine(i)
+		if line == nil {
+			return
+		}
+		offset += len(line.content)
+	}
+	line := m.GetLine(pos.Line)
+	if line == nil {
+		return
+	}
+	if pos.Cell >= len(line.Cells) {
+		offset += len(line.content)
+	} else {
+		offset += line.Cells[pos.Cell].ByteOffset
+	}
+	return
+}
+
 var nextMomentID int64
 
 type NewMomentFromFile func(
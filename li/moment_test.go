@@ -2,6 +2,8 @@ package li
 
 import (
 	"testing"
+
+	"github.com/reusee/li/treesitter"
 )
 
 func TestMomentFromBytes(t *testing.T) {
@@ -89,6 +91,39 @@ func TestDerivedMomentLanguage(t *testing.T) {
 	})
 }
 
+func TestIncrementalParsing(t *testing.T) {
+	withEditorBytes(t, []byte("package main\n\nfunc main() {\n\tfoo()\n}\n// 好\n"), func(
+		moment *Moment,
+		scope Scope,
+		buffer *Buffer,
+		apply ApplyChange,
+	) {
+		buffer.SetLanguage(scope, LanguageGo)
+		moment.GetParser(scope)
+		for _, change := range []Change{
+			{Op: OpInsert, Begin: Position{Line: 3, Cell: 5}, String: "1, 好"},
+			{Op: OpInsert, Begin: Position{Line: 1, Cell: 0}, String: "var a = 1\nvar b = 2\n"},
+			{Op: OpDelete, Begin: Position{Line: 1, Cell: 0}, Number: 10},
+			{Op: OpReplace, Begin: Position{Line: 0, Cell: 8}, End: Position{Line: 1, Cell: 4}, String: "foo\nfunc bar() {}\n"},
+			{Op: OpInsert, Begin: Position{Line: 7, Cell: 4}, String: "\ntype T int"},
+		} {
+			prev := moment
+			moment, _ = apply(moment, change)
+			_, ok := treeSitterEdit(prev, moment)
+			parser := moment.GetParser(scope)
+			full := languageParser(LanguageGo)(moment)
+			eq(t,
+				ok, true,
+				treesitter.NodeString(parser.RootNode()), treesitter.NodeString(full.RootNode()),
+			)
+			full.Close()
+		}
+		eq(t,
+			moment.GetContent(), "package foo\nfunc bar() {}\nb = 2\n\nfunc main() {\n\tfoo(1, 好)\n}\n// 好\ntype T int\n",
+		)
+	})
+}
+
 func TestCellUTF16Offset(t *testing.T) {
 	withHelloEditor(t, func(
 		m *Moment,
@@ -0,0 +1,658 @@
+package li
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/junegunn/fzf/src/util"
+	"github.com/reusee/li/treesitter"
+)
+
+type OutlineSymbol struct {
+	Name     string
+	Kind     string
+	Detail   string
+	Depth    int      // number of enclosing symbols
+	Range    Range    // whole definition
+	Position Position // of name
+}
+
+func (s OutlineSymbol) text() string {
+	if s.Detail == "" {
+		return s.Name
+	}
+	return s.Name + " " + s.Detail
+}
+
+// setOutlineDepths sorts symbols by position and sets depths by range containment
+func setOutlineDepths(symbols []OutlineSymbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Range.Begin.Before(symbols[j].Range.Begin)
+	})
+	var stack []Range
+	for i, symbol := range symbols {
+		for len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if symbol.Range != parent &&
+				!symbol.Range.Begin.Before(parent.Begin) &&
+				!parent.End.Before(symbol.Range.End) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		symbols[i].Depth = len(stack)
+		stack = append(stack, symbol.Range)
+	}
+}
+
+// currentOutlineSymbol returns index of the innermost symbol containing pos,
+// or the last outermost symbol before pos within maxDistance lines, -1 if not found
+func currentOutlineSymbol(symbols []OutlineSymbol, pos Position, maxDistance int) int {
+	current := -1
+	for i, symbol := range symbols {
+		if pos.Before(symbol.Range.Begin) {
+			break
+		}
+		if symbol.Range.Contains(pos) {
+			current = i
+		} else if current >= 0 && symbols[current].Range.Contains(pos) {
+			// nested in current
+		} else if current >= 0 && symbol.Range.End.Before(symbols[current].Range.End) {
+			// nested in previous
+		} else if maxDistance <= 0 || pos.Line-symbol.Range.End.Line <= maxDistance {
+			current = i
+		}
+	}
+	return current
+}
+
+var lspSymbolKinds = []string{
+	"",
+	"File",
+	"Module",
+	"Namespace",
+	"Package",
+	"Class",
+	"Method",
+	"Property",
+	"Field",
+	"Constructor",
+	"Enum",
+	"Interface",
+	"Function",
+	"Variable",
+	"Constant",
+	"String",
+	"Number",
+	"Boolean",
+	"Array",
+	"Object",
+	"Key",
+	"Null",
+	"EnumMember",
+	"Struct",
+	"Event",
+	"Operator",
+	"TypeParameter",
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail"`
+	Kind           int                 `json:"kind"`
+	Range          *LSPRange           `json:"range"`
+	SelectionRange *LSPRange           `json:"selectionRange"`
+	Location       *LSPLocation        `json:"location"`
+	Children       []lspDocumentSymbol `json:"children"`
+}
+
+// parseLSPDocumentSymbols parses result of []DocumentSymbol or []SymbolInformation against moment
+func parseLSPDocumentSymbols(moment *Moment, result json.RawMessage) (symbols []OutlineSymbol, err error) {
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+	var items []lspDocumentSymbol
+	if err := json.Unmarshal(result, &items); err != nil {
+		return nil, err
+	}
+	var add func(items []lspDocumentSymbol)
+	add = func(items []lspDocumentSymbol) {
+		for _, item := range items {
+			var r, selection LSPRange
+			if item.Range != nil {
+				r = *item.Range
+				selection = r
+				if item.SelectionRange != nil {
+					selection = *item.SelectionRange
+				}
+			} else if item.Location != nil {
+				r = item.Location.Range
+				selection = r
+			} else {
+				continue
+			}
+			kind := ""
+			if item.Kind > 0 && item.Kind < len(lspSymbolKinds) {
+				kind = lspSymbolKinds[item.Kind]
+			}
+			symbols = append(symbols, OutlineSymbol{
+				Name:   item.Name,
+				Kind:   kind,
+				Detail: item.Detail,
+				Range: Range{
+					Begin: lspToPosition(moment, r.Start),
+					End:   lspToPosition(moment, r.End),
+				},
+				Position: lspToPosition(moment, selection.Start),
+			})
+			add(item.Children)
+		}
+	}
+	add(items)
+	setOutlineDepths(symbols)
+	return
+}
+
+// treeSitterPosition converts row and byte column of syntax tree to position
+func treeSitterPosition(moment *Moment, row int, col int) Position {
+	line := moment.GetLine(row)
+	if line == nil {
+		return Position{Line: row}
+	}
+	for _, cell := range line.Cells {
+		if cell.ByteOffset >= col {
+			return Position{Line: row, Cell: cell.RuneOffset}
+		}
+	}
+	return Position{Line: row, Cell: len(line.Cells)}
+}
+
+// treeSitterOutline returns functions, types, methods and fields in syntax tree of moment
+func treeSitterOutline(scope Scope, moment *Moment) []OutlineSymbol {
+	parser := moment.GetParser(scope)
+	if parser == nil {
+		return nil
+	}
+	return parserOutline(moment, parser)
+}
+
+func parserOutline(moment *Moment, parser *treesitter.Parser) (symbols []OutlineSymbol) {
+	bs := moment.GetBytes()
+	text := func(node treesitter.TSNode) string {
+		begin, end := treesitter.NodeByteRange(node)
+		if begin > end || end > len(bs) {
+			return ""
+		}
+		return string(bs[begin:end])
+	}
+	add := func(node treesitter.TSNode, name treesitter.TSNode, kind string, detail string) {
+		startRow, startCol, endRow, endCol := treesitter.NodePosition(node)
+		nameRow, nameCol, _, _ := treesitter.NodePosition(name)
+		symbols = append(symbols, OutlineSymbol{
+			Name:   strings.TrimSpace(text(name)),
+			Kind:   kind,
+			Detail: detail,
+			Range: Range{
+				Begin: treeSitterPosition(moment, startRow, startCol),
+				End:   treeSitterPosition(moment, endRow, endCol),
+			},
+			Position: treeSitterPosition(moment, nameRow, nameCol),
+		})
+	}
+
+	treesitter.Walk(parser.RootNode(), func(node treesitter.TSNode) {
+		switch treesitter.NodeType(node) {
+
+		case "function_declaration":
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				add(node, name, "Function", "")
+			}
+
+		case "method_declaration":
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				detail := ""
+				if receiver, ok := treesitter.ChildByFieldName(node, "receiver"); ok {
+					detail = text(receiver)
+				}
+				add(node, name, "Method", detail)
+			}
+
+		case "method_spec":
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				add(node, name, "Method", "")
+			}
+
+		case "type_spec", "type_alias":
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				kind := "Type"
+				if t, ok := treesitter.ChildByFieldName(node, "type"); ok {
+					switch treesitter.NodeType(t) {
+					case "struct_type":
+						kind = "Struct"
+					case "interface_type":
+						kind = "Interface"
+					}
+				}
+				add(node, name, kind, "")
+			}
+
+		case "field_declaration":
+			t, ok := treesitter.ChildByFieldName(node, "type")
+			if !ok {
+				return
+			}
+			embedded := true
+			for _, child := range treesitter.NamedChildren(node) {
+				if treesitter.NodeType(child) == "field_identifier" {
+					embedded = false
+					add(node, child, "Field", text(t))
+				}
+			}
+			if embedded {
+				add(node, t, "Field", "")
+			}
+
+		case "function_definition", "class_definition":
+			// python, shell
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				kind := "Function"
+				if treesitter.NodeType(node) == "class_definition" {
+					kind = "Class"
+				}
+				add(node, name, kind, "")
+			} else if name, ok := cDeclaratorName(node); ok {
+				// c
+				add(node, name, "Function", "")
+			}
+
+		case "struct_specifier", "union_specifier", "enum_specifier":
+			// c, definitions only
+			if _, ok := treesitter.ChildByFieldName(node, "body"); !ok {
+				return
+			}
+			if name, ok := treesitter.ChildByFieldName(node, "name"); ok {
+				kind := "Struct"
+				if treesitter.NodeType(node) == "enum_specifier" {
+					kind = "Enum"
+				}
+				add(node, name, kind, "")
+			}
+
+		case "type_definition":
+			// c
+			if name, ok := cDeclaratorName(node); ok {
+				add(node, name, "Type", "")
+			}
+
+		case "section":
+			// markdown
+			for _, child := range treesitter.NamedChildren(node) {
+				switch treesitter.NodeType(child) {
+				case "atx_heading", "setext_heading":
+					if name, ok := treesitter.ChildByFieldName(child, "heading_content"); ok {
+						add(node, name, "String", "")
+					}
+				}
+			}
+
+		}
+	})
+
+	setOutlineDepths(symbols)
+	return
+}
+
+// cDeclaratorName returns the identifier of nested declarators of c declaration
+func cDeclaratorName(node treesitter.TSNode) (treesitter.TSNode, bool) {
+	for {
+		declarator, ok := treesitter.ChildByFieldName(node, "declarator")
+		if !ok {
+			return declarator, false
+		}
+		switch treesitter.NodeType(declarator) {
+		case "identifier", "field_identifier", "type_identifier":
+			return declarator, true
+		}
+		node = declarator
+	}
+}
+
+type Outline struct {
+	Show    bool
+	symbols map[*Buffer]*outlineSymbols
+}
+
+type outlineSymbols struct {
+	moment  *Moment
+	symbols []OutlineSymbol
+	pending bool // language server request
+}
+
+func (_ Provide) Outline() *Outline {
+	return &Outline{
+		symbols: make(map[*Buffer]*outlineSymbols),
+	}
+}
+
+// GetOutline returns symbols of current moment of view,
+// if not wait, symbols of previous moment may be returned while requesting language server
+type GetOutline func(view *View, wait bool) []OutlineSymbol
+
+func (_ Provide) GetOutline(
+	scope Scope,
+	outline *Outline,
+	client *LSPClient,
+	run RunInMainLoop,
+	j AppendJournal,
+) GetOutline {
+	return func(view *View, wait bool) []OutlineSymbol {
+		buffer := view.Buffer
+		moment := view.GetMoment()
+		cache, ok := outline.symbols[buffer]
+		if !ok {
+			cache = new(outlineSymbols)
+			outline.symbols[buffer] = cache
+		}
+		if cache.moment == moment {
+			return cache.symbols
+		}
+
+		doc, ok := client.Documents[buffer]
+		if !ok {
+			cache.moment = moment
+			cache.symbols = treeSitterOutline(scope, moment)
+			return cache.symbols
+		}
+
+		setResult := func(result json.RawMessage, err error) {
+			if err == nil {
+				var symbols []OutlineSymbol
+				symbols, err = parseLSPDocumentSymbols(moment, result)
+				if err == nil {
+					cache.moment = moment
+					cache.symbols = symbols
+					return
+				}
+			}
+			if errors.Is(err, ErrLSPCanceled) {
+				return
+			}
+			j("document symbols: %v", err)
+			// not requesting again for this moment
+			cache.moment = moment
+			cache.symbols = treeSitterOutline(scope, moment)
+		}
+
+		if wait {
+			client.sync(buffer, moment)
+			var result json.RawMessage
+			err := doc.Endpoint.Req("textDocument/documentSymbol", M{
+				"textDocument": M{
+					"uri": doc.URI,
+				},
+			}).Result(&result)
+			setResult(result, err)
+			return cache.symbols
+		}
+
+		if !cache.pending {
+			cache.pending = true
+			client.sync(buffer, moment)
+			call := doc.Endpoint.Req("textDocument/documentSymbol", M{
+				"textDocument": M{
+					"uri": doc.URI,
+				},
+			})
+			client.cancelOnChange(buffer, call)
+			call.Then(func(call *LSPCall) {
+				var result json.RawMessage
+				err := call.Result(&result)
+				run(func() {
+					cache.pending = false
+					setResult(result, err)
+				})
+			})
+		}
+		return cache.symbols
+	}
+}
+
+func (_ Provide) OutlineCleanup(
+	on On,
+	outline *Outline,
+) OnStartup {
+	return func() {
+		on(func(
+			ev EvViewClosed,
+			views Views,
+		) {
+			for _, view := range views {
+				if view.Buffer == ev.View.Buffer {
+					return
+				}
+			}
+			delete(outline.symbols, ev.View.Buffer)
+		})
+	}
+}
+
+// moveCursorToCell moves cursor of current view to pos
+func moveCursorToCell(view *View, pos Position, moveCursor MoveCursor) {
+	col := 0
+	if line := view.GetMoment().GetLine(pos.Line); line != nil && pos.Cell < len(line.Cells) {
+		col = line.Cells[pos.Cell].DisplayOffset
+	}
+	moveCursor(Move{AbsLine: &pos.Line, AbsCol: &col})
+}
+
+func OutlineUI(
+	box Box,
+	cur CurrentView,
+	outline *Outline,
+	getOutline GetOutline,
+	getStyle GetStyle,
+	style Style,
+	config UIConfig,
+) Element {
+
+	style = darkerOrLighterStyle(style, 15)
+	hlStyle := getStyle("Highlight")(style)
+	fg, _, _ := hlStyle.Decompose()
+	hlStyle = style.Foreground(fg)
+
+	var symbols []OutlineSymbol
+	focusLine := 0
+	view := cur()
+	if view != nil {
+		symbols = getOutline(view, false)
+		pos := view.cursorPosition()
+		if pos.Line < 0 {
+			pos = Position{Line: view.CursorLine}
+		}
+		if i := currentOutlineSymbol(symbols, pos, config.MaxOutlineDistance); i >= 0 {
+			focusLine = i
+		}
+	}
+
+	title := "outline"
+	if view != nil {
+		title = view.Buffer.Path
+	}
+
+	return Rect(
+		box,
+		style,
+		Fill(true),
+		Text(
+			Box{box.Top, box.Left + 1, box.Top + 1, box.Right - 1},
+			title,
+			Bold(true), AlignLeft, Fill(true), style,
+		),
+		ElementWith(
+			VerticalScroll(
+				ElementFrom(func(
+					box Box,
+				) (ret []Element) {
+					for i, symbol := range symbols {
+						s := style
+						if i == focusLine {
+							s = hlStyle
+						}
+						ret = append(ret, Text(
+							Box{
+								Top:    box.Top + i,
+								Left:   box.Left,
+								Right:  box.Right,
+								Bottom: box.Top + i + 1,
+							},
+							strings.Repeat("  ", symbol.Depth)+symbol.Name,
+							s,
+							AlignLeft,
+							Fill(true),
+						))
+					}
+					return
+				}),
+				focusLine,
+			),
+			func() Box {
+				return Box{box.Top + 1, box.Left + 1, box.Bottom, box.Right - 1}
+			},
+		),
+	)
+}
+
+func (_ Command) ToggleOutline() (spec CommandSpec) {
+	spec.Desc = "toggle source outline panel"
+	spec.Func = func(
+		outline *Outline,
+	) {
+		outline.Show = !outline.Show
+	}
+	return
+}
+
+func ShowOutline(
+	cur CurrentView,
+	getOutline GetOutline,
+	pushJump PushJump,
+	moveCursor MoveCursor,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowMessage,
+	config UIConfig,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	symbols := getOutline(view, true)
+	if len(symbols) == 0 {
+		show([]string{"no symbols"})
+		return
+	}
+	pos := view.cursorPosition()
+	if pos.Line < 0 {
+		pos = Position{Line: view.CursorLine}
+	}
+	current := currentOutlineSymbol(symbols, pos, config.MaxOutlineDistance)
+
+	type Candidate struct {
+		Index    int
+		MatchLen int
+		Score    int
+	}
+	var candidates []Candidate
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: "Outline",
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(candidates) {
+				return
+			}
+			pushJump()
+			moveCursorToCell(view, symbols[candidates[i].Index].Position, moveCursor)
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			candidates = candidates[:0]
+			for i, symbol := range symbols {
+				chars := util.RunesToChars([]rune(symbol.Name))
+				matched, matchLen, score := fuzzyMatched(runes, &chars)
+				if !matched {
+					continue
+				}
+				if w := displayWidth(strings.Repeat("  ", symbol.Depth) + symbol.text()); w > maxLen {
+					maxLen = w
+				}
+				candidates = append(candidates, Candidate{
+					Index:    i,
+					MatchLen: matchLen,
+					Score:    score,
+				})
+			}
+			if len(runes) > 0 {
+				// by score, document order if no pattern
+				sort.SliceStable(candidates, func(i, j int) bool {
+					return candidates[i].Score > candidates[j].Score
+				})
+			}
+			for i, candidate := range candidates {
+				if len(runes) == 0 && candidate.Index == current {
+					initIndex = i
+				}
+				ids = append(ids, ID(i))
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			var getStyle GetStyle
+			scope.Assign(&box, &focus, &style, &getStyle)
+			s := style
+			if i == focus {
+				hlStyle := getStyle("Highlight")(s)
+				fg, _, _ := hlStyle.Decompose()
+				s = s.Foreground(fg)
+			}
+			candidate := candidates[i]
+			symbol := symbols[candidate.Index]
+			indent := symbol.Depth * 2
+			return Text(
+				box,
+				strings.Repeat("  ", symbol.Depth)+symbol.text(),
+				s,
+				OffsetStyleFunc(func(i int) StyleFunc {
+					fn := SameStyle
+					if i >= indent && i < indent+candidate.MatchLen {
+						fn = fn.SetUnderline(true)
+					} else {
+						fn = fn.SetUnderline(false)
+					}
+					return fn
+				}),
+			)
+		},
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) ShowOutline() (spec CommandSpec) {
+	spec.Desc = "show symbols of current buffer"
+	spec.Func = ShowOutline
+	return
+}
@@ -0,0 +1,308 @@
+package li
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+const outlineTestSource = `package main
+
+type Foo struct {
+	Bar int
+	baz, Qux string
+	Embedded
+}
+
+type Iface interface {
+	Do()
+}
+
+func (f *Foo) Method() {
+}
+
+func main() {
+}
+`
+
+func TestTreeSitterOutline(t *testing.T) {
+	withEditorBytes(t, []byte(outlineTestSource), func(
+		scope Scope,
+		buffer *Buffer,
+		view *View,
+		getOutline GetOutline,
+		outline *Outline,
+		ctrl func(string),
+		commands Commands,
+	) {
+		buffer.SetLanguage(scope, LanguageGo)
+		symbols := getOutline(view, false)
+		type S struct {
+			Name  string
+			Kind  string
+			Depth int
+			Line  int
+		}
+		var got []S
+		for _, symbol := range symbols {
+			got = append(got, S{symbol.Name, symbol.Kind, symbol.Depth, symbol.Position.Line})
+		}
+		eq(t,
+			got, []S{
+				{"Foo", "Struct", 0, 2},
+				{"Bar", "Field", 1, 3},
+				{"baz", "Field", 1, 4},
+				{"Qux", "Field", 1, 4},
+				{"Embedded", "Field", 1, 5},
+				{"Iface", "Interface", 0, 8},
+				{"Do", "Method", 1, 9},
+				{"Method", "Method", 0, 12},
+				{"main", "Function", 0, 15},
+			},
+			symbols[1].Detail, "int",
+			symbols[3].Position, Position{Line: 4, Cell: 6},
+			symbols[7].Detail, "(f *Foo)",
+		)
+
+		// cached
+		eq(t,
+			&getOutline(view, false)[0] == &symbols[0], true,
+		)
+
+		// panel
+		scope.Call(commands["ToggleOutline"].Func)
+		ctrl("loop")
+		eq(t,
+			outline.Show, true,
+		)
+	})
+}
+
+func TestTreeSitterOutlinePython(t *testing.T) {
+	withEditorBytes(t, []byte("class Foo:\n    def bar(self):\n        pass\n\ndef baz():\n    pass\n"), func(
+		scope Scope,
+		buffer *Buffer,
+		view *View,
+		getOutline GetOutline,
+	) {
+		buffer.SetLanguage(scope, LanguagePython)
+		symbols := getOutline(view, false)
+		eq(t,
+			len(symbols), 3,
+			symbols[0].Name, "Foo",
+			symbols[0].Kind, "Class",
+			symbols[1].Name, "bar",
+			symbols[1].Depth, 1,
+			symbols[2].Name, "baz",
+			symbols[2].Depth, 0,
+			symbols[2].Position.Line, 4,
+		)
+	})
+}
+
+func TestTreeSitterOutlineC(t *testing.T) {
+	withEditorBytes(t, []byte(`struct s {
+	int a;
+};
+
+typedef struct {
+	int b;
+} t;
+
+enum e { A };
+
+struct s *foo(int x) {
+	struct s *p;
+	return p;
+}
+`), func(
+		scope Scope,
+		buffer *Buffer,
+		view *View,
+		getOutline GetOutline,
+	) {
+		buffer.SetLanguage(scope, LanguageC)
+		type S struct {
+			Name  string
+			Kind  string
+			Depth int
+			Line  int
+		}
+		var got []S
+		for _, symbol := range getOutline(view, false) {
+			got = append(got, S{symbol.Name, symbol.Kind, symbol.Depth, symbol.Position.Line})
+		}
+		eq(t,
+			got, []S{
+				{"s", "Struct", 0, 0},
+				{"a", "Field", 1, 1},
+				{"t", "Type", 0, 6},
+				{"b", "Field", 1, 5},
+				{"e", "Enum", 0, 8},
+				{"foo", "Function", 0, 10},
+			},
+		)
+	})
+}
+
+func TestTreeSitterOutlineShell(t *testing.T) {
+	withEditorBytes(t, []byte("foo() {\n  echo\n}\n\nfunction bar {\n  echo\n}\n"), func(
+		scope Scope,
+		buffer *Buffer,
+		view *View,
+		getOutline GetOutline,
+	) {
+		buffer.SetLanguage(scope, LanguageShell)
+		symbols := getOutline(view, false)
+		eq(t,
+			len(symbols), 2,
+			symbols[0].Name, "foo",
+			symbols[0].Kind, "Function",
+			symbols[1].Name, "bar",
+			symbols[1].Position.Line, 4,
+		)
+	})
+}
+
+func TestTreeSitterOutlineMarkdown(t *testing.T) {
+	withEditorBytes(t, []byte("# foo\n\ntext\n\n## bar\n\n# baz\n\nqux\n---\n"), func(
+		scope Scope,
+		buffer *Buffer,
+		view *View,
+		getOutline GetOutline,
+	) {
+		buffer.SetLanguage(scope, LanguageMarkdown)
+		symbols := getOutline(view, false)
+		eq(t,
+			len(symbols), 4,
+			symbols[0].Name, "foo",
+			symbols[0].Depth, 0,
+			symbols[1].Name, "bar",
+			symbols[1].Depth, 1,
+			symbols[1].Position, Position{Line: 4, Cell: 3},
+			symbols[2].Name, "baz",
+			symbols[2].Depth, 0,
+			symbols[3].Name, "qux",
+		)
+	})
+}
+
+func TestParseLSPDocumentSymbols(t *testing.T) {
+	withEditorBytes(t, []byte(outlineTestSource), func(
+		moment *Moment,
+	) {
+		// hierarchical
+		symbols, err := parseLSPDocumentSymbols(moment, json.RawMessage(`[
+			{
+				"name": "Foo", "kind": 23, "detail": "struct{...}",
+				"range": {"start": {"line": 2, "character": 0}, "end": {"line": 6, "character": 1}},
+				"selectionRange": {"start": {"line": 2, "character": 5}, "end": {"line": 2, "character": 8}},
+				"children": [
+					{
+						"name": "Bar", "kind": 8,
+						"range": {"start": {"line": 3, "character": 1}, "end": {"line": 3, "character": 8}},
+						"selectionRange": {"start": {"line": 3, "character": 1}, "end": {"line": 3, "character": 4}}
+					}
+				]
+			},
+			{
+				"name": "main", "kind": 12,
+				"range": {"start": {"line": 15, "character": 0}, "end": {"line": 16, "character": 1}},
+				"selectionRange": {"start": {"line": 15, "character": 5}, "end": {"line": 15, "character": 9}}
+			}
+		]`))
+		ce(err)
+		eq(t,
+			len(symbols), 3,
+			symbols[0].Kind, "Struct",
+			symbols[0].Position, Position{Line: 2, Cell: 5},
+			symbols[1].Name, "Bar",
+			symbols[1].Depth, 1,
+			symbols[2].Name, "main",
+			symbols[2].Depth, 0,
+		)
+
+		// flat
+		symbols, err = parseLSPDocumentSymbols(moment, json.RawMessage(`[
+			{
+				"name": "Bar", "kind": 8, "containerName": "Foo",
+				"location": {"uri": "file:///tmp/foo.go", "range": {"start": {"line": 3, "character": 1}, "end": {"line": 3, "character": 8}}}
+			},
+			{
+				"name": "Foo", "kind": 23,
+				"location": {"uri": "file:///tmp/foo.go", "range": {"start": {"line": 2, "character": 0}, "end": {"line": 6, "character": 1}}}
+			}
+		]`))
+		ce(err)
+		eq(t,
+			len(symbols), 2,
+			symbols[0].Name, "Foo",
+			symbols[1].Name, "Bar",
+			symbols[1].Depth, 1,
+		)
+	})
+}
+
+func TestCurrentOutlineSymbol(t *testing.T) {
+	symbols := []OutlineSymbol{
+		{Name: "Foo", Range: Range{Position{2, 0}, Position{6, 1}}},
+		{Name: "Bar", Range: Range{Position{3, 1}, Position{3, 8}}},
+		{Name: "main", Range: Range{Position{15, 0}, Position{16, 1}}},
+	}
+	eq(t,
+		currentOutlineSymbol(symbols, Position{0, 0}, 10), -1,
+		currentOutlineSymbol(symbols, Position{3, 2}, 10), 1,
+		currentOutlineSymbol(symbols, Position{5, 0}, 10), 0,
+		currentOutlineSymbol(symbols, Position{10, 0}, 10), 0,
+		currentOutlineSymbol(symbols, Position{10, 0}, 2), -1,
+		currentOutlineSymbol(symbols, Position{16, 0}, 10), 2,
+	)
+}
+
+func TestShowOutline(t *testing.T) {
+	withEditorBytes(t, []byte(outlineTestSource), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+		jumps *JumpList,
+	) {
+		buffer.Path = "foo.go"
+		buffer.AbsPath = "/tmp/foo.go"
+		endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+			switch method {
+			case "textDocument/documentSymbol":
+				return []M{
+					{
+						"name": "Foo", "kind": 23,
+						"range":          M{"start": M{"line": 2, "character": 0}, "end": M{"line": 6, "character": 1}},
+						"selectionRange": M{"start": M{"line": 2, "character": 5}, "end": M{"line": 2, "character": 8}},
+					},
+					{
+						"name": "main", "kind": 12,
+						"range":          M{"start": M{"line": 15, "character": 0}, "end": M{"line": 16, "character": 1}},
+						"selectionRange": M{"start": M{"line": 15, "character": 5}, "end": M{"line": 15, "character": 9}},
+					},
+				}
+			}
+			return nil
+		})
+		client.open(endpoint, buffer, view.GetMoment())
+
+		scope.Call(ShowOutline)
+		ctrl("loop")
+		for _, r := range "mn" {
+			emitRune(r)
+		}
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			view.CursorLine, 15,
+			view.CursorCol, 5,
+			len(jumps.Jumps), 1,
+		)
+	})
+}
@@ -0,0 +1,440 @@
+package li
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SaveHistoryConfig struct {
+	Enable     bool
+	MaxEntries int // per file
+	MaxAgeDays int
+}
+
+func (_ Provide) SaveHistoryConfig(
+	get GetConfig,
+) SaveHistoryConfig {
+	var config struct {
+		SaveHistory SaveHistoryConfig
+	}
+	config.SaveHistory.Enable = true
+	config.SaveHistory.MaxEntries = 100
+	config.SaveHistory.MaxAgeDays = 30
+	ce(get(&config))
+	return config.SaveHistory
+}
+
+type SaveHistoryEntry struct {
+	Time time.Time
+	Hash string
+	Size int
+}
+
+// SaveHistory stores contents of saved files, content-addressed under Dir
+type SaveHistory struct {
+	sync.Mutex
+	Dir    string
+	Config SaveHistoryConfig
+}
+
+type saveHistoryIndex struct {
+	Path    string
+	Entries []SaveHistoryEntry // oldest first
+}
+
+func (_ Provide) SaveHistory(
+	dir ConfigDir,
+	config SaveHistoryConfig,
+) *SaveHistory {
+	return &SaveHistory{
+		Dir:    filepath.Join(string(dir), "history"),
+		Config: config,
+	}
+}
+
+func (h *SaveHistory) objectPath(hash string) string {
+	return filepath.Join(h.Dir, "objects", hash[:2], hash[2:])
+}
+
+func (h *SaveHistory) indexPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(h.Dir, "index", hex.EncodeToString(sum[:16])+".json")
+}
+
+func (h *SaveHistory) readIndex(indexPath string) (index saveHistoryIndex, err error) {
+	defer he(&err)
+	content, err := ioutil.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	ce(err)
+	ce(json.Unmarshal(content, &index))
+	return
+}
+
+func (h *SaveHistory) writeIndex(indexPath string, index saveHistoryIndex) (err error) {
+	defer he(&err)
+	content, err := json.Marshal(index)
+	ce(err)
+	ce(os.MkdirAll(filepath.Dir(indexPath), 0755))
+	tmp := indexPath + ".tmp"
+	ce(ioutil.WriteFile(tmp, content, 0644))
+	ce(os.Rename(tmp, indexPath))
+	return
+}
+
+// Record adds content of path to history
+func (h *SaveHistory) Record(path string, content []byte, t time.Time) (err error) {
+	defer he(&err)
+	h.Lock()
+	defer h.Unlock()
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// object
+	objectPath := h.objectPath(hash)
+	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
+		ce(os.MkdirAll(filepath.Dir(objectPath), 0755))
+		tmp := objectPath + ".tmp"
+		ce(ioutil.WriteFile(tmp, content, 0644))
+		ce(os.Rename(tmp, objectPath))
+	}
+
+	// index
+	indexPath := h.indexPath(path)
+	index, err := h.readIndex(indexPath)
+	ce(err)
+	index.Path = path
+	if n := len(index.Entries); n > 0 && index.Entries[n-1].Hash == hash {
+		// not changed since last save
+		return nil
+	}
+	index.Entries = append(index.Entries, SaveHistoryEntry{
+		Time: t,
+		Hash: hash,
+		Size: len(content),
+	})
+
+	// retention
+	var dropped []SaveHistoryEntry
+	if h.Config.MaxAgeDays > 0 {
+		deadline := t.Add(-time.Hour * 24 * time.Duration(h.Config.MaxAgeDays))
+		for len(index.Entries) > 1 && index.Entries[0].Time.Before(deadline) {
+			dropped = append(dropped, index.Entries[0])
+			index.Entries = index.Entries[1:]
+		}
+	}
+	if max := h.Config.MaxEntries; max > 0 && len(index.Entries) > max {
+		dropped = append(dropped, index.Entries[:len(index.Entries)-max]...)
+		index.Entries = index.Entries[len(index.Entries)-max:]
+	}
+	ce(h.writeIndex(indexPath, index))
+
+	if len(dropped) > 0 {
+		ce(h.collect(dropped))
+	}
+
+	return
+}
+
+// collect deletes objects of dropped entries that no index refers to
+func (h *SaveHistory) collect(dropped []SaveHistoryEntry) (err error) {
+	defer he(&err)
+	referenced := make(map[string]bool)
+	indexPaths, err := filepath.Glob(filepath.Join(h.Dir, "index", "*.json"))
+	ce(err)
+	for _, indexPath := range indexPaths {
+		index, err := h.readIndex(indexPath)
+		ce(err)
+		for _, entry := range index.Entries {
+			referenced[entry.Hash] = true
+		}
+	}
+	for _, entry := range dropped {
+		if referenced[entry.Hash] {
+			continue
+		}
+		if err := os.Remove(h.objectPath(entry.Hash)); err != nil && !os.IsNotExist(err) {
+			ce(err)
+		}
+	}
+	return
+}
+
+// Entries returns saves of path, newest first
+func (h *SaveHistory) Entries(path string) (entries []SaveHistoryEntry, err error) {
+	h.Lock()
+	defer h.Unlock()
+	index, err := h.readIndex(h.indexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	for i := len(index.Entries) - 1; i >= 0; i-- {
+		entries = append(entries, index.Entries[i])
+	}
+	return
+}
+
+func (h *SaveHistory) Load(entry SaveHistoryEntry) ([]byte, error) {
+	return ioutil.ReadFile(h.objectPath(entry.Hash))
+}
+
+func (_ Provide) SaveHistoryRecording(
+	on On,
+	config SaveHistoryConfig,
+	j AppendJournal,
+) OnStartup {
+	return func() {
+		if !config.Enable {
+			return
+		}
+		on(func(
+			ev EvBufferSynced,
+			history *SaveHistory,
+		) {
+			if err := history.Record(
+				ev.Buffer.AbsPath,
+				[]byte(ev.Moment.GetContent()),
+				time.Now(),
+			); err != nil {
+				j("save history: %s", err.Error())
+			}
+		})
+	}
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fM", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.1fK", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+func ShowSaveHistory(
+	cur CurrentView,
+	history *SaveHistory,
+	pushOverlay PushOverlay,
+	newMoment NewMomentFromBytes,
+	show ShowMessage,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if view.Buffer.Path == "" {
+		show([]string{"buffer is not associated with a file"})
+		return
+	}
+	entries, err := history.Entries(view.Buffer.AbsPath)
+	if err != nil {
+		show(strings.Split(err.Error(), "\n"))
+		return
+	}
+	if len(entries) == 0 {
+		show([]string{"no save history of " + view.Buffer.Path})
+		return
+	}
+
+	moments := make(map[string]*Moment)
+	load := func(entry SaveHistoryEntry) (*Moment, error) {
+		if m, ok := moments[entry.Hash]; ok {
+			return m, nil
+		}
+		content, err := history.Load(entry)
+		if err != nil {
+			return nil, err
+		}
+		m, _, err := newMoment(content)
+		if err != nil {
+			return nil, err
+		}
+		moments[entry.Hash] = m
+		return m, nil
+	}
+
+	index := 0
+
+	// diff preview of selected entry
+	diffIndex := -1
+	var diffMoment *Moment
+	var diffLines []string
+
+	var id ID
+	dialog := WidgetDialog{
+
+		OnKey: func(
+			ev KeyEvent,
+			scope Scope,
+			closeOverlay CloseOverlay,
+			apply ApplyChange,
+			showDiff ShowDiff,
+		) {
+			switch ev.Name() {
+			case "Up", "Rune[k]":
+				if index > 0 {
+					index--
+				}
+			case "Down", "Rune[j]":
+				if index < len(entries)-1 {
+					index++
+				}
+			case "Enter":
+				// restore into a new moment
+				closeOverlay(id)
+				saved, err := load(entries[index])
+				if err != nil {
+					show(strings.Split(err.Error(), "\n"))
+					return
+				}
+				moment := view.GetMoment()
+				newMoment := replaceMomentLines(
+					apply,
+					moment,
+					0,
+					moment.NumLines(),
+					linesText(saved, 0, saved.NumLines()-1),
+				)
+				view.switchMoment(scope, newMoment)
+			case "Rune[d]":
+				closeOverlay(id)
+				saved, err := load(entries[index])
+				if err != nil {
+					show(strings.Split(err.Error(), "\n"))
+					return
+				}
+				moment := view.GetMoment()
+				showDiff(
+					DiffSide{
+						Name:   "saved:" + entries[index].Time.Format("2006-01-02 15:04:05"),
+						Moment: saved,
+					},
+					DiffSide{
+						Name:   momentName(view.Buffer, moment),
+						Buffer: view.Buffer,
+						Moment: moment,
+					},
+				)
+			case "Esc", "Rune[q]":
+				closeOverlay(id)
+			}
+		},
+
+		Element: ElementFrom(func(
+			box Box,
+			defaultStyle Style,
+			getStyle GetStyle,
+		) Element {
+
+			style := darkerOrLighterStyle(defaultStyle, -10)
+			hlStyle := getStyle("Highlight")(style)
+			fg, _, _ := hlStyle.Decompose()
+			selectedStyle := style.Foreground(fg).Bold(true)
+
+			dialogBox := Box{
+				Top:    box.Top + 1,
+				Left:   box.Left + 2,
+				Bottom: box.Bottom - 1,
+				Right:  box.Right - 2,
+			}
+			contentBox := Box{
+				Top:    dialogBox.Top + 2,
+				Left:   dialogBox.Left + 2,
+				Bottom: dialogBox.Bottom - 1,
+				Right:  dialogBox.Right - 2,
+			}
+			listWidth := contentBox.Width() / 3
+
+			// entries
+			height := contentBox.Height()
+			begin := 0
+			if index >= height {
+				begin = index - height + 1
+			}
+			var listElements []Element
+			for i := begin; i < len(entries) && i-begin < height; i++ {
+				entry := entries[i]
+				s := style
+				if i == index {
+					s = selectedStyle
+				}
+				listElements = append(listElements, Text(
+					Box{
+						Top:    contentBox.Top + i - begin,
+						Left:   contentBox.Left,
+						Bottom: contentBox.Top + i - begin + 1,
+						Right:  contentBox.Left + listWidth,
+					},
+					fmt.Sprintf(
+						"%s %s",
+						entry.Time.Format("01-02 15:04:05"),
+						formatSize(entry.Size),
+					),
+					s,
+				))
+			}
+
+			// diff preview
+			if moment := view.GetMoment(); index != diffIndex || moment != diffMoment {
+				diffIndex = index
+				diffMoment = moment
+				saved, err := load(entries[index])
+				if err != nil {
+					diffLines = strings.Split(err.Error(), "\n")
+				} else {
+					diffLines = momentDiffLines(moment, saved)
+				}
+				if len(diffLines) == 0 {
+					diffLines = []string{"(no changes)"}
+				}
+			}
+
+			return Rect(
+				dialogBox,
+				style,
+				Fill(true),
+				Text(
+					Box{
+						Top:    dialogBox.Top + 1,
+						Left:   contentBox.Left,
+						Bottom: dialogBox.Top + 2,
+						Right:  contentBox.Right,
+					},
+					"Save History",
+					AlignCenter,
+					style.Bold(true),
+				),
+				listElements,
+				Text(
+					Box{
+						Top:    contentBox.Top,
+						Left:   contentBox.Left + listWidth + 2,
+						Bottom: contentBox.Bottom,
+						Right:  contentBox.Right,
+					},
+					diffLines,
+					style,
+				),
+			)
+		}),
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) ShowSaveHistory() (spec CommandSpec) {
+	spec.Desc = "browse saved versions of current file and restore one"
+	spec.Func = ShowSaveHistory
+	return
+}
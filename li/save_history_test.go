@@ -0,0 +1,104 @@
+package li
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestSaveHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	ce(err)
+	defer os.RemoveAll(dir)
+	h := &SaveHistory{
+		Dir: dir,
+		Config: SaveHistoryConfig{
+			MaxEntries: 2,
+		},
+	}
+
+	t0 := time.Now()
+	ce(h.Record("/foo", []byte("a"), t0))
+	ce(h.Record("/foo", []byte("a"), t0.Add(time.Second)))
+	entries, err := h.Entries("/foo")
+	ce(err)
+	eq(t,
+		len(entries), 1,
+	)
+	first := entries[0]
+
+	ce(h.Record("/foo", []byte("b"), t0.Add(time.Second*2)))
+	ce(h.Record("/bar", []byte("b"), t0.Add(time.Second*2)))
+	ce(h.Record("/foo", []byte("c"), t0.Add(time.Second*3)))
+	entries, err = h.Entries("/foo")
+	ce(err)
+	eq(t,
+		len(entries), 2,
+		entries[0].Size, 1,
+		entries[0].Time.Equal(t0.Add(time.Second*3)), true,
+	)
+	content, err := h.Load(entries[1])
+	ce(err)
+	eq(t,
+		string(content), "b",
+	)
+	_, err = h.Load(first)
+	eq(t,
+		os.IsNotExist(err), true,
+	)
+
+	// max age
+	entries, err = h.Entries("/bar")
+	ce(err)
+	b := entries[0]
+	h.Config.MaxAgeDays = 1
+	ce(h.Record("/bar", []byte("c"), t0.Add(time.Hour*48)))
+	entries, err = h.Entries("/bar")
+	ce(err)
+	eq(t,
+		len(entries), 1,
+	)
+	// still referenced by /foo
+	_, err = os.Stat(h.objectPath(b.Hash))
+	eq(t,
+		err, nil,
+	)
+}
+
+func TestShowSaveHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	ce(err)
+	defer os.RemoveAll(dir)
+	h := &SaveHistory{
+		Dir: filepath.Join(dir, "history"),
+	}
+	path := filepath.Join(dir, "foo")
+	ce(ioutil.WriteFile(path, []byte("b\n"), 0644))
+
+	withEditor(func(
+		scope Scope,
+		newBuffer NewBufferFromFile,
+		newView NewViewFromBuffer,
+		ctrl func(string),
+		emitKey EmitKey,
+	) {
+		buffer, err := newBuffer(path)
+		ce(err)
+		view, err := newView(buffer)
+		ce(err)
+		ce(h.Record(buffer.AbsPath, []byte("a\n"), time.Now()))
+
+		scope.Fork(&h).Call(ShowSaveHistory)
+		ctrl("loop")
+		emitKey(tcell.KeyEnter)
+		moment := view.GetMoment()
+		eq(t,
+			moment.GetContent(), "a\n",
+			moment.Previous != nil, true,
+		)
+	})
+}
@@ -34,7 +34,9 @@ func (_ Provide) ScrollToCursor(
 		// move viewport column
 		col := view.CursorCol
 		viewportCol := view.ViewportCol
-		if col < viewportCol {
+		if view.SoftWrap {
+			viewportCol = 0
+		} else if col < viewportCol {
 			viewportCol = viewportCol - (viewportCol - col)
 		} else if col >= viewportCol+view.Box.Width() {
 			viewportCol -= viewportCol + view.Box.Width() - col - 1
@@ -83,19 +85,24 @@ func (v *View) calculateViewportLineRange(
 		paddingBottom = 0
 	}
 
-	var calLineHeights CalculateLineHeights
+	var calLineHeights CalculateViewLineHeights
 	scope.Assign(&calLineHeights)
-	lineHeights := calLineHeights(moment, [2]int{
+	lineHeights := calLineHeights(v, moment, [2]int{
 		line - v.Box.Height() - 1, line,
 	})
+	heightOf := func(l int) int {
+		if h, ok := lineHeights[l]; ok {
+			return h
+		}
+		if h, ok := calLineHeights(v, moment, [2]int{l, l + 1})[l]; ok {
+			return h
+		}
+		return 1
+	}
 
 	min = line
 	height := v.Box.Height() - paddingBottom
-	if h, ok := lineHeights[line]; ok {
-		height -= h
-	} else {
-		height--
-	}
+	height -= heightOf(line)
 	for {
 		if min < 0 {
 			min = 0
@@ -105,11 +112,7 @@ func (v *View) calculateViewportLineRange(
 		if l < 0 {
 			break
 		}
-		if h, ok := lineHeights[l]; ok {
-			height -= h
-		} else {
-			height--
-		}
+		height -= heightOf(l)
 		if height < 0 {
 			break
 		}
@@ -127,11 +130,7 @@ func (v *View) calculateViewportLineRange(
 		if l < 0 {
 			break
 		}
-		if h, ok := lineHeights[l]; ok {
-			height -= h
-		} else {
-			height--
-		}
+		height -= heightOf(l)
 		if height < 0 {
 			break
 		}
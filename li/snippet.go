@@ -0,0 +1,485 @@
+package li
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reusee/e4"
+	"github.com/reusee/toml"
+)
+
+type Snippet struct {
+	Trigger string
+	Desc    string
+	Body    string
+}
+
+type Snippets map[Language][]Snippet
+
+var builtinSnippets = map[Language][]Snippet{
+	LanguageGo: {
+		{
+			Trigger: "iferr",
+			Desc:    "return if error",
+			Body:    "if err != nil {\n\treturn ${1:err}\n}$0",
+		},
+		{
+			Trigger: "tabletest",
+			Desc:    "table driven test",
+			Body: `func Test${1:Name}(t *testing.T) {
+	tests := []struct {
+		name string
+		$2
+	}{
+		$3
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			$0
+		})
+	}
+}`,
+		},
+		{
+			Trigger: "handler",
+			Desc:    "http handler function",
+			Body:    "func ${1:handler}(w http.ResponseWriter, r *http.Request) {\n\t$0\n}",
+		},
+	},
+}
+
+func languageSnippetFileName(lang Language) string {
+	return strings.ToLower(strings.TrimPrefix(lang.String(), "Language")) + ".toml"
+}
+
+// Snippets loads builtin snippets and user snippets in ConfigDir/snippets, user snippets override builtin ones with the same trigger
+func (_ Provide) Snippets(
+	dir ConfigDir,
+) Snippets {
+	snippets := make(Snippets)
+	for lang, builtin := range builtinSnippets {
+		snippets[lang] = append(snippets[lang], builtin...)
+	}
+
+	for _, spec := range languageSpecs {
+		lang := spec.Language
+		path := filepath.Join(string(dir), "snippets", languageSnippetFileName(lang))
+		content, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		ce(err, e4.NewInfo("read %s", path))
+		var file struct {
+			Snippets []Snippet
+		}
+		ce(toml.Unmarshal(content, &file), e4.NewInfo("parse %s", path))
+
+		for _, snippet := range file.Snippets {
+			replaced := false
+			for i, s := range snippets[lang] {
+				if s.Trigger == snippet.Trigger {
+					snippets[lang][i] = snippet
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				snippets[lang] = append(snippets[lang], snippet)
+			}
+		}
+	}
+
+	return snippets
+}
+
+// rank snippets above words
+const snippetCompletionRank = 1000
+
+type snippetPart struct {
+	Text      string
+	IsTabstop bool
+	Tabstop   int
+}
+
+// parseSnippet parses $1, ${1} and ${1:default} tabstops, a tabstop number may appear multiple times as mirrors
+func parseSnippet(body string) (parts []snippetPart, defaults map[int]string) {
+	defaults = make(map[int]string)
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, snippetPart{
+				Text: text.String(),
+			})
+			text.Reset()
+		}
+	}
+	hasFinal := false
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) && (runes[i+1] == '$' || runes[i+1] == '\\') {
+			text.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		if r != '$' || i+1 >= len(runes) {
+			text.WriteRune(r)
+			continue
+		}
+
+		j := i + 1
+		braced := runes[j] == '{'
+		if braced {
+			j++
+		}
+		num := 0
+		numBegin := j
+		for j < len(runes) && runes[j] >= '0' && runes[j] <= '9' {
+			num = num*10 + int(runes[j]-'0')
+			j++
+		}
+		if j == numBegin {
+			// not a tabstop
+			text.WriteRune(r)
+			continue
+		}
+
+		if braced {
+			var def strings.Builder
+			if j < len(runes) && runes[j] == ':' {
+				j++
+				for j < len(runes) && runes[j] != '}' {
+					if runes[j] == '\\' && j+1 < len(runes) {
+						j++
+					}
+					def.WriteRune(runes[j])
+					j++
+				}
+			}
+			if j >= len(runes) || runes[j] != '}' {
+				// unterminated
+				text.WriteRune(r)
+				continue
+			}
+			j++
+			if _, ok := defaults[num]; !ok && def.Len() > 0 {
+				defaults[num] = def.String()
+			}
+		}
+
+		flush()
+		parts = append(parts, snippetPart{
+			IsTabstop: true,
+			Tabstop:   num,
+		})
+		if num == 0 {
+			hasFinal = true
+		}
+		i = j - 1
+	}
+	flush()
+
+	if !hasFinal {
+		parts = append(parts, snippetPart{
+			IsTabstop: true,
+			Tabstop:   0,
+		})
+	}
+	return
+}
+
+type SnippetSession struct {
+	Moment *Moment // moment of last sync
+	Begin  int     // byte offset of snippet in Moment
+	Index  int     // index of current tabstop in order
+	parts  []snippetPart
+	values map[int]string
+	order  []int
+}
+
+func (s *SnippetSession) render(parts []snippetPart) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if part.IsTabstop {
+			b.WriteString(s.values[part.Tabstop])
+		} else {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// split returns parts before and after the first occurrence of tabstop
+func (s *SnippetSession) split(tabstop int) (before []snippetPart, after []snippetPart) {
+	for i, part := range s.parts {
+		if part.IsTabstop && part.Tabstop == tabstop {
+			return s.parts[:i], s.parts[i+1:]
+		}
+	}
+	return s.parts, nil
+}
+
+// sync reads the value of current tabstop from moment, returns length of snippet in moment, or false if snippet is broken by edits
+func (s *SnippetSession) sync(moment *Moment) (length int, ok bool) {
+	if s.Index < 0 {
+		return len(s.render(s.parts)), true
+	}
+	tabstop := s.order[s.Index]
+	before, after := s.split(tabstop)
+	prefix := s.render(before)
+	suffix := s.render(after)
+	oldValue := s.values[tabstop]
+	oldContent := s.Moment.GetContent()
+	content := moment.GetContent()
+	valueLen := len(oldValue) + len(content) - len(oldContent)
+	valueBegin := s.Begin + len(prefix)
+	valueEnd := valueBegin + valueLen
+	if valueLen < 0 ||
+		valueEnd+len(suffix) > len(content) ||
+		content[s.Begin:valueBegin] != prefix ||
+		content[valueEnd:valueEnd+len(suffix)] != suffix {
+		return 0, false
+	}
+	s.values[tabstop] = content[valueBegin:valueEnd]
+	s.Moment = moment
+	return len(prefix) + valueLen + len(suffix), true
+}
+
+func (s *SnippetSession) descendsFrom(moment *Moment) bool {
+	for i := 0; moment != nil && i < 1024; i++ {
+		if moment == s.Moment {
+			return true
+		}
+		moment = moment.Previous
+	}
+	return false
+}
+
+type ExpandSnippet func(
+	snippet Snippet,
+	r Range,
+)
+
+func (_ Provide) ExpandSnippet(
+	cur CurrentView,
+	curMoment CurrentMoment,
+	scope Scope,
+	apply ApplyChange,
+	enable EnableEditMode,
+	jump JumpSnippetTabstop,
+) ExpandSnippet {
+	return func(
+		snippet Snippet,
+		r Range,
+	) {
+		view := cur()
+		if view == nil {
+			return
+		}
+		moment := curMoment()
+
+		// indent following lines as the current line
+		body := snippet.Body
+		if line := moment.GetLine(r.Begin.Line); line != nil {
+			indent := line.content[:len(line.content)-len(strings.TrimLeft(line.content, " \t"))]
+			body = strings.ReplaceAll(body, "\n", "\n"+indent)
+		}
+
+		parts, defaults := parseSnippet(body)
+		session := &SnippetSession{
+			Index:  -1,
+			parts:  parts,
+			values: defaults,
+		}
+		seen := make(map[int]bool)
+		for _, part := range parts {
+			if part.IsTabstop && part.Tabstop > 0 && !seen[part.Tabstop] {
+				seen[part.Tabstop] = true
+				session.order = append(session.order, part.Tabstop)
+			}
+		}
+		sort.Ints(session.order)
+		session.order = append(session.order, 0)
+
+		newMoment, _ := apply(moment, Change{
+			Op:     OpReplace,
+			Begin:  r.Begin,
+			End:    r.End,
+			String: session.render(parts),
+		})
+		view.switchMoment(scope, newMoment)
+		session.Moment = newMoment
+		session.Begin = newMoment.PositionToByteOffset(r.Begin)
+		view.Snippet = session
+		enable()
+
+		jump(1)
+	}
+}
+
+type JumpSnippetTabstop func(n int) bool
+
+func (_ Provide) JumpSnippetTabstop(
+	cur CurrentView,
+	scope Scope,
+	apply ApplyChange,
+	moveCursor MoveCursor,
+	j AppendJournal,
+) JumpSnippetTabstop {
+	return func(n int) bool {
+		view := cur()
+		if view == nil || view.Snippet == nil {
+			return false
+		}
+		session := view.Snippet
+		moment := view.GetMoment()
+		if !session.descendsFrom(moment) {
+			view.Snippet = nil
+			return false
+		}
+		length, ok := session.sync(moment)
+		if !ok {
+			j("snippet session ended by edits")
+			view.Snippet = nil
+			return false
+		}
+
+		// update mirrors
+		text := session.render(session.parts)
+		if moment.GetContent()[session.Begin:session.Begin+length] != text {
+			moment, _ = apply(moment, Change{
+				Op:     OpReplace,
+				Begin:  moment.ByteOffsetToPosition(session.Begin),
+				End:    moment.ByteOffsetToPosition(session.Begin + length),
+				String: text,
+			})
+			view.switchMoment(scope, moment)
+			session.Moment = moment
+		}
+
+		index := session.Index + n
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(session.order) {
+			index = len(session.order) - 1
+		}
+		session.Index = index
+
+		// move cursor to the end of tabstop value, and select the value so typing replaces it
+		tabstop := session.order[index]
+		before, _ := session.split(tabstop)
+		begin := session.Begin + len(session.render(before))
+		value := session.values[tabstop]
+		offset := begin + len(value)
+		view.SelectionAnchor = nil
+		if value != "" && tabstop != 0 {
+			// cursor at the last rune, selections include the cursor
+			_, size := utf8.DecodeLastRuneInString(value)
+			offset -= size
+			anchor := moment.ByteOffsetToPosition(begin)
+			view.SelectionAnchor = &anchor
+		}
+		pos := moment.ByteOffsetToPosition(offset)
+		col := 0
+		if line := moment.GetLine(pos.Line); line != nil && pos.Cell < len(line.Cells) {
+			col = line.Cells[pos.Cell].DisplayOffset
+		}
+		moveCursor(Move{AbsLine: intP(pos.Line), AbsCol: intP(col)})
+
+		if tabstop == 0 {
+			// final tabstop
+			view.Snippet = nil
+		}
+		return true
+	}
+}
+
+type DeleteSnippetPlaceholder func() bool
+
+func (_ Provide) DeleteSnippetPlaceholder(
+	cur CurrentView,
+	deleteSelected DeleteSelected,
+) DeleteSnippetPlaceholder {
+	return func() bool {
+		view := cur()
+		if view == nil || view.Snippet == nil || view.selectedRange() == nil {
+			return false
+		}
+		deleteSelected(nil)
+		return true
+	}
+}
+
+func (_ Provide) SnippetCompletion(
+	on On,
+	snippets Snippets,
+) OnStartup {
+	return func() {
+		on(func(
+			ev EvCollectCompletionCandidate,
+		) {
+			pattern, begin, end, ok := completionPattern(ev.Moment, ev.State)
+			if !ok {
+				return
+			}
+			for _, snippet := range snippets[ev.View.Buffer.language] {
+				snippet := snippet
+				if !strings.HasPrefix(snippet.Trigger, pattern) {
+					continue
+				}
+				var offsets []int
+				for i := range []rune(pattern) {
+					offsets = append(offsets, i)
+				}
+				ev.Add(CompletionCandidate{
+					Text:             snippet.Trigger,
+					Rank:             snippetCompletionRank,
+					MatchRuneOffsets: offsets,
+					Begin:            begin,
+					End:              end,
+					Apply: func(scope Scope) {
+						scope.Call(func(expand ExpandSnippet) {
+							expand(snippet, Range{begin, end})
+						})
+					},
+				})
+			}
+		})
+	}
+}
+
+func (_ Command) NextSnippetTabstop() (spec CommandSpec) {
+	spec.Desc = "jump to next snippet tabstop"
+	spec.Func = func(jump JumpSnippetTabstop) {
+		jump(1)
+	}
+	return
+}
+
+func (_ Command) PrevSnippetTabstop() (spec CommandSpec) {
+	spec.Desc = "jump to previous snippet tabstop"
+	spec.Func = func(jump JumpSnippetTabstop) {
+		jump(-1)
+	}
+	return
+}
+
+func (_ Command) NextSnippetTabstopOrInsertTab() (spec CommandSpec) {
+	spec.Desc = "jump to next snippet tabstop if expanding snippet, or insert tab"
+	spec.Func = func(
+		jump JumpSnippetTabstop,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+	) {
+		if jump(1) {
+			return
+		}
+		insert("\t", PositionFunc(posCursor))
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package li
+
+import "testing"
+
+func TestParseSnippet(t *testing.T) {
+	parts, defaults := parseSnippet(`${1:foo} \$1 $2 ${1}$0 ${3:a\}b}`)
+	eq(t,
+		len(parts), 8,
+		parts[0].IsTabstop, true,
+		parts[0].Tabstop, 1,
+		parts[1].Text, " $1 ",
+		parts[2].Tabstop, 2,
+		parts[4].Tabstop, 1,
+		parts[5].Tabstop, 0,
+		parts[7].Tabstop, 3,
+		defaults[1], "foo",
+		defaults[3], "a}b",
+	)
+
+	// implicit final tabstop
+	parts, _ = parseSnippet("foo")
+	eq(t,
+		len(parts), 2,
+		parts[1].IsTabstop, true,
+		parts[1].Tabstop, 0,
+	)
+}
+
+func TestExpandSnippet(t *testing.T) {
+	withEditorBytes(t, []byte("x\n"), func(
+		view *View,
+		scope Scope,
+		expand ExpandSnippet,
+		emitRune EmitRune,
+		jump JumpSnippetTabstop,
+	) {
+		expand(Snippet{
+			Body: "${1:ab} = $1 + ${2:c}$0",
+		}, Range{})
+		eq(t,
+			view.GetMoment().GetContent(), "ab = ab + cx\n",
+			view.CursorLine, 0,
+			view.CursorCol, 1,
+			view.Snippet != nil, true,
+			*view.selectedRange(), Range{Begin: Position{Line: 0, Cell: 0}, End: Position{Line: 0, Cell: 2}},
+		)
+
+		// typing replaces the default
+		emitRune('f')
+		emitRune('o')
+		emitRune('o')
+		eq(t,
+			view.GetMoment().GetContent(), "foo = ab + cx\n",
+			view.selectedRange() == nil, true,
+			jump(1), true,
+			view.GetMoment().GetContent(), "foo = foo + cx\n",
+			view.CursorCol, 12,
+			*view.selectedRange(), Range{Begin: Position{Line: 0, Cell: 12}, End: Position{Line: 0, Cell: 13}},
+		)
+
+		eq(t,
+			jump(1), true,
+			view.CursorCol, 13,
+			view.selectedRange() == nil, true,
+			view.Snippet == nil, true,
+			jump(1), false,
+		)
+	})
+}
+
+func TestSnippetCompletion(t *testing.T) {
+	withEditorBytes(t, []byte("ife\n"), func(
+		view *View,
+		trigger Trigger,
+	) {
+		view.Buffer.language = LanguageGo
+		state := view.ViewMomentState
+		state.CursorCol = 3
+		var candidates []CompletionCandidate
+		trigger(EvCollectCompletionCandidate{
+			Add: func(c CompletionCandidate) {
+				if c.Apply != nil {
+					candidates = append(candidates, c)
+				}
+			},
+			View:   view,
+			Moment: view.GetMoment(),
+			State:  state,
+		})
+		eq(t,
+			len(candidates), 1,
+			candidates[0].Text, "iferr",
+			candidates[0].End, Position{Line: 0, Cell: 3},
+		)
+	})
+}
@@ -0,0 +1,217 @@
+package li
+
+import "unicode"
+
+type SoftWrapConfig struct {
+	Enable           bool
+	Indicator        string
+	Indent           int
+	MoveByDisplayRow bool
+}
+
+func (_ Provide) SoftWrapConfig(
+	getConfig GetConfig,
+) SoftWrapConfig {
+	var config struct {
+		SoftWrap SoftWrapConfig
+	}
+	config.SoftWrap.Indicator = "↪"
+	config.SoftWrap.Indent = 2
+	config.SoftWrap.MoveByDisplayRow = true
+	ce(getConfig(&config))
+	return config.SoftWrap
+}
+
+// PrefixWidth returns display width of continuation row prefix
+func (c SoftWrapConfig) PrefixWidth() int {
+	w := displayWidth(c.Indicator)
+	if c.Indent > w {
+		w = c.Indent
+	}
+	return w
+}
+
+// wrapRows returns indexes of cells that begin display rows
+func wrapRows(line *Line, width int, prefixWidth int) (starts []int) {
+	starts = []int{0}
+	if width <= prefixWidth+1 {
+		return
+	}
+	avail := width
+	rowBegin := 0
+	rowWidth := 0
+	lastBreak := -1
+	for i, cell := range line.Cells {
+		for rowWidth+cell.DisplayWidth > avail && i > rowBegin {
+			// break at word boundary if possible, then at i if still not fit
+			brk := i
+			if lastBreak > rowBegin {
+				brk = lastBreak
+			}
+			starts = append(starts, brk)
+			rowBegin = brk
+			avail = width - prefixWidth
+			rowWidth = 0
+			for _, c := range line.Cells[brk:i] {
+				rowWidth += c.DisplayWidth
+			}
+			lastBreak = -1
+		}
+		rowWidth += cell.DisplayWidth
+		if unicode.IsSpace(cell.Rune) {
+			lastBreak = i + 1
+		}
+	}
+	return
+}
+
+// wrapRows returns row starts of line in view, one row if not wrapping
+func (v *View) wrapRows(line *Line, config SoftWrapConfig) []int {
+	if !v.SoftWrap || line == nil {
+		return []int{0}
+	}
+	return wrapRows(line, v.ContentBox.Width(), config.PrefixWidth())
+}
+
+// rowOfCol returns the display row containing col, and the column offset of that row
+func rowOfCol(line *Line, starts []int, col int) (row int, rowCol int) {
+	for i, start := range starts {
+		if start >= len(line.Cells) {
+			break
+		}
+		offset := line.Cells[start].DisplayOffset
+		if offset > col {
+			break
+		}
+		row = i
+		rowCol = offset
+	}
+	return
+}
+
+// rowColRange returns display column range of row
+func rowColRange(line *Line, starts []int, row int) (begin int, end int) {
+	if len(line.Cells) == 0 {
+		return 0, 0
+	}
+	if starts[row] < len(line.Cells) {
+		begin = line.Cells[starts[row]].DisplayOffset
+	}
+	if row+1 < len(starts) && starts[row+1] < len(line.Cells) {
+		end = line.Cells[starts[row+1]].DisplayOffset
+	} else {
+		end = line.DisplayWidth
+	}
+	return
+}
+
+type ViewCursorScreenPosition func(
+	view *View,
+	moment *Moment,
+) (
+	x int,
+	y int,
+)
+
+func (_ Provide) ViewCursorScreenPosition(
+	calLineHeight CalculateViewSumLineHeight,
+	config SoftWrapConfig,
+) ViewCursorScreenPosition {
+	return func(
+		view *View,
+		moment *Moment,
+	) (
+		x int,
+		y int,
+	) {
+		y = view.ContentBox.Top + calLineHeight(view, moment, [2]int{
+			view.ViewportLine, view.CursorLine,
+		})
+		x = view.ContentBox.Left + (view.CursorCol - view.ViewportCol)
+		if view.SoftWrap {
+			line := moment.GetLine(view.CursorLine)
+			if line == nil {
+				return
+			}
+			row, rowCol := rowOfCol(line, view.wrapRows(line, config), view.CursorCol)
+			y += row
+			x = view.ContentBox.Left + view.CursorCol - rowCol
+			if row > 0 {
+				x += config.PrefixWidth()
+			}
+		}
+		return
+	}
+}
+
+// moveByDisplayRow converts a relative line move to absolute position by wrapped display rows
+func (v *View) moveByDisplayRow(
+	scope Scope,
+	moment *Moment,
+	config SoftWrapConfig,
+	n int,
+) (line int, col int) {
+	line = v.CursorLine
+	l := moment.GetLine(line)
+	if l == nil {
+		return line, v.CursorCol
+	}
+	starts := v.wrapRows(l, config)
+	row, rowCol := rowOfCol(l, starts, v.CursorCol)
+	x := v.CursorCol - rowCol
+	for n > 0 {
+		if row < len(starts)-1 {
+			row++
+		} else {
+			next := v.nextVisibleLine(scope, moment, line)
+			if next >= moment.NumLines() {
+				break
+			}
+			line = next
+			l = moment.GetLine(line)
+			starts = v.wrapRows(l, config)
+			row = 0
+		}
+		n--
+	}
+	for n < 0 {
+		if row > 0 {
+			row--
+		} else {
+			prev := v.prevVisibleLine(scope, moment, line)
+			if prev < 0 {
+				break
+			}
+			line = prev
+			l = moment.GetLine(line)
+			starts = v.wrapRows(l, config)
+			row = len(starts) - 1
+		}
+		n++
+	}
+	begin, end := rowColRange(l, starts, row)
+	col = begin + x
+	if col >= end && end > begin {
+		col = end - 1
+	}
+	return
+}
+
+func ToggleSoftWrap(
+	cur CurrentView,
+	scrollToCursor ScrollToCursor,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	view.SoftWrap = !view.SoftWrap
+	view.ViewportCol = 0
+	scrollToCursor()
+}
+
+func (_ Command) ToggleSoftWrap() (spec CommandSpec) {
+	spec.Desc = "toggle soft wrapping of long lines in current view"
+	spec.Func = ToggleSoftWrap
+	return
+}
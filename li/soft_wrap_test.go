@@ -0,0 +1,64 @@
+package li
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapRows(t *testing.T) {
+	withEditorBytes(t, []byte("foo bar baz qux\nfoobarbaz\na bcdefghijk\n"), func(
+		moment *Moment,
+	) {
+		eq(t,
+			wrapRows(moment.GetLine(0), 8, 2), []int{0, 8, 12},
+			wrapRows(moment.GetLine(0), 100, 2), []int{0},
+			wrapRows(moment.GetLine(1), 4, 1), []int{0, 4, 7},
+			// word after break not fitting continuation row
+			wrapRows(moment.GetLine(2), 10, 2), []int{0, 2, 10},
+		)
+	})
+}
+
+func TestSoftWrap(t *testing.T) {
+	long := strings.Repeat("foo ", 30) + "\nbar\n"
+	withEditorBytes(t, []byte(long), func(
+		view *View,
+		scope Scope,
+		ctrl func(string),
+		moveCursor MoveCursor,
+		getScreenString GetScreenString,
+		calLineHeights CalculateViewLineHeights,
+	) {
+		ctrl("loop")
+		scope.Call(ToggleSoftWrap)
+		ctrl("loop")
+		eq(t,
+			view.SoftWrap, true,
+		)
+		width := view.ContentBox.Width()
+		rows := len(view.wrapRows(view.GetMoment().GetLine(0), SoftWrapConfig{Indicator: "↪", Indent: 2}))
+		eq(t,
+			rows > 1, true,
+			calLineHeights(view, view.GetMoment(), [2]int{0, 1})[0], rows,
+		)
+
+		lines := getScreenString(view.ContentBox)
+		eq(t,
+			strings.HasPrefix(lines[0], "foo foo"), true,
+			strings.HasPrefix(lines[1], "↪ foo"), true,
+			strings.HasPrefix(lines[rows], "bar"), true,
+			displayWidth(strings.TrimRight(lines[0], " ")) <= width, true,
+		)
+
+		// move by display row
+		moveCursor(Move{RelLine: 1})
+		eq(t,
+			view.CursorLine, 0,
+			view.CursorCol > 0, true,
+		)
+		moveCursor(Move{RelLine: rows})
+		eq(t,
+			view.CursorLine, 1,
+		)
+	})
+}
@@ -0,0 +1,82 @@
+package li
+
+import "sync"
+
+type SyntaxClass uint8
+
+const (
+	SyntaxKeyword SyntaxClass = iota + 1
+	SyntaxType
+	SyntaxLiteral
+	SyntaxBuiltin
+	SyntaxComment
+)
+
+func (s SyntaxStyles) ofClass(class SyntaxClass) StyleFunc {
+	switch class {
+	case SyntaxKeyword:
+		return s.Keyword
+	case SyntaxType:
+		return s.Type
+	case SyntaxLiteral:
+		return s.Literal
+	case SyntaxBuiltin:
+		return s.Builtin
+	case SyntaxComment:
+		return s.Comment
+	}
+	return nil
+}
+
+// SyntaxStainer styles cells by classes of tree-sitter node types
+type SyntaxStainer struct {
+	//TODO eviction
+	cache  sync.Map
+	styles map[string]StyleFunc
+}
+
+type syntaxStainerCacheKey struct {
+	MomentID
+	LineNumber
+}
+
+// newSyntaxStainer returns a LanguageSpec.Stainer func of node types by class
+func newSyntaxStainer(nodeTypes map[SyntaxClass][]string) func(SyntaxStyles) Stainer {
+	return func(syntaxStyles SyntaxStyles) Stainer {
+		styles := make(map[string]StyleFunc)
+		for class, types := range nodeTypes {
+			for _, t := range types {
+				styles[t] = syntaxStyles.ofClass(class)
+			}
+		}
+		return &SyntaxStainer{
+			styles: styles,
+		}
+	}
+}
+
+func (s *SyntaxStainer) Line() dyn {
+	return func(
+		moment *Moment,
+		lineNum LineNumber,
+		scope Scope,
+	) (
+		fns []StyleFunc,
+	) {
+
+		key := syntaxStainerCacheKey{moment.ID, lineNum}
+		if v, ok := s.cache.Load(key); ok {
+			return v.([]StyleFunc)
+		}
+
+		line := moment.GetLine(int(lineNum))
+		for _, cell := range line.Cells {
+			attr := moment.GetSyntaxAttr(scope, int(lineNum), cell.RuneOffset)
+			fns = append(fns, s.styles[attr])
+		}
+
+		s.cache.Store(key, fns)
+
+		return
+	}
+}
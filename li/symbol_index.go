@@ -0,0 +1,228 @@
+package li
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type IndexedSymbol struct {
+	OutlineSymbol
+	Path string
+}
+
+// SymbolIndex holds tree-sitter extracted symbols of files in workspaces
+type SymbolIndex struct {
+	sync.Mutex
+	roots map[string]chan struct{} // closed when indexed
+	files map[string]*indexedFile  // by absolute path
+}
+
+type indexedFile struct {
+	modTime time.Time
+	symbols []OutlineSymbol
+}
+
+const (
+	symbolIndexMaxFiles    = 20000
+	symbolIndexMaxFileSize = 1 << 20
+)
+
+var symbolIndexRootMarkers = []string{".git"}
+
+var errSymbolIndexFull = errors.New("too many files")
+
+func (_ Provide) SymbolIndex() *SymbolIndex {
+	return &SymbolIndex{
+		roots: make(map[string]chan struct{}),
+		files: make(map[string]*indexedFile),
+	}
+}
+
+// Symbols returns indexed symbols sorted by path, files in skip are excluded
+func (s *SymbolIndex) Symbols(skip map[string]bool) (ret []IndexedSymbol) {
+	s.Lock()
+	defer s.Unlock()
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		if !skip[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, symbol := range s.files[path].symbols {
+			ret = append(ret, IndexedSymbol{
+				OutlineSymbol: symbol,
+				Path:          path,
+			})
+		}
+	}
+	return
+}
+
+// indexFile parses file if modified since last indexing, returns false if language not supported
+func (s *SymbolIndex) indexFile(newMoment NewMomentFromBytes, path string) bool {
+	parse := languageParser(LanguageFromPath(path))
+	if parse == nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > symbolIndexMaxFileSize {
+		s.Lock()
+		delete(s.files, path)
+		s.Unlock()
+		return false
+	}
+	s.Lock()
+	file, ok := s.files[path]
+	s.Unlock()
+	if ok && file.modTime.Equal(info.ModTime()) {
+		return true
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	moment, _, err := newMoment(content)
+	if err != nil {
+		return false
+	}
+	parser := parse(moment)
+	symbols := parserOutline(moment, parser)
+	parser.Close()
+
+	s.Lock()
+	s.files[path] = &indexedFile{
+		modTime: info.ModTime(),
+		symbols: symbols,
+	}
+	s.Unlock()
+	return true
+}
+
+// IndexSymbols indexes files under root in background, once for each root
+type IndexSymbols func(root string) (done <-chan struct{})
+
+func (_ Provide) IndexSymbols(
+	index *SymbolIndex,
+	newMoment NewMomentFromBytes,
+	run RunInMainLoop,
+) IndexSymbols {
+	return func(root string) <-chan struct{} {
+		index.Lock()
+		done, ok := index.roots[root]
+		if ok {
+			index.Unlock()
+			return done
+		}
+		done = make(chan struct{})
+		index.roots[root] = done
+		index.Unlock()
+
+		go func() {
+			defer close(done)
+			t0 := time.Now()
+			n := 0
+			err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					// skip unreadable
+					return nil
+				}
+				if info.IsDir() {
+					name := info.Name()
+					if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if n >= symbolIndexMaxFiles {
+					return errSymbolIndexFull
+				}
+				if index.indexFile(newMoment, path) {
+					n++
+				}
+				return nil
+			})
+			run(func(
+				j AppendJournal,
+			) {
+				if err != nil {
+					j("index symbols in %s: %v", root, err)
+				}
+				j("indexed symbols of %d files in %s in %v", n, root, time.Since(t0))
+			})
+		}()
+
+		return done
+	}
+}
+
+// symbolIndexRoot returns workspace root of buffer for indexing
+func symbolIndexRoot(buffer *Buffer, getConfig GetConfig) string {
+	var c struct {
+		LanguageServerProtocol LanguageServerProtocolConfig
+	}
+	ce(getConfig(&c))
+	markers := symbolIndexRootMarkers
+	if config, ok := c.LanguageServerProtocol.Servers[languageConfigName(buffer.language)]; ok && len(config.RootMarkers) > 0 {
+		markers = config.RootMarkers
+	}
+	return findWorkspaceRoot(buffer.AbsDir, markers)
+}
+
+func (_ Provide) SymbolIndexing(
+	on On,
+	getConfig GetConfig,
+) OnStartup {
+	return func() {
+
+		var c struct {
+			LanguageServerProtocol LanguageServerProtocolConfig
+		}
+		ce(getConfig(&c))
+		config := c.LanguageServerProtocol
+
+		// index workspace of opened file if no language server
+		on(func(
+			ev EvBufferLanguageChanged,
+			indexSymbols IndexSymbols,
+		) {
+			if ev.Buffer.Path == "" {
+				return
+			}
+			if languageParser(ev.NewLang) == nil {
+				return
+			}
+			if config.Enable {
+				if server, ok := config.Servers[languageConfigName(ev.NewLang)]; ok && server.Command != "" {
+					return
+				}
+			}
+			indexSymbols(symbolIndexRoot(ev.Buffer, getConfig))
+		})
+
+		// reindex saved file
+		on(func(
+			ev EvBufferSynced,
+			index *SymbolIndex,
+			newMoment NewMomentFromBytes,
+		) {
+			index.Lock()
+			_, ok := index.files[ev.Buffer.AbsPath]
+			index.Unlock()
+			if !ok {
+				return
+			}
+			path := ev.Buffer.AbsPath
+			go index.indexFile(newMoment, path)
+		})
+
+	}
+}
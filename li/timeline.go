@@ -0,0 +1,270 @@
+package li
+
+import (
+	"fmt"
+	"time"
+)
+
+type TimelineConfig struct {
+	StepSeconds int
+}
+
+func (_ Provide) TimelineConfig(
+	get GetConfig,
+) TimelineConfig {
+	var config struct {
+		Timeline TimelineConfig
+	}
+	config.Timeline.StepSeconds = 60
+	ce(get(&config))
+	return config.Timeline
+}
+
+type Timeline struct {
+	Origin  *Moment
+	Moments []*Moment // ancestry of Origin, oldest first
+	Index   int
+	Changed map[int]bool // lines changed from Origin
+}
+
+func newTimeline(origin *Moment) *Timeline {
+	var moments []*Moment
+	for m := origin; m != nil; m = m.Previous {
+		moments = append(moments, m)
+	}
+	for i := 0; i < len(moments)/2; i++ {
+		moments[i], moments[len(moments)-1-i] = moments[len(moments)-1-i], moments[i]
+	}
+	return &Timeline{
+		Origin:  origin,
+		Moments: moments,
+		Index:   len(moments) - 1,
+	}
+}
+
+func (t *Timeline) Moment() *Moment {
+	return t.Moments[t.Index]
+}
+
+// indexByTime returns the index of moment d away from current moment in wall-clock time
+func (t *Timeline) indexByTime(d time.Duration) int {
+	target := t.Moment().T0.Add(d)
+	if d < 0 {
+		i := t.Index
+		for i > 0 && t.Moments[i].T0.After(target) {
+			i--
+		}
+		return i
+	}
+	i := t.Index
+	for i < len(t.Moments)-1 && t.Moments[i].T0.Before(target) {
+		i++
+	}
+	return i
+}
+
+// changedLines returns lines of to that are not in from
+func changedLines(from *Moment, to *Moment) map[int]bool {
+	ret := make(map[int]bool)
+	if from == to {
+		return ret
+	}
+	for _, diff := range diffMomentLines(from, to) {
+		if diff.Op == LineInsert {
+			ret[diff.B] = true
+		}
+	}
+	return ret
+}
+
+type TimelineMode struct {
+	View *View
+}
+
+var _ KeyStrokeHandler = new(TimelineMode)
+
+func (t *TimelineMode) StrokeSpecs() any {
+	return func(
+		config TimelineConfig,
+	) []StrokeSpec {
+		return []StrokeSpec{
+			{
+				// read-only, consumes all keys
+				Predict: func() bool {
+					return true
+				},
+				Func: func(
+					ev KeyEvent,
+					scope Scope,
+					moveCursor MoveCursor,
+					toggleSelection ToggleSelection,
+					newClip NewClipFromSelection,
+					lineEnd LineEnd,
+				) {
+					view := t.View
+					timeline := view.Timeline
+					if timeline == nil {
+						return
+					}
+					step := time.Second * time.Duration(config.StepSeconds)
+					index := timeline.Index
+
+					switch ev.Name() {
+					case "Left", "Rune[h]":
+						index--
+					case "Right", "Rune[l]":
+						index++
+					case "Rune[H]":
+						index = timeline.indexByTime(-step)
+					case "Rune[L]":
+						index = timeline.indexByTime(step)
+					case "Down", "Rune[j]":
+						moveCursor(Move{RelLine: 1})
+					case "Up", "Rune[k]":
+						moveCursor(Move{RelLine: -1})
+					case "Rune[v]":
+						toggleSelection()
+					case "Rune[y]":
+						// copy selected text or current line out of the moment
+						if view.SelectionAnchor == nil {
+							moveCursor(Move{AbsCol: intP(0)})
+							toggleSelection()
+							lineEnd()
+						}
+						newClip()
+						toggleSelection()
+					case "Enter", "Rune[b]":
+						scope.Call(BranchFromTimeline)
+					case "Esc", "Rune[q]":
+						scope.Call(ExitTimeline)
+					}
+
+					if index < 0 {
+						index = 0
+					} else if index >= len(timeline.Moments) {
+						index = len(timeline.Moments) - 1
+					}
+					if index != timeline.Index {
+						timeline.Index = index
+						timeline.Changed = changedLines(timeline.Origin, timeline.Moment())
+						view.switchMoment(scope, timeline.Moment())
+					}
+				},
+			},
+		}
+	}
+}
+
+func EnterTimeline(
+	cur CurrentView,
+	curModes CurrentModes,
+	screen Screen,
+) {
+	view := cur()
+	if view == nil || view.Timeline != nil {
+		return
+	}
+	view.Timeline = newTimeline(view.GetMoment())
+	view.SelectionAnchor = nil
+	newModes := []Mode{
+		&TimelineMode{
+			View: view,
+		},
+	}
+	for _, mode := range curModes() {
+		if _, ok := mode.(*EditMode); ok {
+			// no editing in timeline
+			continue
+		}
+		newModes = append(newModes, mode)
+	}
+	curModes(newModes)
+	screen.SetCursorShape(CursorBlock)
+}
+
+func (_ Command) EnterTimeline() (spec CommandSpec) {
+	spec.Desc = "scrub through history of current buffer by time"
+	spec.Func = EnterTimeline
+	return
+}
+
+func leaveTimeline(
+	view *View,
+	curModes CurrentModes,
+) {
+	view.Timeline = nil
+	view.SelectionAnchor = nil
+	modes := curModes()
+	filtered := make([]Mode, 0, len(modes))
+	for _, mode := range modes {
+		if _, ok := mode.(*TimelineMode); ok {
+			continue
+		}
+		filtered = append(filtered, mode)
+	}
+	curModes(filtered)
+}
+
+// ExitTimeline restores the moment before entering timeline
+func ExitTimeline(
+	cur CurrentView,
+	curModes CurrentModes,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil || view.Timeline == nil {
+		return
+	}
+	origin := view.Timeline.Origin
+	leaveTimeline(view, curModes)
+	view.switchMoment(scope, origin)
+}
+
+func (_ Command) ExitTimeline() (spec CommandSpec) {
+	spec.Desc = "leave timeline and restore current moment"
+	spec.Func = ExitTimeline
+	return
+}
+
+// BranchFromTimeline keeps the selected moment, later changes branch from it
+func BranchFromTimeline(
+	cur CurrentView,
+	curModes CurrentModes,
+) {
+	view := cur()
+	if view == nil || view.Timeline == nil {
+		return
+	}
+	leaveTimeline(view, curModes)
+}
+
+func (_ Command) BranchFromTimeline() (spec CommandSpec) {
+	spec.Desc = "leave timeline at selected moment to branch from it"
+	spec.Func = BranchFromTimeline
+	return
+}
+
+func (_ Provide) TimelineStatus(
+	on On,
+) OnStartup {
+	return func() {
+		on(func(
+			ev EvCollectStatusSections,
+			cur CurrentView,
+		) {
+			view := cur()
+			if view == nil || view.Timeline == nil {
+				return
+			}
+			timeline := view.Timeline
+			moment := timeline.Moment()
+			ev.Add("timeline", [][]any{
+				{moment.T0.Format("15:04:05"), AlignRight, Padding(0, 2, 0, 0)},
+				{
+					fmt.Sprintf("%d / %d", timeline.Index+1, len(timeline.Moments)),
+					AlignRight, Padding(0, 2, 0, 0),
+				},
+			})
+		})
+	}
+}
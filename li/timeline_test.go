@@ -0,0 +1,89 @@
+package li
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	withEditorBytes(t, []byte("a\n"), func(
+		view *View,
+		scope Scope,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+		emitRune EmitRune,
+		linkedOne LinkedOne,
+	) {
+		root := view.GetMoment()
+		insert("b\n", PositionFunc(posCursor))
+		m1 := view.GetMoment()
+		insert("c\n", PositionFunc(posCursor))
+		m2 := view.GetMoment()
+		insert("d\n", PositionFunc(posCursor))
+		m3 := view.GetMoment()
+		now := time.Now()
+		root.T0 = now.Add(-time.Hour)
+		m1.T0 = now.Add(-time.Minute * 30)
+		m2.T0 = now.Add(-time.Minute * 5)
+		m3.T0 = now
+
+		scope.Call(EnterTimeline)
+		eq(t,
+			view.Timeline != nil, true,
+			len(view.Timeline.Moments), 4,
+		)
+
+		// one moment back
+		emitRune('h')
+		eq(t,
+			view.GetMoment() == m2, true,
+			len(view.Timeline.Changed), 0,
+		)
+
+		// a minute back
+		emitRune('H')
+		eq(t,
+			view.GetMoment() == m1, true,
+		)
+		emitRune('L')
+		eq(t,
+			view.GetMoment() == m2, true,
+		)
+		emitRune('H')
+		emitRune('H')
+		eq(t,
+			view.GetMoment() == root, true,
+		)
+
+		// read-only
+		emitRune('x')
+		eq(t,
+			view.GetMoment() == root, true,
+		)
+
+		// copy out
+		emitRune('y')
+		var clip Clip
+		linkedOne(view.Buffer, &clip)
+		eq(t,
+			clip.Moment == root, true,
+			clip.String(), "a",
+		)
+
+		// restore
+		emitRune('q')
+		eq(t,
+			view.Timeline == nil, true,
+			view.GetMoment() == m3, true,
+		)
+
+		// branch
+		scope.Call(EnterTimeline)
+		emitRune('h')
+		emitRune('b')
+		eq(t,
+			view.Timeline == nil, true,
+			view.GetMoment() == m2, true,
+		)
+	})
+}
@@ -100,7 +100,8 @@ func renderAll(scope Scope, elements ...Element) {
 type UIConfig struct {
 	StatusWidth        int
 	JournalHeight      int
-	MaxOutlineDistance int
+	MaxOutlineDistance int // lines from cursor to the symbol followed by outline
+	OutlineWidth       int
 	ViewList           struct {
 		HideTimeoutSeconds int
 		MarginLeft         int
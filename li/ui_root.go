@@ -7,6 +7,7 @@ func Root(
 	getStyle GetStyle,
 	getJournalHeight JournalHeight,
 	uiConfig UIConfig,
+	outline *Outline,
 ) Element {
 
 	box := Box{0, 0, int(height), int(width)}
@@ -28,6 +29,25 @@ func Root(
 	}
 	viewBox := Box{0, statusWidth, box.Height() - journalHeight, box.Width()}
 
+	var outlineElems []Element
+	if outline.Show {
+		outlineWidth := uiConfig.OutlineWidth
+		if outlineWidth == 0 {
+			outlineWidth = 30
+		}
+		if outlineWidth > viewBox.Width()/2 {
+			outlineWidth = viewBox.Width() / 2
+		}
+		viewBox.Right -= outlineWidth
+		outlineBox := Box{0, viewBox.Right, viewBox.Bottom, box.Width()}
+		outlineElems = append(outlineElems, ElementWith(
+			ElementFrom(OutlineUI),
+			func() Box {
+				return outlineBox
+			},
+		))
+	}
+
 	return ElementFrom(
 
 		// status
@@ -46,6 +66,9 @@ func Root(
 			},
 		),
 
+		// outline
+		outlineElems,
+
 		// overlay
 		ElementWith(
 			ElementFrom(OverlayUI),
@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"sync"
 )
 
 type ViewUIArgs struct {
-	MomentID     MomentID
-	Width        int
-	Height       int
-	IsFocus      bool
-	HintsVersion int
+	MomentID      MomentID
+	Width         int
+	Height        int
+	IsFocus       bool
+	HintsVersion  int
+	FoldVersion   int
+	SoftWrap      bool
+	GitVersion    int
+	DiagVersion   int
+	TokensVersion int
 	ViewMomentState
 }
 
@@ -32,8 +38,12 @@ func (view *View) RenderFunc() any {
 		uiConfig UIConfig,
 		trigger Trigger,
 		getLineHints GetLineHints,
-		calLineHeights CalculateLineHeights,
-		calLineHeight CalculateSumLineHeight,
+		calLineHeights CalculateViewLineHeights,
+		cursorPosition ViewCursorScreenPosition,
+		wrapConfig SoftWrapConfig,
+		gitBuffers GitBuffers,
+		lspClient *LSPClient,
+		updateTokens UpdateSemanticTokens,
 	) Element {
 
 		moment := view.GetMoment()
@@ -50,8 +60,17 @@ func (view *View) RenderFunc() any {
 		if l := displayWidth(fmt.Sprintf("%d", view.ViewportLine+view.Box.Height())); l > lineNumLength {
 			lineNumLength = l
 		}
+
+		// diagnostic signs
+		var diagSigns map[int]LSPSeverity
+		signWidth := 0
+		if diagnostics, ok := lspClient.Diagnostics[view.Buffer.AbsPath]; ok {
+			diagSigns = diagnostics.Signs(moment)
+			signWidth = 1
+		}
+
 		contentBox := view.Box
-		contentBox.Left += lineNumLength + 2
+		contentBox.Left += signWidth + lineNumLength + 2
 		view.Lock()
 		view.ContentBox = contentBox
 		view.Unlock()
@@ -61,22 +80,26 @@ func (view *View) RenderFunc() any {
 		// cursor position
 		defer func() {
 			if view == currentView {
-				y := contentBox.Top
-				moment := view.GetMoment()
-				lineHeight := calLineHeight(moment, [2]int{
-					view.ViewportLine, view.CursorLine,
-				})
-				y += lineHeight
-				screen.ShowCursor(
-					contentBox.Left+(view.CursorCol-view.ViewportCol),
-					y,
-				)
+				screen.ShowCursor(cursorPosition(view, view.GetMoment()))
 			}
 		}()
 
 		// line hints
 		hints, version := getLineHints()
 
+		// git marks
+		var gitMarks map[int]GitMark
+		gitVersion := 0
+		if state, ok := gitBuffers[view.Buffer]; ok {
+			gitVersion = state.Version
+			if state.Moment == moment {
+				gitMarks = state.Marks
+			}
+		}
+
+		// semantic tokens, requested here since stainers run in render goroutines
+		tokensVersion := updateTokens(view.Buffer, moment)
+
 		// frame buffer cache
 		args := ViewUIArgs{
 			MomentID:        moment.ID,
@@ -84,6 +107,11 @@ func (view *View) RenderFunc() any {
 			Height:          view.Box.Height(),
 			IsFocus:         view == currentView,
 			HintsVersion:    version,
+			FoldVersion:     view.FoldVersion,
+			SoftWrap:        view.SoftWrap,
+			GitVersion:      gitVersion,
+			DiagVersion:     lspClient.DiagnosticsVersion,
+			TokensVersion:   tokensVersion,
 			ViewMomentState: view.ViewMomentState,
 		}
 		if view.FrameBuffer != nil && args == view.FrameBufferArgs {
@@ -105,6 +133,7 @@ func (view *View) RenderFunc() any {
 		// line number box
 		lineNumBox := view.Box
 		lineNumBox.Top = contentBox.Top
+		lineNumBox.Left += signWidth
 		lineNumBox.Right = lineNumBox.Left + lineNumLength + 2
 
 		frameBuffer := NewFrameBuffer(box)
@@ -116,7 +145,13 @@ func (view *View) RenderFunc() any {
 
 		// style
 		hlStyle := getStyle("Highlight")
+		changedStyle := getStyle("Changed")
 		lineNumStyle := defaultStyle
+		gitStyles := map[GitMark]Style{
+			GitMarkAdded:    getStyle("GitAdded")(defaultStyle),
+			GitMarkModified: getStyle("GitModified")(defaultStyle),
+			GitMarkDeleted:  getStyle("GitDeleted")(defaultStyle),
+		}
 
 		// indent-based background
 		indentStyle := func(style Style, lineNum int, offset int) Style {
@@ -146,19 +181,28 @@ func (view *View) RenderFunc() any {
 		selectedRange := view.selectedRange()
 		wg := new(sync.WaitGroup)
 		loopLineNum := view.ViewportLine
+		if r, ok := view.hidingFold(scope, moment, loopLineNum); ok {
+			loopLineNum = r.Begin
+		}
 		loopY := contentBox.Top
-		lineHeights := calLineHeights(moment, [2]int{
+		lineHeights := calLineHeights(view, moment, [2]int{
 			view.ViewportLine, view.ViewportLine + view.Box.Height(),
 		})
 		for loopY < contentBox.Bottom {
 			y := loopY
 			lineNum := loopLineNum
 			lineHeight := 1
-			if h, ok := lineHeights[loopLineNum]; ok {
+			h, ok := lineHeights[loopLineNum]
+			if !ok {
+				h, ok = calLineHeights(view, moment, [2]int{
+					loopLineNum, loopLineNum + 1,
+				})[loopLineNum]
+			}
+			if ok && h > 0 {
 				lineHeight = h
 			}
 			loopY += lineHeight
-			loopLineNum += 1
+			loopLineNum = view.nextVisibleLine(scope, moment, loopLineNum)
 			wg.Add(1)
 			procs <- func() {
 				defer wg.Done()
@@ -212,6 +256,37 @@ func (view *View) RenderFunc() any {
 					).RenderFunc())
 				}
 
+				// git mark
+				if mark, ok := gitMarks[lineNum]; ok {
+					r := '│'
+					if mark == GitMarkDeleted {
+						r = '_'
+					}
+					set(
+						lineNumBox.Right-1, y,
+						r, nil,
+						gitStyles[mark],
+					)
+				}
+
+				// diagnostic sign
+				if signWidth > 0 {
+					r := ' '
+					style := lineNumStyle
+					if severity, ok := diagSigns[lineNum]; ok {
+						r = severity.Sign()
+						style = getStyle(severity.StyleName())(lineNumStyle)
+					}
+					for i := 0; i < lineHeight && y+i < contentBox.Bottom; i++ {
+						set(
+							view.Box.Left, y+i,
+							r, nil,
+							style,
+						)
+						r = ' '
+					}
+				}
+
 				baseStyle := defaultStyle
 				if y == contentBox.Bottom-1 {
 					if currentView == view {
@@ -219,25 +294,51 @@ func (view *View) RenderFunc() any {
 					}
 					baseStyle = baseStyle.Underline(true)
 				}
+				if view.Timeline != nil && view.Timeline.Changed[lineNum] {
+					baseStyle = changedStyle(baseStyle)
+				}
 				if isCurrentLine {
 					baseStyle = darkerOrLighterStyle(baseStyle, 20)
 				}
 				blockStyle := baseStyle
 
-				var hintLines []string
+				type hintLine struct {
+					text  string
+					style StyleFunc
+				}
+				var hintLines []hintLine
 				n := sort.Search(len(hints), func(i int) bool {
 					return hints[i].Line >= lineNum
 				})
 				for ; n < len(hints) && hints[n].Line == lineNum; n++ {
-					hintLines = append(hintLines, hints[n].Hints...)
+					style := hintStyle
+					if hints[n].Style != "" {
+						style = getStyle(hints[n].Style)
+					}
+					for _, text := range hints[n].Hints {
+						hintLines = append(hintLines, hintLine{
+							text:  text,
+							style: style,
+						})
+					}
+				}
+				sort.SliceStable(hintLines, func(i, j int) bool {
+					return hintLines[i].text < hintLines[j].text
+				})
+
+				// wrapped rows
+				numRows := 0
+				var rowStarts []int
+				if len(lines) > 0 {
+					rowStarts = view.wrapRows(&lines[0], wrapConfig)
+					numRows = len(rowStarts)
 				}
-				sort.Strings(hintLines)
 
 				for i := 0; i < lineHeight; i++ {
 					var line *Line
-					if i < len(lines) {
+					if i < numRows {
 						// moment line
-						line = &lines[i]
+						line = &lines[0]
 					}
 
 					x := contentBox.Left
@@ -245,12 +346,40 @@ func (view *View) RenderFunc() any {
 						// moment content
 
 						cells := line.Cells
-						skip := view.ViewportCol
+						firstCell := 0
 						leftSkip := false
-						for skip > 0 && len(cells) > 0 {
-							skip -= cells[0].DisplayWidth
-							cells = cells[1:]
-							leftSkip = true
+						if view.SoftWrap {
+							firstCell = rowStarts[i]
+							if i+1 < numRows {
+								cells = cells[:rowStarts[i+1]]
+							}
+							cells = cells[firstCell:]
+							if i > 0 {
+								// continuation indicator
+								for _, r := range wrapConfig.Indicator {
+									set(
+										x, y,
+										r, nil,
+										hintStyle(blockStyle),
+									)
+									x += runeDisplayWidth(r)
+								}
+								for ; x < contentBox.Left+wrapConfig.PrefixWidth(); x++ {
+									set(
+										x, y,
+										' ', nil,
+										blockStyle,
+									)
+								}
+							}
+						} else {
+							skip := view.ViewportCol
+							for skip > 0 && len(cells) > 0 {
+								skip -= cells[0].DisplayWidth
+								cells = cells[1:]
+								firstCell++
+								leftSkip = true
+							}
 						}
 
 						var cellColors []*Color
@@ -268,7 +397,8 @@ func (view *View) RenderFunc() any {
 						}
 
 						// cells
-						for cellNum, cell := range cells {
+						for n, cell := range cells {
+							cellNum := firstCell + n
 
 							// right truncated
 							if x >= contentBox.Right {
@@ -299,7 +429,7 @@ func (view *View) RenderFunc() any {
 								style = darkerOrLighterStyle(style, 20)
 							}
 
-							if leftSkip && x == contentBox.Left {
+							if leftSkip && n == 0 {
 								// left truncated
 								set(
 									x, y,
@@ -342,14 +472,34 @@ func (view *View) RenderFunc() any {
 							x += cell.DisplayWidth
 						}
 
-					} else if i >= len(lines) && i < len(lines)+len(hintLines) {
+						// folded summary
+						if fold, ok := view.foldAt(scope, moment, lineNum); ok && i == numRows-1 {
+							summary := fmt.Sprintf(
+								" ··· %s  [%d lines]",
+								strings.TrimSpace(moment.GetLine(fold.End).content),
+								fold.End-fold.Begin+1,
+							)
+							for _, r := range summary {
+								if x >= contentBox.Right {
+									break
+								}
+								set(
+									x, y,
+									r, nil,
+									hintStyle(blockStyle),
+								)
+								x += runeDisplayWidth(r)
+							}
+						}
+
+					} else if i >= numRows && i < numRows+len(hintLines) {
 						// hint
-						content := hintLines[i-len(lines)]
-						for _, r := range content {
+						hint := hintLines[i-numRows]
+						for _, r := range hint.text {
 							set(
 								x, y,
 								r, nil,
-								hintStyle(blockStyle),
+								hint.style(blockStyle),
 							)
 							x += runeDisplayWidth(r)
 						}
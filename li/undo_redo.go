@@ -2,11 +2,13 @@ package li
 
 import (
 	"sort"
+	"strings"
 	"time"
 )
 
 type UndoConfig struct {
 	DurationMS1 time.Duration
+	Unit        string // session, word or line
 }
 
 func (_ Provide) UndoConfig(
@@ -16,6 +18,7 @@ func (_ Provide) UndoConfig(
 		Undo UndoConfig
 	}
 	config.Undo.DurationMS1 = 3000
+	config.Undo.Unit = "session"
 	ce(getConfig(&config))
 	return config.Undo
 }
@@ -40,31 +43,41 @@ func (_ Command) Undo() (spec CommandSpec) {
 	return
 }
 
-func RedoLatest(
-	cur CurrentView,
+// latestChild returns the newest moment derived from moment
+func latestChild(
 	linkedAll LinkedAll,
-	scope Scope,
-) {
-	view := cur()
-	if view == nil {
-		return
-	}
+	buffer *Buffer,
+	moment *Moment,
+) *Moment {
 	var allMoments []*Moment
-	linkedAll(view.Buffer, &allMoments)
+	linkedAll(buffer, &allMoments)
 	var moments []*Moment
-	currentMoment := view.GetMoment()
-	for _, moment := range allMoments {
-		if moment.Previous == currentMoment {
-			moments = append(moments, moment)
+	for _, m := range allMoments {
+		if m.Previous == moment {
+			moments = append(moments, m)
 		}
 	}
 	if len(moments) == 0 {
-		return
+		return nil
 	}
 	sort.SliceStable(moments, func(i, j int) bool {
 		return moments[i].ID > moments[j].ID
 	})
-	view.switchMoment(scope, moments[0])
+	return moments[0]
+}
+
+func RedoLatest(
+	cur CurrentView,
+	linkedAll LinkedAll,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if next := latestChild(linkedAll, view.Buffer, view.GetMoment()); next != nil {
+		view.switchMoment(scope, next)
+	}
 }
 
 func (_ Command) RedoLatest() (spec CommandSpec) {
@@ -106,3 +119,180 @@ func (_ Command) UndoDuration1() (spec CommandSpec) {
 	spec.Func = UndoDuration1
 	return
 }
+
+func RedoDuration1(
+	cur CurrentView,
+	config UndoConfig,
+	linkedAll LinkedAll,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	var prev *Moment
+	moment := view.GetMoment()
+	t0 := moment.T0
+	next := latestChild(linkedAll, view.Buffer, moment)
+	for {
+		if next == nil {
+			break
+		}
+		prev = next
+		if next.T0.Sub(t0) > config.DurationMS1*time.Millisecond {
+			break
+		}
+		next = latestChild(linkedAll, view.Buffer, next)
+	}
+	if prev != nil {
+		view.switchMoment(scope, prev)
+	}
+}
+
+func (_ Command) RedoDuration1() (spec CommandSpec) {
+	spec.Desc = "redo to next moment at least Undo.DurationMS1 later"
+	spec.Func = RedoDuration1
+	return
+}
+
+type UndoUnit uint8
+
+const (
+	UndoUnitSession UndoUnit = 1 << iota
+	UndoUnitLine
+	UndoUnitWord
+
+	UndoUnitAll = UndoUnitSession | UndoUnitLine | UndoUnitWord
+)
+
+func (c UndoConfig) UndoUnit() UndoUnit {
+	switch strings.ToLower(c.Unit) {
+	case "word":
+		return UndoUnitWord
+	case "line":
+		return UndoUnitLine
+	}
+	return UndoUnitSession
+}
+
+func (m *Moment) isUndoBoundary(unit UndoUnit) bool {
+	return m.undoBoundaries&unit > 0
+}
+
+func (_ Provide) UndoBoundaries(
+	on On,
+) OnStartup {
+	return func() {
+
+		// edit mode sessions
+		on(func(
+			ev EvModesChanged,
+			cur CurrentView,
+		) {
+			view := cur()
+			if view == nil {
+				return
+			}
+			view.GetMoment().undoBoundaries |= UndoUnitAll
+		})
+
+		on(func(
+			ev EvMomentSwitched,
+			curModes CurrentModes,
+		) {
+			if ev.Old == nil || ev.New.Previous != ev.Old {
+				// not a new change
+				return
+			}
+			ev.View.RLock()
+			_, visited := ev.View.MomentStates[ev.New]
+			ev.View.RUnlock()
+			if visited {
+				// redo
+				return
+			}
+			if !IsEditing(curModes()) {
+				// changes out of edit mode are units of their own
+				ev.Old.undoBoundaries |= UndoUnitAll
+				ev.New.undoBoundaries |= UndoUnitAll
+				return
+			}
+
+			change := ev.New.Change
+			prevChange := ev.Old.Change
+			if change.Begin.Line != prevChange.Begin.Line {
+				ev.Old.undoBoundaries |= UndoUnitLine | UndoUnitWord
+			}
+			if change.Op != prevChange.Op {
+				ev.Old.undoBoundaries |= UndoUnitWord
+			}
+			if change.Op == OpInsert && change.String != "" {
+				if strings.Contains(change.String, "\n") {
+					ev.New.undoBoundaries |= UndoUnitLine | UndoUnitWord
+				}
+				runes := []rune(change.String)
+				if runeCategory(runes[len(runes)-1]) != RuneCategoryIdentifier {
+					ev.New.undoBoundaries |= UndoUnitWord
+				}
+			}
+		})
+
+	}
+}
+
+func UndoByUnit(
+	cur CurrentView,
+	config UndoConfig,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	unit := config.UndoUnit()
+	target := view.GetMoment().Previous
+	if target == nil {
+		return
+	}
+	for !target.isUndoBoundary(unit) && target.Previous != nil {
+		target = target.Previous
+	}
+	view.switchMoment(scope, target)
+}
+
+func (_ Command) UndoByUnit() (spec CommandSpec) {
+	spec.Desc = "undo to previous boundary of Undo.Unit: session, word or line"
+	spec.Func = UndoByUnit
+	return
+}
+
+func RedoByUnit(
+	cur CurrentView,
+	config UndoConfig,
+	linkedAll LinkedAll,
+	scope Scope,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	unit := config.UndoUnit()
+	target := latestChild(linkedAll, view.Buffer, view.GetMoment())
+	if target == nil {
+		return
+	}
+	for !target.isUndoBoundary(unit) {
+		next := latestChild(linkedAll, view.Buffer, target)
+		if next == nil {
+			break
+		}
+		target = next
+	}
+	view.switchMoment(scope, target)
+}
+
+func (_ Command) RedoByUnit() (spec CommandSpec) {
+	spec.Desc = "redo to next boundary of Undo.Unit: session, word or line"
+	spec.Func = RedoByUnit
+	return
+}
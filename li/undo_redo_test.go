@@ -119,3 +119,86 @@ func TestTimedUndo2(t *testing.T) {
 		scope.Call(UndoDuration1)
 	})
 }
+
+func TestTimedRedo(t *testing.T) {
+	withHelloEditor(t, func(
+		scope Scope,
+		view *View,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+	) {
+		m := view.GetMoment()
+		insert("foo", PositionFunc(posCursor))
+		insert("bar", PositionFunc(posCursor))
+		latest := view.GetMoment()
+		view.switchMoment(scope, m)
+
+		scope.Call(RedoDuration1)
+		eq(t,
+			view.GetMoment() == latest, true,
+		)
+		scope.Call(RedoDuration1)
+		eq(t,
+			view.GetMoment() == latest, true,
+		)
+	})
+}
+
+func TestUndoByUnit(t *testing.T) {
+	withEditorBytes(t, []byte("\n"), func(
+		scope Scope,
+		view *View,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+		enable EnableEditMode,
+		disable DisableEditMode,
+	) {
+		withUnit := func(unit string) Scope {
+			var config UndoConfig
+			scope.Assign(&config)
+			config.Unit = unit
+			return scope.Fork(func() UndoConfig { return config })
+		}
+		content := func() string {
+			return view.GetMoment().GetContent()
+		}
+
+		enable()
+		for _, s := range []string{"f", "o", "o", " ", "b", "a", "r", "\n", "b", "a", "z"} {
+			insert(s, PositionFunc(posCursor))
+		}
+		disable()
+		eq(t,
+			content(), "foo bar\nbaz\n",
+		)
+
+		withUnit("word").Call(UndoByUnit)
+		eq(t,
+			content(), "foo bar\n\n",
+		)
+		withUnit("word").Call(UndoByUnit)
+		eq(t,
+			content(), "foo \n",
+		)
+		withUnit("word").Call(RedoByUnit)
+		eq(t,
+			content(), "foo bar\n\n",
+		)
+		withUnit("line").Call(UndoByUnit)
+		eq(t,
+			content(), "\n",
+		)
+		withUnit("line").Call(RedoByUnit)
+		eq(t,
+			content(), "foo bar\n\n",
+		)
+		withUnit("session").Call(RedoByUnit)
+		eq(t,
+			content(), "foo bar\nbaz\n",
+		)
+		withUnit("session").Call(UndoByUnit)
+		eq(t,
+			content(), "\n",
+		)
+	})
+}
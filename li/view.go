@@ -26,6 +26,18 @@ type View struct {
 	//TODO eviction
 	MomentStates map[*Moment]ViewMomentState
 
+	// begin lines of folded ranges
+	FoldedLines map[int]bool
+	FoldVersion int
+
+	SoftWrap bool
+
+	// active snippet expansion
+	Snippet *SnippetSession
+
+	// not nil if scrubbing through history
+	Timeline *Timeline
+
 	//TODO merge moment segments
 }
 
@@ -64,6 +76,7 @@ func (_ Provide) NewViewFromBuffer(
 	linkedOne LinkedOne,
 	trigger Trigger,
 	languageStainers LanguageStainers,
+	softWrapConfig SoftWrapConfig,
 ) NewViewFromBuffer {
 	return func(buffer *Buffer) (view *View, err error) {
 
@@ -75,12 +88,10 @@ func (_ Provide) NewViewFromBuffer(
 			ID:     id,
 			Buffer: buffer,
 			moment: moment,
-			Stainer: func() Stainer {
-				if fn, ok := languageStainers[buffer.language]; ok {
-					return fn()
-				}
-				return new(NoopStainer)
-			}(),
+			Stainer: &SemanticTokensStainer{
+				Buffer:  buffer,
+				Lexical: lexicalStainer(languageStainers, buffer.language),
+			},
 			ViewMomentState: ViewMomentState{
 				ViewportLine: 0,
 				ViewportCol:  0,
@@ -88,6 +99,8 @@ func (_ Provide) NewViewFromBuffer(
 				CursorCol:    0,
 			},
 			MomentStates: make(map[*Moment]ViewMomentState),
+			FoldedLines:  make(map[int]bool),
+			SoftWrap:     softWrapConfig.Enable,
 			Box: Box{
 				Top:    0,
 				Left:   0,
@@ -114,11 +127,16 @@ func (_ Provide) NewViewFromBuffer(
 
 type CloseView func()
 
+type EvViewClosed struct {
+	View *View
+}
+
 func (_ Provide) CloseView(
 	cur CurrentView,
 	views Views,
 	derive Derive,
 	dropLinked DropLinked,
+	trigger Trigger,
 ) CloseView {
 	return func() {
 		c := cur()
@@ -133,6 +151,9 @@ func (_ Provide) CloseView(
 				return views
 			},
 		)
+		trigger(EvViewClosed{
+			View: c,
+		})
 	}
 }
 
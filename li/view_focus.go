@@ -1,6 +1,9 @@
 package li
 
-import "path/filepath"
+import (
+	"fmt"
+	"path/filepath"
+)
 
 type CurrentMoment func() *Moment
 
@@ -172,3 +175,58 @@ func (_ Provide) CurrentViewFilePathStatus(
 		})
 	}
 }
+
+type ShowPathPosition func(
+	path string,
+	pos Position,
+) (
+	view *View,
+	err error,
+)
+
+func (_ Provide) ShowPathPosition(
+	views Views,
+	cur CurrentView,
+	newBuffers NewBuffersFromPath,
+	newView NewViewFromBuffer,
+	moveCursor MoveCursor,
+) ShowPathPosition {
+	return func(path string, pos Position) (view *View, err error) {
+		defer he(&err)
+		if c := cur(); c != nil && c.Buffer.AbsPath == path {
+			view = c
+		}
+		for _, v := range views {
+			if view == nil && v.Buffer.AbsPath == path {
+				view = v
+			}
+		}
+		if view == nil {
+			buffers, err := newBuffers(path)
+			ce(err)
+			if len(buffers) == 0 {
+				return nil, fmt.Errorf("cannot open %s", path)
+			}
+			view, err = newView(buffers[0])
+			ce(err)
+		}
+		if cur() != view {
+			cur(view)
+		}
+
+		moment := view.GetMoment()
+		if pos.Line >= moment.NumLines() {
+			pos.Line = moment.NumLines() - 1
+		}
+		col := 0
+		if line := moment.GetLine(pos.Line); line != nil && len(line.Cells) > 0 {
+			cell := pos.Cell
+			if cell >= len(line.Cells) {
+				cell = len(line.Cells) - 1
+			}
+			col = line.Cells[cell].DisplayOffset
+		}
+		moveCursor(Move{AbsLine: &pos.Line, AbsCol: &col})
+		return
+	}
+}
@@ -0,0 +1,615 @@
+package li
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type DiffSide struct {
+	Name   string
+	Buffer *Buffer // nil if read-only
+	Moment *Moment
+}
+
+type diffRow struct {
+	Changed   bool
+	Left      int // line number, -1 if none
+	Right     int
+	LeftText  string
+	RightText string
+}
+
+type diffHunk struct {
+	Row        int
+	NumRows    int
+	LeftBegin  int // lines, end exclusive
+	LeftEnd    int
+	RightBegin int
+	RightEnd   int
+}
+
+// diffRows aligns lines of two moments, changed lines are paired in hunks
+func diffRows(left *Moment, right *Moment) (rows []diffRow, hunks []diffHunk) {
+	var dels, ins []LineDiff
+	nextLeft, nextRight := 0, 0
+
+	flush := func() {
+		if len(dels) == 0 && len(ins) == 0 {
+			return
+		}
+		hunk := diffHunk{
+			Row:        len(rows),
+			LeftBegin:  nextLeft,
+			LeftEnd:    nextLeft + len(dels),
+			RightBegin: nextRight,
+			RightEnd:   nextRight + len(ins),
+		}
+		for i := 0; i < len(dels) || i < len(ins); i++ {
+			row := diffRow{
+				Changed: true,
+				Left:    -1,
+				Right:   -1,
+			}
+			if i < len(dels) {
+				row.Left = dels[i].A
+				row.LeftText = dels[i].Text
+			}
+			if i < len(ins) {
+				row.Right = ins[i].B
+				row.RightText = ins[i].Text
+			}
+			rows = append(rows, row)
+		}
+		hunk.NumRows = len(rows) - hunk.Row
+		hunks = append(hunks, hunk)
+		nextLeft = hunk.LeftEnd
+		nextRight = hunk.RightEnd
+		dels = dels[:0]
+		ins = ins[:0]
+	}
+
+	for _, diff := range diffMomentLines(left, right) {
+		switch diff.Op {
+		case LineDelete:
+			dels = append(dels, diff)
+		case LineInsert:
+			ins = append(ins, diff)
+		case LineEqual:
+			flush()
+			rows = append(rows, diffRow{
+				Left:      diff.A,
+				Right:     diff.B,
+				LeftText:  diff.Text,
+				RightText: diff.Text,
+			})
+			nextLeft = diff.A + 1
+			nextRight = diff.B + 1
+		}
+	}
+	flush()
+
+	return
+}
+
+type DiffSession struct {
+	Left   DiffSide
+	Right  DiffSide
+	Rows   []diffRow
+	Hunks  []diffHunk
+	Inline bool
+	Offset int // first row in viewport
+	Hunk   int // current hunk
+}
+
+func (d *DiffSession) update() {
+	d.Rows, d.Hunks = diffRows(d.Left.Moment, d.Right.Moment)
+	if d.Hunk >= len(d.Hunks) {
+		d.Hunk = len(d.Hunks) - 1
+	}
+	if d.Hunk < 0 {
+		d.Hunk = 0
+	}
+	d.scroll(0)
+}
+
+func (d *DiffSession) scroll(n int) {
+	d.Offset += n
+	if d.Offset >= len(d.Rows) {
+		d.Offset = len(d.Rows) - 1
+	}
+	if d.Offset < 0 {
+		d.Offset = 0
+	}
+}
+
+// rows shown above hunk when jumping to it
+const diffContextRows = 3
+
+func (d *DiffSession) jumpHunk(n int) {
+	if len(d.Hunks) == 0 {
+		return
+	}
+	d.Hunk += n
+	if d.Hunk < 0 {
+		d.Hunk = 0
+	} else if d.Hunk >= len(d.Hunks) {
+		d.Hunk = len(d.Hunks) - 1
+	}
+	d.Offset = d.Hunks[d.Hunk].Row - diffContextRows
+	d.scroll(0)
+}
+
+// copyHunk copies lines of current hunk from one side to the other, returns false if target is read-only
+func (d *DiffSession) copyHunk(
+	scope Scope,
+	toRight bool,
+) bool {
+	if len(d.Hunks) == 0 {
+		return true
+	}
+	hunk := d.Hunks[d.Hunk]
+	from, to := &d.Left, &d.Right
+	fromBegin, fromEnd := hunk.LeftBegin, hunk.LeftEnd
+	toBegin, toEnd := hunk.RightBegin, hunk.RightEnd
+	if !toRight {
+		from, to = to, from
+		fromBegin, fromEnd, toBegin, toEnd = toBegin, toEnd, fromBegin, fromEnd
+	}
+	if to.Buffer == nil {
+		return false
+	}
+
+	scope.Call(func(
+		apply ApplyChange,
+		views Views,
+	) {
+		lines := linesText(from.Moment, fromBegin, fromEnd-1)
+		newMoment := replaceMomentLines(apply, to.Moment, toBegin, toEnd, lines)
+		// views showing the target moment follow the change
+		for _, view := range views {
+			if view.Buffer == to.Buffer && view.GetMoment() == to.Moment {
+				view.switchMoment(scope, newMoment)
+			}
+		}
+		to.Moment = newMoment
+	})
+	d.update()
+	return true
+}
+
+type ShowDiff func(
+	left DiffSide,
+	right DiffSide,
+)
+
+func (_ Provide) ShowDiff(
+	pushOverlay PushOverlay,
+) ShowDiff {
+	return func(
+		left DiffSide,
+		right DiffSide,
+	) {
+		session := &DiffSession{
+			Left:  left,
+			Right: right,
+		}
+		session.update()
+		session.jumpHunk(0)
+
+		var id ID
+		var pageRows int
+		dialog := WidgetDialog{
+
+			OnKey: func(
+				ev KeyEvent,
+				scope Scope,
+				closeOverlay CloseOverlay,
+				show ShowMessage,
+			) {
+				switch ev.Name() {
+				case "Down", "Rune[j]":
+					session.scroll(1)
+				case "Up", "Rune[k]":
+					session.scroll(-1)
+				case "PgDn", "Ctrl+F":
+					session.scroll(pageRows)
+				case "PgUp", "Ctrl+B":
+					session.scroll(-pageRows)
+				case "Rune[g]":
+					session.Offset = 0
+				case "Rune[G]":
+					session.scroll(len(session.Rows))
+				case "Rune[n]", "Rune[]]":
+					session.jumpHunk(1)
+				case "Rune[p]", "Rune[[]":
+					session.jumpHunk(-1)
+				case "Tab", "Rune[i]":
+					session.Inline = !session.Inline
+				case "Rune[>]":
+					if !session.copyHunk(scope, true) {
+						show([]string{session.Right.Name + " is read-only"})
+					}
+				case "Rune[<]":
+					if !session.copyHunk(scope, false) {
+						show([]string{session.Left.Name + " is read-only"})
+					}
+				case "Esc", "Rune[q]":
+					closeOverlay(id)
+				}
+			},
+
+			Element: ElementFrom(func(
+				box Box,
+				defaultStyle Style,
+				getStyle GetStyle,
+			) Element {
+
+				style := darkerOrLighterStyle(defaultStyle, -10)
+				hlStyle := getStyle("Highlight")(style)
+				deleteStyle := getStyle("DiffDelete")(style)
+				insertStyle := getStyle("DiffInsert")(style)
+				wordDeleteStyle := getStyle("DiffDeleteWord")(deleteStyle)
+				wordInsertStyle := getStyle("DiffInsertWord")(insertStyle)
+
+				dialogBox := Box{
+					Top:    box.Top + 1,
+					Left:   box.Left + 2,
+					Bottom: box.Bottom - 1,
+					Right:  box.Right - 2,
+				}
+				contentBox := Box{
+					Top:    dialogBox.Top + 2,
+					Left:   dialogBox.Left + 1,
+					Bottom: dialogBox.Bottom,
+					Right:  dialogBox.Right - 1,
+				}
+				pageRows = contentBox.Height() / 2
+
+				numWidth := len(fmt.Sprintf("%d", len(session.Rows)))
+				numText := func(n int) string {
+					if n < 0 {
+						return strings.Repeat(" ", numWidth)
+					}
+					return fmt.Sprintf("%*d", numWidth, n+1)
+				}
+
+				currentHunk := -1
+				if len(session.Hunks) > 0 {
+					currentHunk = session.Hunk
+				}
+				hunkAt := make(map[int]int)
+				for i, hunk := range session.Hunks {
+					for row := hunk.Row; row < hunk.Row+hunk.NumRows; row++ {
+						hunkAt[row] = i
+					}
+				}
+
+				// line with number and word highlights
+				line := func(
+					top int,
+					left int,
+					right int,
+					mark string,
+					num int,
+					text string,
+					lineStyle Style,
+					mask []bool,
+					wordStyle Style,
+				) Element {
+					prefix := mark + numText(num) + " "
+					prefixLen := len([]rune(prefix))
+					return Text(
+						Box{
+							Top:    top,
+							Left:   left,
+							Bottom: top + 1,
+							Right:  right,
+						},
+						prefix+text,
+						lineStyle,
+						Fill(true),
+						OffsetStyleFunc(func(i int) StyleFunc {
+							i -= prefixLen
+							if i >= 0 && i < len(mask) && mask[i] {
+								return func(Style) Style {
+									return wordStyle
+								}
+							}
+							return SameStyle
+						}),
+					)
+				}
+
+				var elements []Element
+				y := contentBox.Top
+				half := contentBox.Width() / 2
+				for i := session.Offset; i < len(session.Rows) && y < contentBox.Bottom; i++ {
+					row := session.Rows[i]
+					leftText := expandTabs(row.LeftText)
+					rightText := expandTabs(row.RightText)
+					var leftMask, rightMask []bool
+					if row.Left >= 0 && row.Right >= 0 && row.Changed {
+						leftMask, rightMask = intraLineDiff(leftText, rightText)
+					}
+					mark := " "
+					if n, ok := hunkAt[i]; ok && n == currentHunk {
+						mark = ">"
+					}
+
+					leftStyle, rightStyle := style, style
+					if row.Changed {
+						leftStyle, rightStyle = deleteStyle, insertStyle
+					}
+
+					if session.Inline {
+						if !row.Changed {
+							elements = append(elements, line(
+								y, contentBox.Left, contentBox.Right,
+								mark, row.Right, "  "+rightText, style, nil, style,
+							))
+							y++
+							continue
+						}
+						if row.Left >= 0 {
+							elements = append(elements, line(
+								y, contentBox.Left, contentBox.Right,
+								mark, row.Left, "- "+leftText, leftStyle,
+								append([]bool{false, false}, leftMask...), wordDeleteStyle,
+							))
+							y++
+						}
+						if row.Right >= 0 && y < contentBox.Bottom {
+							elements = append(elements, line(
+								y, contentBox.Left, contentBox.Right,
+								mark, row.Right, "+ "+rightText, rightStyle,
+								append([]bool{false, false}, rightMask...), wordInsertStyle,
+							))
+							y++
+						}
+						continue
+					}
+
+					// side by side
+					if row.Left < 0 {
+						leftStyle = style
+					}
+					if row.Right < 0 {
+						rightStyle = style
+					}
+					elements = append(elements,
+						line(
+							y, contentBox.Left, contentBox.Left+half,
+							mark, row.Left, leftText, leftStyle,
+							leftMask, wordDeleteStyle,
+						),
+						line(
+							y, contentBox.Left+half, contentBox.Right,
+							"|", row.Right, rightText, rightStyle,
+							rightMask, wordInsertStyle,
+						),
+					)
+					y++
+				}
+
+				// title
+				title := fmt.Sprintf(
+					"[no changes]  %s  <->  %s",
+					session.Left.Name,
+					session.Right.Name,
+				)
+				if len(session.Hunks) > 0 {
+					title = fmt.Sprintf(
+						"[hunk %d / %d]  %s  <->  %s",
+						session.Hunk+1,
+						len(session.Hunks),
+						session.Left.Name,
+						session.Right.Name,
+					)
+				}
+
+				return Rect(
+					dialogBox,
+					style,
+					Fill(true),
+					Text(
+						Box{
+							Top:    dialogBox.Top,
+							Left:   contentBox.Left,
+							Bottom: dialogBox.Top + 1,
+							Right:  contentBox.Right,
+						},
+						title,
+						hlStyle.Bold(true),
+					),
+					elements,
+				)
+			}),
+		}
+
+		id = pushOverlay(OverlayObject(dialog))
+	}
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
+}
+
+func momentName(buffer *Buffer, moment *Moment) string {
+	name := buffer.Path
+	if name == "" {
+		name = fmt.Sprintf("buffer %d", buffer.ID)
+	}
+	return fmt.Sprintf("%s@%d", name, moment.ID)
+}
+
+func DiffWithPreviousMoment(
+	cur CurrentView,
+	show ShowDiff,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	moment := view.GetMoment()
+	if moment.Previous == nil {
+		return
+	}
+	show(
+		DiffSide{
+			Name:   momentName(view.Buffer, moment.Previous),
+			Buffer: view.Buffer,
+			Moment: moment.Previous,
+		},
+		DiffSide{
+			Name:   momentName(view.Buffer, moment),
+			Buffer: view.Buffer,
+			Moment: moment,
+		},
+	)
+}
+
+func (_ Command) DiffWithPreviousMoment() (spec CommandSpec) {
+	spec.Desc = "show diff between previous and current moment"
+	spec.Func = DiffWithPreviousMoment
+	return
+}
+
+func DiffWithDisk(
+	cur CurrentView,
+	show ShowDiff,
+	newMoment NewMomentFromFile,
+	showMessage ShowMessage,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	if view.Buffer.Path == "" {
+		showMessage([]string{"buffer is not associated with a file"})
+		return
+	}
+	diskMoment, _, err := newMoment(view.Buffer.Path)
+	if err != nil {
+		showMessage(strings.Split(err.Error(), "\n"))
+		return
+	}
+	moment := view.GetMoment()
+	show(
+		DiffSide{
+			Name:   "disk:" + view.Buffer.Path,
+			Moment: diskMoment,
+		},
+		DiffSide{
+			Name:   momentName(view.Buffer, moment),
+			Buffer: view.Buffer,
+			Moment: moment,
+		},
+	)
+}
+
+func (_ Command) DiffWithDisk() (spec CommandSpec) {
+	spec.Desc = "show diff between disk file and current moment"
+	spec.Func = DiffWithDisk
+	return
+}
+
+func DiffWithBuffer(
+	cur CurrentView,
+	views Views,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowDiff,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	var others []*View
+	seen := make(map[*Buffer]bool)
+	seen[view.Buffer] = true
+	for _, v := range views {
+		if seen[v.Buffer] {
+			continue
+		}
+		seen[v.Buffer] = true
+		others = append(others, v)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].ID < others[j].ID
+	})
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: "Diff With",
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(others) {
+				return
+			}
+			other := others[i]
+			moment := view.GetMoment()
+			otherMoment := other.GetMoment()
+			show(
+				DiffSide{
+					Name:   momentName(view.Buffer, moment),
+					Buffer: view.Buffer,
+					Moment: moment,
+				},
+				DiffSide{
+					Name:   momentName(other.Buffer, otherMoment),
+					Buffer: other.Buffer,
+					Moment: otherMoment,
+				},
+			)
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			for i, v := range others {
+				name := momentName(v.Buffer, v.GetMoment())
+				if !strings.Contains(name, string(runes)) {
+					continue
+				}
+				if w := displayWidth(name); w > maxLen {
+					maxLen = w
+				}
+				ids = append(ids, ID(i))
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			var getStyle GetStyle
+			scope.Assign(&box, &focus, &style, &getStyle)
+			s := style
+			if i == focus {
+				hlStyle := getStyle("Highlight")(s)
+				fg, _, _ := hlStyle.Decompose()
+				s = s.Foreground(fg)
+			}
+			v := others[i]
+			return Text(
+				box,
+				momentName(v.Buffer, v.GetMoment()),
+				s,
+			)
+		},
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) DiffWithBuffer() (spec CommandSpec) {
+	spec.Desc = "show diff between current buffer and another open buffer"
+	spec.Func = DiffWithBuffer
+	return
+}
@@ -0,0 +1,86 @@
+package li
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\nd\n"), func(
+		moment *Moment,
+		apply ApplyChange,
+	) {
+		m := replaceMomentLines(apply, moment, 1, 2, []string{"B", "B2"})
+		m = replaceMomentLines(apply, m, 4, 5, nil)
+		rows, hunks := diffRows(moment, m)
+		eq(t,
+			len(rows), 5,
+			len(hunks), 2,
+
+			hunks[0].Row, 1,
+			hunks[0].NumRows, 2,
+			hunks[0].LeftBegin, 1,
+			hunks[0].LeftEnd, 2,
+			hunks[0].RightBegin, 1,
+			hunks[0].RightEnd, 3,
+			rows[1].LeftText, "b",
+			rows[1].RightText, "B",
+			rows[2].Left, -1,
+			rows[2].RightText, "B2",
+
+			hunks[1].Row, 4,
+			hunks[1].LeftBegin, 3,
+			hunks[1].LeftEnd, 4,
+			hunks[1].RightBegin, 4,
+			hunks[1].RightEnd, 4,
+			rows[4].Right, -1,
+		)
+	})
+}
+
+func TestDiffWithDisk(t *testing.T) {
+	withEditorBytes(t, []byte("a\nb\nc\nd\n"), func(
+		view *View,
+		scope Scope,
+		emitRune EmitRune,
+		ctrl func(string),
+		getScreenString GetScreenString,
+		width Width,
+		height Height,
+	) {
+		f, err := ioutil.TempFile("", "*")
+		ce(err)
+		defer os.Remove(f.Name())
+		_, err = f.Write([]byte("a\nx\nc\n"))
+		ce(err)
+		ce(f.Close())
+		view.Buffer.Path = f.Name()
+
+		scope.Call(DiffWithDisk)
+		ctrl("loop")
+		lines := getScreenString(Box{0, 0, int(height), int(width)})
+		found := false
+		for _, line := range lines {
+			if strings.Contains(line, "[hunk 1 / 2]") {
+				found = true
+			}
+		}
+		eq(t,
+			found, true,
+		)
+
+		// copy first hunk from disk to buffer
+		emitRune('>')
+		eq(t,
+			view.GetMoment().GetContent(), "a\nx\nc\nd\n",
+		)
+
+		// remaining hunk
+		emitRune('>')
+		eq(t,
+			view.GetMoment().GetContent(), "a\nx\nc\n",
+		)
+	})
+}
@@ -0,0 +1,48 @@
+package li
+
+type ShowPrompt func(
+	title string,
+	initial string,
+	cb func(scope Scope, input string),
+)
+
+func (_ Provide) ShowPrompt(
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+) ShowPrompt {
+	return func(
+		title string,
+		initial string,
+		cb func(scope Scope, input string),
+	) {
+
+		input := initial
+
+		var id ID
+		dialog := &SelectionDialog{
+
+			Title: title,
+
+			OnClose: func(_ Scope) {
+				closeOverlay(id)
+			},
+
+			OnSelect: func(scope Scope, _ ID) {
+				closeOverlay(id)
+				cb(scope, input)
+			},
+
+			OnUpdate: func(scope Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+				input = string(runes)
+				// single candidate for Enter to confirm
+				ids = []ID{0}
+				return
+			},
+
+			runes: []rune(initial),
+		}
+
+		overlay := OverlayObject(dialog)
+		id = pushOverlay(overlay)
+	}
+}
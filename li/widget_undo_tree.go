@@ -0,0 +1,272 @@
+package li
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type undoTreeRow struct {
+	Moment *Moment
+	Depth  int
+}
+
+// undoTreeRows lays out moments in depth first order, later branches are indented
+func undoTreeRows(moments []*Moment) (rows []undoTreeRow) {
+	set := make(map[*Moment]bool)
+	for _, moment := range moments {
+		set[moment] = true
+	}
+	children := make(map[*Moment][]*Moment)
+	var roots []*Moment
+	for _, moment := range moments {
+		if moment.Previous == nil || !set[moment.Previous] {
+			roots = append(roots, moment)
+			continue
+		}
+		children[moment.Previous] = append(children[moment.Previous], moment)
+	}
+	byID := func(ms []*Moment) {
+		sort.SliceStable(ms, func(i, j int) bool {
+			return ms[i].ID < ms[j].ID
+		})
+	}
+	byID(roots)
+
+	type Item struct {
+		Moment *Moment
+		Depth  int
+	}
+	var stack []Item
+	for i := len(roots) - 1; i >= 0; i-- {
+		stack = append(stack, Item{roots[i], 0})
+	}
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		rows = append(rows, undoTreeRow{
+			Moment: item.Moment,
+			Depth:  item.Depth,
+		})
+		subs := children[item.Moment]
+		byID(subs)
+		// first child continues the branch
+		for i := len(subs) - 1; i >= 0; i-- {
+			depth := item.Depth
+			if i > 0 {
+				depth += i
+			}
+			stack = append(stack, Item{subs[i], depth})
+		}
+	}
+	return
+}
+
+// momentDiffLines returns changed lines from one moment to another
+func momentDiffLines(from *Moment, to *Moment) (lines []string) {
+	for _, diff := range diffMomentLines(from, to) {
+		switch diff.Op {
+		case LineInsert:
+			lines = append(lines, "+ "+diff.Text)
+		case LineDelete:
+			lines = append(lines, "- "+diff.Text)
+		}
+	}
+	return
+}
+
+func ShowUndoTree(
+	cur CurrentView,
+	linkedAll LinkedAll,
+	pushOverlay PushOverlay,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+	var moments []*Moment
+	linkedAll(view.Buffer, &moments)
+	rows := undoTreeRows(moments)
+	if len(rows) == 0 {
+		return
+	}
+	current := view.GetMoment()
+	index := 0
+	for i, row := range rows {
+		if row.Moment == current {
+			index = i
+			break
+		}
+	}
+
+	// diff base, current moment if not marked
+	base := current
+
+	// diff preview of selected row
+	diffIndex := -1
+	var diffLines []string
+
+	var id ID
+	dialog := WidgetDialog{
+
+		OnKey: func(
+			ev KeyEvent,
+			scope Scope,
+			closeOverlay CloseOverlay,
+			showDiff ShowDiff,
+		) {
+			switch ev.Name() {
+			case "Up", "Rune[k]":
+				if index > 0 {
+					index--
+				}
+			case "Down", "Rune[j]":
+				if index < len(rows)-1 {
+					index++
+				}
+			case "Enter":
+				closeOverlay(id)
+				view.switchMoment(scope, rows[index].Moment)
+			case "Rune[m]":
+				// mark selected moment as diff base, unmark if marked
+				if rows[index].Moment == base {
+					base = current
+				} else {
+					base = rows[index].Moment
+				}
+				diffIndex = -1
+			case "Rune[d]":
+				// full diff between selected and base moment, older on the left
+				closeOverlay(id)
+				from, to := rows[index].Moment, base
+				if from.ID > to.ID {
+					from, to = to, from
+				}
+				showDiff(
+					DiffSide{
+						Name:   momentName(view.Buffer, from),
+						Buffer: view.Buffer,
+						Moment: from,
+					},
+					DiffSide{
+						Name:   momentName(view.Buffer, to),
+						Buffer: view.Buffer,
+						Moment: to,
+					},
+				)
+			case "Esc", "Rune[q]":
+				closeOverlay(id)
+			}
+		},
+
+		Element: ElementFrom(func(
+			box Box,
+			defaultStyle Style,
+			getStyle GetStyle,
+		) Element {
+
+			style := darkerOrLighterStyle(defaultStyle, -10)
+			hlStyle := getStyle("Highlight")(style)
+			fg, _, _ := hlStyle.Decompose()
+			selectedStyle := style.Foreground(fg).Bold(true)
+
+			dialogBox := Box{
+				Top:    box.Top + 1,
+				Left:   box.Left + 2,
+				Bottom: box.Bottom - 1,
+				Right:  box.Right - 2,
+			}
+			contentBox := Box{
+				Top:    dialogBox.Top + 2,
+				Left:   dialogBox.Left + 2,
+				Bottom: dialogBox.Bottom - 1,
+				Right:  dialogBox.Right - 2,
+			}
+			treeWidth := contentBox.Width() / 3
+
+			// tree
+			height := contentBox.Height()
+			begin := 0
+			if index >= height {
+				begin = index - height + 1
+			}
+			var treeElements []Element
+			for i := begin; i < len(rows) && i-begin < height; i++ {
+				row := rows[i]
+				mark := "o"
+				if row.Moment == current {
+					mark = "@"
+				}
+				text := fmt.Sprintf(
+					"%s%s %d %s",
+					strings.Repeat("| ", row.Depth),
+					mark,
+					row.Moment.ID,
+					row.Moment.T0.Format("15:04:05"),
+				)
+				if row.Moment == base && base != current {
+					text += " *"
+				}
+				s := style
+				if i == index {
+					s = selectedStyle
+				}
+				treeElements = append(treeElements, Text(
+					Box{
+						Top:    contentBox.Top + i - begin,
+						Left:   contentBox.Left,
+						Bottom: contentBox.Top + i - begin + 1,
+						Right:  contentBox.Left + treeWidth,
+					},
+					text,
+					s,
+				))
+			}
+
+			// diff preview
+			if index != diffIndex {
+				diffIndex = index
+				diffLines = momentDiffLines(base, rows[index].Moment)
+				if len(diffLines) == 0 {
+					diffLines = []string{"(no changes)"}
+				}
+			}
+
+			return Rect(
+				dialogBox,
+				style,
+				Fill(true),
+				Text(
+					Box{
+						Top:    dialogBox.Top + 1,
+						Left:   contentBox.Left,
+						Bottom: dialogBox.Top + 2,
+						Right:  contentBox.Right,
+					},
+					"Undo Tree",
+					AlignCenter,
+					style.Bold(true),
+				),
+				treeElements,
+				Text(
+					Box{
+						Top:    contentBox.Top,
+						Left:   contentBox.Left + treeWidth + 2,
+						Bottom: contentBox.Bottom,
+						Right:  contentBox.Right,
+					},
+					diffLines,
+					style,
+				),
+			)
+		}),
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) ShowUndoTree() (spec CommandSpec) {
+	spec.Desc = "browse undo tree of current buffer, switch to or diff any moments"
+	spec.Func = ShowUndoTree
+	return
+}
@@ -0,0 +1,106 @@
+package li
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestUndoTree(t *testing.T) {
+	withEditorBytes(t, []byte("a\n"), func(
+		view *View,
+		scope Scope,
+		insert InsertAtPositionFunc,
+		posCursor PosCursor,
+		linkedAll LinkedAll,
+		emitRune EmitRune,
+		emitKey EmitKey,
+		getScreenString GetScreenString,
+		width Width,
+		height Height,
+		ctrl func(string),
+		newMoment NewMomentFromBytes,
+	) {
+		root := view.GetMoment()
+		insert("b", PositionFunc(posCursor))
+		first := view.GetMoment()
+		scope.Call(Undo)
+		insert("c", PositionFunc(posCursor))
+		second := view.GetMoment()
+
+		var moments []*Moment
+		linkedAll(view.Buffer, &moments)
+		rows := undoTreeRows(moments)
+		eq(t,
+			len(rows), 3,
+			rows[0].Moment == root, true,
+			rows[0].Depth, 0,
+			rows[1].Moment == first, true,
+			rows[1].Depth, 0,
+			rows[2].Moment == second, true,
+			rows[2].Depth, 1,
+		)
+
+		eq(t,
+			strings.Join(momentDiffLines(second, first), "|"), "- ca|+ ba",
+		)
+		var numbers []string
+		for i := 0; i < 12; i++ {
+			numbers = append(numbers, strconv.Itoa(i))
+		}
+		from, _, err := newMoment([]byte(strings.Join(numbers, "\n") + "\n"))
+		ce(err)
+		numbers[11] = "x"
+		to, _, err := newMoment([]byte(strings.Join(numbers, "\n") + "\n"))
+		ce(err)
+		eq(t,
+			strings.Join(momentDiffLines(from, to), "|"), "- 11|+ x",
+		)
+
+		// switch to the older branch
+		scope.Call(ShowUndoTree)
+		ctrl("loop")
+		emitRune('k')
+		lines := getScreenString(Box{0, 0, int(height), int(width)})
+		found := false
+		for _, line := range lines {
+			if strings.Contains(line, "+ ba") {
+				found = true
+			}
+		}
+		eq(t,
+			found, true,
+		)
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			view.GetMoment() == first, true,
+		)
+
+		// diff two moments other than current
+		contains := func(str string) bool {
+			for _, line := range getScreenString(Box{0, 0, int(height), int(width)}) {
+				if strings.Contains(line, str) {
+					return true
+				}
+			}
+			return false
+		}
+		scope.Call(ShowUndoTree)
+		ctrl("loop")
+		emitRune('k')
+		emitRune('m')
+		emitRune('j')
+		emitRune('j')
+		eq(t,
+			contains("- a"), true,
+			contains("- ba"), false,
+		)
+		emitRune('d')
+		eq(t,
+			contains(momentName(view.Buffer, root)), true,
+			contains(momentName(view.Buffer, second)), true,
+		)
+	})
+}
@@ -0,0 +1,295 @@
+package li
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+const maxWorkspaceSymbols = 1000
+
+type WorkspaceSymbol struct {
+	Name      string
+	Kind      string
+	Container string
+	Path      string
+	Position  Position     // of indexed symbol
+	Location  *LSPLocation // of language server symbol
+}
+
+func (s WorkspaceSymbol) text(root string) string {
+	path := s.Path
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	name := s.Name
+	if s.Container != "" {
+		name = s.Container + "." + s.Name
+	}
+	return fmt.Sprintf("%s  %s  %s:%d", name, s.Kind, path, s.Position.Line+1)
+}
+
+// parseLSPWorkspaceSymbols parses result of []SymbolInformation or []WorkspaceSymbol
+func parseLSPWorkspaceSymbols(result json.RawMessage) (symbols []WorkspaceSymbol, err error) {
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+	var items []struct {
+		Name          string `json:"name"`
+		Kind          int    `json:"kind"`
+		ContainerName string `json:"containerName"`
+		Location      struct {
+			URI   string    `json:"uri"`
+			Range *LSPRange `json:"range"` // optional for WorkspaceSymbol
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(result, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		kind := ""
+		if item.Kind > 0 && item.Kind < len(lspSymbolKinds) {
+			kind = lspSymbolKinds[item.Kind]
+		}
+		loc := &LSPLocation{
+			URI: item.Location.URI,
+		}
+		if item.Location.Range != nil {
+			loc.Range = *item.Location.Range
+		}
+		symbols = append(symbols, WorkspaceSymbol{
+			Name:      item.Name,
+			Kind:      kind,
+			Container: item.ContainerName,
+			Path:      uriToPath(loc.URI),
+			Position: Position{
+				Line: loc.Range.Start.Line,
+			},
+			Location: loc,
+		})
+	}
+	return
+}
+
+// indexedWorkspaceSymbols returns symbols of open views and indexed files
+func indexedWorkspaceSymbols(
+	views Views,
+	index *SymbolIndex,
+	getOutline GetOutline,
+) (symbols []WorkspaceSymbol) {
+	add := func(path string, symbol OutlineSymbol) {
+		symbols = append(symbols, WorkspaceSymbol{
+			Name:     symbol.Name,
+			Kind:     symbol.Kind,
+			Path:     path,
+			Position: symbol.Position,
+		})
+	}
+	// open buffers may be modified
+	open := make(map[string]bool)
+	for _, view := range views {
+		path := view.Buffer.AbsPath
+		if path == "" || open[path] {
+			continue
+		}
+		if languageParser(view.Buffer.language) == nil {
+			continue
+		}
+		open[path] = true
+		for _, symbol := range getOutline(view, false) {
+			add(path, symbol)
+		}
+	}
+	for _, symbol := range index.Symbols(open) {
+		add(symbol.Path, symbol.OutlineSymbol)
+	}
+	return
+}
+
+func WorkspaceSymbols(
+	cur CurrentView,
+	views Views,
+	client *LSPClient,
+	index *SymbolIndex,
+	indexSymbols IndexSymbols,
+	getOutline GetOutline,
+	getConfig GetConfig,
+	showPosition ShowPathPosition,
+	showLocation ShowLSPLocation,
+	pushJump PushJump,
+	moveCursor MoveCursor,
+	pushOverlay PushOverlay,
+	closeOverlay CloseOverlay,
+	show ShowMessage,
+	j AppendJournal,
+) {
+	view := cur()
+	if view == nil {
+		return
+	}
+
+	title := "Workspace Symbols"
+	root := view.Buffer.AbsDir
+	doc, useLSP := client.Documents[view.Buffer]
+	var indexed []WorkspaceSymbol
+	if useLSP {
+		root = doc.Endpoint.Root
+	} else {
+		if view.Buffer.Path != "" {
+			root = symbolIndexRoot(view.Buffer, getConfig)
+			select {
+			case <-indexSymbols(root):
+			default:
+				title += " (indexing)"
+			}
+		}
+		indexed = indexedWorkspaceSymbols(views, index, getOutline)
+		if len(indexed) == 0 {
+			show([]string{"no symbols indexed"})
+			return
+		}
+	}
+
+	type Candidate struct {
+		WorkspaceSymbol
+		Text     string
+		MatchLen int
+		Score    int
+	}
+	var candidates []Candidate
+
+	var id ID
+	dialog := &SelectionDialog{
+
+		Title: title,
+
+		OnClose: func(_ Scope) {
+			closeOverlay(id)
+		},
+
+		OnSelect: func(_ Scope, i ID) {
+			closeOverlay(id)
+			if int(i) >= len(candidates) {
+				return
+			}
+			symbol := candidates[i].WorkspaceSymbol
+			pushJump()
+			if symbol.Location != nil {
+				if err := showLocation(*symbol.Location); err != nil {
+					show(strings.Split(err.Error(), "\n"))
+				}
+				return
+			}
+			view, err := showPosition(symbol.Path, Position{
+				Line: symbol.Position.Line,
+			})
+			if err != nil {
+				show(strings.Split(err.Error(), "\n"))
+				return
+			}
+			moveCursorToCell(view, symbol.Position, moveCursor)
+		},
+
+		OnUpdate: func(_ Scope, runes []rune) (ids []ID, maxLen int, initIndex int) {
+			candidates = candidates[:0]
+
+			symbols := indexed
+			if useLSP {
+				var result json.RawMessage
+				if err := doc.Endpoint.Req("workspace/symbol", M{
+					"query": string(runes),
+				}).Result(&result); err != nil {
+					j("workspace symbols: %v", err)
+					return
+				}
+				var err error
+				symbols, err = parseLSPWorkspaceSymbols(result)
+				if err != nil {
+					j("workspace symbols: %v", err)
+					return
+				}
+			}
+
+			for _, symbol := range symbols {
+				chars := util.RunesToChars([]rune(symbol.Name))
+				matched, matchLen, score := fuzzyMatched(runes, &chars)
+				if !matched && !useLSP {
+					// server may match differently
+					continue
+				}
+				candidates = append(candidates, Candidate{
+					WorkspaceSymbol: symbol,
+					Text:            symbol.text(root),
+					MatchLen:        matchLen,
+					Score:           score,
+				})
+			}
+			if !useLSP {
+				// ranked by server otherwise
+				sort.SliceStable(candidates, func(i, j int) bool {
+					a, b := candidates[i], candidates[j]
+					if a.Score != b.Score {
+						return a.Score > b.Score
+					}
+					return a.Name < b.Name
+				})
+			}
+			if len(candidates) > maxWorkspaceSymbols {
+				candidates = candidates[:maxWorkspaceSymbols]
+			}
+
+			for i, candidate := range candidates {
+				if w := displayWidth(candidate.Text); w > maxLen {
+					maxLen = w
+				}
+				ids = append(ids, ID(i))
+			}
+			return
+		},
+
+		CandidateElement: func(scope Scope, i ID) Element {
+			var box Box
+			var focus ID
+			var style Style
+			var getStyle GetStyle
+			scope.Assign(&box, &focus, &style, &getStyle)
+			s := style
+			if i == focus {
+				hlStyle := getStyle("Highlight")(s)
+				fg, _, _ := hlStyle.Decompose()
+				s = s.Foreground(fg)
+			}
+			candidate := candidates[i]
+			offset := 0
+			if candidate.Container != "" {
+				offset = len([]rune(candidate.Container)) + 1
+			}
+			return Text(
+				box,
+				candidate.Text,
+				s,
+				OffsetStyleFunc(func(i int) StyleFunc {
+					fn := SameStyle
+					if i >= offset && i < offset+candidate.MatchLen {
+						fn = fn.SetUnderline(true)
+					} else {
+						fn = fn.SetUnderline(false)
+					}
+					return fn
+				}),
+			)
+		},
+	}
+
+	id = pushOverlay(OverlayObject(dialog))
+}
+
+func (_ Command) WorkspaceSymbols() (spec CommandSpec) {
+	spec.Desc = "search symbols in workspace"
+	spec.Func = WorkspaceSymbols
+	return
+}
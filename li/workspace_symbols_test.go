@@ -0,0 +1,157 @@
+package li
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestSymbolIndex(t *testing.T) {
+	root := t.TempDir()
+	ce(os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	ce(os.MkdirAll(filepath.Join(root, ".hidden"), 0755))
+	ce(ioutil.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\nfunc Foo() {\n}\n"), 0644))
+	ce(ioutil.WriteFile(filepath.Join(root, "sub", "b.go"), []byte("package sub\n\ntype Bar struct {\n\tBaz int\n}\n"), 0644))
+	ce(ioutil.WriteFile(filepath.Join(root, ".hidden", "c.go"), []byte("package c\n\nfunc Hidden() {\n}\n"), 0644))
+	ce(ioutil.WriteFile(filepath.Join(root, "README"), []byte("func Readme() {}\n"), 0644))
+
+	withEditor(func(
+		index *SymbolIndex,
+		indexSymbols IndexSymbols,
+		newMoment NewMomentFromBytes,
+	) {
+		<-indexSymbols(root)
+		var names []string
+		for _, symbol := range index.Symbols(nil) {
+			names = append(names, symbol.Name)
+		}
+		eq(t,
+			names, []string{"Foo", "Bar", "Baz"},
+			index.Symbols(map[string]bool{
+				filepath.Join(root, "a.go"): true,
+			})[0].Path, filepath.Join(root, "sub", "b.go"),
+		)
+
+		// reindex modified
+		path := filepath.Join(root, "a.go")
+		ce(ioutil.WriteFile(path, []byte("package a\n\nfunc Qux() {\n}\n"), 0644))
+		info, err := os.Stat(path)
+		ce(err)
+		ce(os.Chtimes(path, info.ModTime(), info.ModTime().Add(1)))
+		eq(t,
+			index.indexFile(newMoment, path), true,
+			index.Symbols(nil)[0].Name, "Qux",
+		)
+	})
+}
+
+func TestParseLSPWorkspaceSymbols(t *testing.T) {
+	symbols, err := parseLSPWorkspaceSymbols(json.RawMessage(`[
+		{
+			"name": "Foo", "kind": 12, "containerName": "foo",
+			"location": {"uri": "file:///tmp/foo.go", "range": {"start": {"line": 2, "character": 5}, "end": {"line": 2, "character": 8}}}
+		},
+		{
+			"name": "Bar", "kind": 23,
+			"location": {"uri": "file:///tmp/bar.go"}
+		}
+	]`))
+	ce(err)
+	eq(t,
+		len(symbols), 2,
+		symbols[0].Kind, "Function",
+		symbols[0].Path, "/tmp/foo.go",
+		symbols[0].Position.Line, 2,
+		symbols[0].text("/tmp"), "foo.Foo  Function  foo.go:3",
+		symbols[1].Location.URI, "file:///tmp/bar.go",
+	)
+}
+
+func TestWorkspaceSymbolsIndexed(t *testing.T) {
+	root := t.TempDir()
+	ce(os.Mkdir(filepath.Join(root, ".git"), 0755))
+	ce(ioutil.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\nfunc Foo() {\n}\n"), 0644))
+	ce(ioutil.WriteFile(filepath.Join(root, "b.go"), []byte("package a\n\n\n\ntype Bar struct {\n}\n"), 0644))
+
+	withEditor(func(
+		scope Scope,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+		newBuffer NewBufferFromFile,
+		newView NewViewFromBuffer,
+		cur CurrentView,
+		indexSymbols IndexSymbols,
+	) {
+		buffer, err := newBuffer(filepath.Join(root, "a.go"))
+		ce(err)
+		_, err = newView(buffer)
+		ce(err)
+		<-indexSymbols(root)
+		ctrl("loop")
+
+		scope.Call(WorkspaceSymbols)
+		ctrl("loop")
+		for _, r := range "bar" {
+			emitRune(r)
+		}
+		emitKey(tcell.KeyEnter)
+		view := cur()
+		eq(t,
+			view.Buffer.AbsPath, filepath.Join(root, "b.go"),
+			view.CursorLine, 4,
+			view.CursorCol, 5,
+		)
+	})
+}
+
+func TestWorkspaceSymbolsLSP(t *testing.T) {
+	withEditorBytes(t, []byte("package main\n\nfunc main() {\n}\n"), func(
+		scope Scope,
+		view *View,
+		buffer *Buffer,
+		client *LSPClient,
+		ctrl func(string),
+		emitRune EmitRune,
+		emitKey EmitKey,
+	) {
+		buffer.AbsPath = "/tmp/foo.go"
+		queries := make(chan string, 10)
+		endpoint := fakeLSPServer(t, func(method string, params json.RawMessage) any {
+			switch method {
+			case "workspace/symbol":
+				var p struct {
+					Query string
+				}
+				ce(json.Unmarshal(params, &p))
+				queries <- p.Query
+				return []M{
+					{
+						"name": "main", "kind": 12,
+						"location": M{
+							"uri":   pathToURI(buffer.AbsPath),
+							"range": M{"start": M{"line": 2, "character": 5}, "end": M{"line": 2, "character": 9}},
+						},
+					},
+				}
+			}
+			return nil
+		})
+		client.open(endpoint, buffer, view.GetMoment())
+
+		scope.Call(WorkspaceSymbols)
+		ctrl("loop")
+		emitRune('m')
+		emitKey(tcell.KeyEnter)
+		eq(t,
+			<-queries, "",
+			<-queries, "m",
+			view.CursorLine, 2,
+			view.CursorCol, 5,
+		)
+	})
+}
@@ -0,0 +1,14 @@
+package treesitter
+
+/*
+#include <tree_sitter/api.h>
+#include <tree-sitter-bash/src/parser.c>
+
+#cgo CFLAGS: -I${SRCDIR}/tree-sitter/lib/include
+*/
+import "C"
+import "unsafe"
+
+func ParseBash(src unsafe.Pointer, l int) *Parser {
+	return parse(C.tree_sitter_bash(), nil, src, l)
+}
@@ -0,0 +1,14 @@
+package treesitter
+
+/*
+#include <tree_sitter/api.h>
+#include <tree-sitter-c/src/parser.c>
+
+#cgo CFLAGS: -I${SRCDIR}/tree-sitter/lib/include
+*/
+import "C"
+import "unsafe"
+
+func ParseC(src unsafe.Pointer, l int) *Parser {
+	return parse(C.tree_sitter_c(), nil, src, l)
+}
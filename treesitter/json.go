@@ -0,0 +1,20 @@
+package treesitter
+
+/*
+#include <tree_sitter/api.h>
+
+#if TREE_SITTER_LANGUAGE_VERSION < 14
+#error "tree-sitter runtime does not support ABI 14, update the tree-sitter submodule"
+#endif
+
+// ABI 14 parser, compiled in parser_json.c
+const TSLanguage *tree_sitter_json(void);
+
+#cgo CFLAGS: -I${SRCDIR}/tree-sitter/lib/include
+*/
+import "C"
+import "unsafe"
+
+func ParseJSON(src unsafe.Pointer, l int) *Parser {
+	return parse(C.tree_sitter_json(), nil, src, l)
+}
@@ -1,6 +1,7 @@
 package treesitter
 
 /*
+#include <stdlib.h>
 #include <tree_sitter/api.h>
 #include <tree-sitter-go/src/parser.c>
 
@@ -23,11 +24,15 @@ type Parser struct {
 }
 
 func ParseGo(src unsafe.Pointer, l int) *Parser {
+	return parse(C.tree_sitter_go(), nil, src, l)
+}
+
+func parse(language *C.TSLanguage, oldTree TSTree, src unsafe.Pointer, l int) *Parser {
 	parser := C.ts_parser_new()
-	C.ts_parser_set_language(parser, C.tree_sitter_go())
+	C.ts_parser_set_language(parser, language)
 	tree := C.ts_parser_parse_string(
 		parser,
-		nil,
+		oldTree,
 		(*C.char)(src),
 		C.uint(l),
 	)
@@ -37,6 +42,32 @@ func ParseGo(src unsafe.Pointer, l int) *Parser {
 	}
 }
 
+// InputEdit describes an edit to the source, points are in rows and byte columns
+type InputEdit struct {
+	StartByte   int
+	OldEndByte  int
+	NewEndByte  int
+	StartPoint  TSPoint
+	OldEndPoint TSPoint
+	NewEndPoint TSPoint
+}
+
+// Reparse returns a new parser of edited source, reusing unchanged nodes of p.
+// p is not modified
+func (p *Parser) Reparse(src unsafe.Pointer, l int, edit InputEdit) *Parser {
+	tree := C.ts_tree_copy(p.Tree)
+	defer C.ts_tree_delete(tree)
+	C.ts_tree_edit(tree, &C.TSInputEdit{
+		start_byte:    C.uint32_t(edit.StartByte),
+		old_end_byte:  C.uint32_t(edit.OldEndByte),
+		new_end_byte:  C.uint32_t(edit.NewEndByte),
+		start_point:   edit.StartPoint,
+		old_end_point: edit.OldEndPoint,
+		new_end_point: edit.NewEndPoint,
+	})
+	return parse(C.ts_parser_language(p.Parser), tree, src, l)
+}
+
 func (p *Parser) Close() {
 	C.ts_tree_delete(p.Tree)
 	C.ts_parser_delete(p.Parser)
@@ -68,6 +99,26 @@ func NodePosition(node TSNode) (
 	return
 }
 
+func NodeByteRange(node TSNode) (start int, end int) {
+	return int(C.ts_node_start_byte(node)), int(C.ts_node_end_byte(node))
+}
+
+// ChildByFieldName returns child of node by field name in grammar, ok is false if no such child
+func ChildByFieldName(node TSNode, name string) (child TSNode, ok bool) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	child = C.ts_node_child_by_field_name(node, cName, C.uint32_t(len(name)))
+	return child, !bool(C.ts_node_is_null(child))
+}
+
+func NamedChildren(node TSNode) (children []TSNode) {
+	count := C.ts_node_named_child_count(node)
+	for i := C.uint(0); i < count; i++ {
+		children = append(children, C.ts_node_named_child(node, i))
+	}
+	return
+}
+
 func Point(row int, col int) TSPoint {
 	return TSPoint{C.uint(row), C.uint(col)}
 }
@@ -76,3 +127,14 @@ func (p *Parser) NodeAt(point TSPoint) TSNode {
 	root := C.ts_tree_root_node(p.Tree)
 	return C.ts_node_descendant_for_point_range(root, point, point)
 }
+
+func (p *Parser) RootNode() TSNode {
+	return C.ts_tree_root_node(p.Tree)
+}
+
+// NodeString returns the s-expression of node
+func NodeString(node TSNode) string {
+	cstr := C.ts_node_string(node)
+	defer C.free(unsafe.Pointer(cstr))
+	return C.GoString(cstr)
+}
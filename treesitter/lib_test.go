@@ -1,7 +1,38 @@
 package treesitter
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
 
 func TestParser(t *testing.T) {
 	runTest(t)
 }
+
+func TestLanguages(t *testing.T) {
+	for _, c := range []struct {
+		parse func(unsafe.Pointer, int) *Parser
+		src   string
+		root  string
+	}{
+		{ParseGo, "package main\n", "source_file"},
+		{ParsePython, "def foo():\n  pass\n", "module"},
+		{ParseYAML, "foo: [1, 2]\n", "stream"},
+		{ParseC, "int main() { return 0; }\n", "translation_unit"},
+		{ParseBash, "echo $HOME\n", "program"},
+		{ParseJSON, "{\"foo\": [1, true, null]}\n", "document"},
+		{ParseMarkdown, "# foo\n\n```go\nbar\n```\n\n- baz\n", "document"},
+	} {
+		src := []byte(c.src)
+		parser := c.parse(unsafe.Pointer(&src[0]), len(src))
+		root := parser.RootNode()
+		if NodeType(root) != c.root {
+			t.Fatalf("got %s", NodeType(root))
+		}
+		if str := NodeString(root); strings.Contains(str, "ERROR") {
+			t.Fatalf("got %s", str)
+		}
+		parser.Close()
+	}
+}
@@ -0,0 +1 @@
+#include "tree-sitter-json/src/parser.c"
@@ -0,0 +1 @@
+#include "tree-sitter-markdown/src/parser.c"
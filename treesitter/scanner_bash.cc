@@ -0,0 +1 @@
+#include "tree-sitter-bash/src/scanner.cc"
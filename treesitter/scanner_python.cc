@@ -0,0 +1 @@
+#include "tree-sitter-python/src/scanner.cc"
@@ -0,0 +1 @@
+#include "tree-sitter-yaml/src/scanner.cc"
@@ -0,0 +1,14 @@
+package treesitter
+
+/*
+#include <tree_sitter/api.h>
+#include <tree-sitter-yaml/src/parser.c>
+
+#cgo CFLAGS: -I${SRCDIR}/tree-sitter/lib/include
+*/
+import "C"
+import "unsafe"
+
+func ParseYAML(src unsafe.Pointer, l int) *Parser {
+	return parse(C.tree_sitter_yaml(), nil, src, l)
+}